@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	gejie "github.com/zshanhui/gejiezhipin/gejielib"
+)
+
+// exampleListingUrl is the listing checked when no pages are given
+const exampleListingUrl = "https://listado.mercadolibre.com.pe/teclado-mecanico"
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "check that scraping still works before a big crawl",
+}
+
+var doctorSelectorsCmd = &cobra.Command{
+	Use:   "selectors",
+	Short: "check the meli selectors on sample product and listing pages",
+	Long: "check the meli selectors on sample product and listing pages and the reviews the products embed, live or from saved fixtures, " +
+		"reporting the fields that are missing, empty or unparseable. exits non-zero when a field is broken",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		listings, _ := cmd.Flags().GetStringSlice("listing")
+		products, _ := cmd.Flags().GetStringSlice("product")
+		sample, _ := cmd.Flags().GetInt("sample")
+		replayDir, _ := cmd.Flags().GetString("replay-fixtures")
+		engineName, _ := cmd.Flags().GetString("engine")
+		if len(listings) == 0 && len(products) == 0 {
+			listings = []string{exampleListingUrl}
+		}
+
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		engine, err := gejie.ParseEngine(engineName)
+		if err != nil {
+			return err
+		}
+		opts := meliBrowserOptions("", replayDir)
+		if err := applyBrowserFlags(cmd, config, opts, gejie.DefaultMeliFingerprints); err != nil {
+			return err
+		}
+
+		loader, closeLoader, err := documentLoader(engine, opts)
+		if err != nil {
+			return err
+		}
+		defer closeLoader()
+
+		report, err := gejie.DoctorSelectors(cmd.Context(), loader, listings, products, sample)
+		printSelectorReport(report)
+		if err != nil {
+			return err
+		}
+		if failures := report.Failures(); len(failures) > 0 {
+			return fmt.Errorf("%d selector checks failed", len(failures))
+		}
+		fmt.Printf("all selectors ok\n")
+		return nil
+	},
+}
+
+// printSelectorReport prints the field checks of every page and then the failures
+func printSelectorReport(report *gejie.SelectorReport) {
+	for _, page := range report.Pages {
+		fmt.Printf("\n%s %s\n", page.Kind, page.Url)
+		if page.Err != nil {
+			fmt.Printf("  could not load page (%s): %v\n", gejie.FailureKind(page.Err), page.Err)
+			continue
+		}
+		for _, check := range page.Fields {
+			line := fmt.Sprintf("  %-28s %-12s", check.Field, check.Health)
+			if check.Selector != "" {
+				line += fmt.Sprintf(" %s => %.60q", check.Selector, check.Value)
+			}
+			if check.Err != nil {
+				line += fmt.Sprintf(" (%v)", check.Err)
+			}
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+	}
+
+	failures := report.Failures()
+	if len(failures) == 0 {
+		return
+	}
+	fmt.Printf("\n%d broken fields:\n", len(failures))
+	for _, failure := range failures {
+		fmt.Printf("  %s %s is %s on %d pages\n", failure.Kind, failure.Field, failure.Health, len(failure.Pages))
+		if failure.Err != nil {
+			fmt.Printf("    %v\n", failure.Err)
+		}
+	}
+}
+
+func init() {
+	doctorSelectorsCmd.Flags().StringSlice("listing", nil, "listing urls to check, defaults to "+exampleListingUrl+" when no pages are given")
+	doctorSelectorsCmd.Flags().StringSlice("product", nil, "product urls to check")
+	doctorSelectorsCmd.Flags().Int("sample", 3, "product links of the listings to check along with the given products")
+	doctorSelectorsCmd.Flags().String("engine", string(gejie.EngineHTTP), "how pages are loaded: http downloads the html only, browser renders every page")
+	doctorSelectorsCmd.Flags().String("replay-fixtures", "", "check pages saved in this directory with --record-fixtures instead of the network")
+	doctorCmd.AddCommand(doctorSelectorsCmd)
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -1,8 +1,12 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	gejie "github.com/zshanhui/gejiezhipin/gejielib"
@@ -18,16 +22,97 @@ var meliCmd = &cobra.Command{
 		onlyImages, _ := cmd.Flags().GetBool("only-images")
 		url, _ := cmd.Flags().GetString("url")
 		createCsv, _ := cmd.Flags().GetBool("create-csv")
+		recordDir, _ := cmd.Flags().GetString("record-fixtures")
+		replayDir, _ := cmd.Flags().GetString("replay-fixtures")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		resumeId, _ := cmd.Flags().GetString("resume")
+		gotoTimeout, _ := cmd.Flags().GetDuration("goto-timeout")
+		ignoreRobots, _ := cmd.Flags().GetBool("ignore-robots")
+		engineName, _ := cmd.Flags().GetString("engine")
+		descriptionHTML, _ := cmd.Flags().GetBool("description-html")
+		maxVariants, _ := cmd.Flags().GetInt("max-variants")
+		flattenVariants, _ := cmd.Flags().GetBool("flatten-variants")
+		onlyFull, _ := cmd.Flags().GetBool("only-full")
 
-		fmt.Printf("maxItems: %d, onlyImages: %t, url: %s, createCsv: %t", maxItems, onlyImages, url, createCsv)
-		routeMeliUrl(url, maxItems, onlyImages, createCsv)
+		config, err := loadConfig(cmd)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		engine, err := gejie.ParseEngine(engineName)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		fmt.Printf("maxItems: %d, onlyImages: %t, url: %s, createCsv: %t, concurrency: %d", maxItems, onlyImages, url, createCsv, concurrency)
+		searchOpts := gejie.DefaultMeliSearchOptions()
+		searchOpts.MaxItems = maxItems
+		searchOpts.CreateCsv = createCsv
+		searchOpts.Concurrency = concurrency
+		searchOpts.Retry = retryPolicy(cmd)
+		searchOpts.RespectRobots = !ignoreRobots
+		searchOpts.FlattenVariants = flattenVariants
+		searchOpts.OnlyFull = onlyFull
+		if resumeId != "" {
+			job, err := gejie.ResumeCrawlJob(gejie.JobsDir, resumeId)
+			if err != nil {
+				fmt.Printf("\ncould not resume: %v\n", err)
+				return
+			}
+			defer job.Close()
+			if url == "" {
+				url = job.Meta("url")
+			}
+			searchOpts.Job = job
+		}
+		browserOpts := meliBrowserOptions(recordDir, replayDir)
+		browserOpts.NavigationTimeout = float64(gotoTimeout.Milliseconds())
+		browserOpts.KeepDescriptionHTML = descriptionHTML
+		browserOpts.MaxVariants = maxVariants
+		startFetcher := func() (gejie.Fetcher, error) {
+			if err := applyBrowserFlags(cmd, config, browserOpts, gejie.DefaultMeliFingerprints); err != nil {
+				return nil, err
+			}
+			return gejie.NewFetcher(engine, browserOpts)
+		}
+		routeMeliUrl(cmd.Context(), url, onlyImages, searchOpts, startFetcher)
 	},
 }
 
 var productUrlPrefixes = []string{"https://www.mercadolibre", "https://articulo.mercadolibre", "mercadolibre"}
 var listUrlPrefixes = []string{"https://listado.mercadolibre", "listado.mercadolibre"}
 
-func routeMeliUrl(url string, maxItems int, onlyImages bool, createCsv bool) {
+// meliBrowserOptions returns the default browser options, recording or replaying fixtures when requested
+func meliBrowserOptions(recordDir string, replayDir string) *gejie.BrowserOptions {
+	opts := gejie.DefaultBrowserOptions()
+	if replayDir != "" {
+		opts.FixtureMode = gejie.FixtureReplay
+		opts.FixtureDir = replayDir
+	} else if recordDir != "" {
+		opts.FixtureMode = gejie.FixtureRecord
+		opts.FixtureDir = recordDir
+	}
+	return opts
+}
+
+// retryPolicy builds the retry policy from the --max-attempts and --retry-delay flags
+func retryPolicy(cmd *cobra.Command) *gejie.RetryPolicy {
+	policy := gejie.DefaultRetryPolicy()
+	policy.MaxAttempts, _ = cmd.Flags().GetInt("max-attempts")
+	policy.BaseDelay, _ = cmd.Flags().GetDuration("retry-delay")
+	return policy
+}
+
+// addRetryFlags adds the flags read by retryPolicy
+func addRetryFlags(cmd *cobra.Command) {
+	defaults := gejie.DefaultRetryPolicy()
+	cmd.Flags().Int("max-attempts", defaults.MaxAttempts, "times a url failing with a timeout, block or server error is tried before giving up")
+	cmd.Flags().Duration("retry-delay", defaults.BaseDelay, "backoff before the first retry, doubled for every retry after it")
+}
+
+// routeMeliUrl scrapes url by its page type, startFetcher is only called once the url is known to be a meli url
+func routeMeliUrl(ctx context.Context, url string, onlyImages bool, searchOpts *gejie.MeliSearchOptions, startFetcher func() (gejie.Fetcher, error)) {
 	if url == "" {
 		fmt.Printf("url is empty, please provide a valid meli url")
 		return
@@ -46,10 +131,26 @@ func routeMeliUrl(url string, maxItems int, onlyImages bool, createCsv bool) {
 		}
 	}
 
+	if !isProductUrl && !isListUrl {
+		fmt.Printf("url is not a valid meli, url: %s", url)
+		return
+	}
+	// one fetcher, and at most one browser, serves the whole run
+	fetcher, err := startFetcher()
+	if err != nil {
+		fmt.Printf("\n%v\n", err)
+		return
+	}
+	defer fetcher.Close()
+
 	if isProductUrl {
 		if onlyImages {
 			fmt.Printf("\nscraping only product images: %s", url)
-			images := gejie.ScrapeProductImages(nil, url)
+			images, err := fetcher.ProductImages(ctx, url)
+			if err != nil {
+				fmt.Printf("\ncould not scrape product images: %v\n", err)
+				return
+			}
 			// just print for now
 			for _, image := range images {
 				fmt.Println(image)
@@ -58,18 +159,101 @@ func routeMeliUrl(url string, maxItems int, onlyImages bool, createCsv bool) {
 		}
 
 		fmt.Printf("\nscraping product url: %s", url)
-		product := gejie.ScrapeProductPageDirect(url)
-		utils.PrintProduct(product)
-
-	} else if isListUrl {
-		fmt.Printf("\nscraping list url: %s", url)
-		products := gejie.RunMeliSearch(&url, int8(maxItems), createCsv)
+		product, err := fetcher.Product(ctx, url)
+		if err != nil {
+			fmt.Printf("\ncould not scrape product (%s): %v\n", gejie.FailureKind(err), err)
+			return
+		}
+		products := []gejie.MeliProduct{*product}
+		if searchOpts.FlattenVariants {
+			products = gejie.FlattenVariants(products)
+		}
 		for _, product := range products {
 			utils.PrintProduct(&product)
 		}
 
 	} else {
-		fmt.Printf("url is not a valid meli, url: %s", url)
+		fmt.Printf("\nscraping list url: %s", url)
+		if searchOpts.Job == nil {
+			job, err := createJob(searchJobName(url), url)
+			if err != nil {
+				fmt.Printf("\ncould not create crawl job: %v\n", err)
+				return
+			}
+			defer job.Close()
+			searchOpts.Job = job
+		}
+		fmt.Printf("\ncrawl job: %s (continue an interrupted run with --resume %s)\n", searchOpts.Job.ID, searchOpts.Job.ID)
+		result, err := gejie.RunMeliSearchWithFetcher(ctx, fetcher, &url, searchOpts)
+		if err != nil && result == nil {
+			fmt.Printf("\nsearch failed (%s): %v\n", gejie.FailureKind(err), err)
+			return
+		}
+		for _, product := range result.Products {
+			utils.PrintProduct(&product)
+		}
+		printFailures(result.Failures)
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("\ninterrupted, kept %d products scraped before stopping, %d were not scraped\n", len(result.Products), result.Interrupted)
+		} else if err != nil {
+			fmt.Printf("\n%v\n", err)
+		}
+	}
+}
+
+// createJob starts a crawl job checkpointing to the jobs directory, remembering the url to resume from
+func createJob(name string, url string) (*gejie.CrawlJob, error) {
+	job, err := gejie.CreateCrawlJob(gejie.JobsDir, gejie.NewCrawlJobID(name))
+	if err != nil {
+		return nil, err
+	}
+	if err := job.SetMeta("url", url); err != nil {
+		job.Close()
+		return nil, err
+	}
+	return job, nil
+}
+
+// searchJobName names a job after the search path, e.g. "listado.mercadolibre.com.pe/teclado-mecanico" -> "teclado-mecanico"
+func searchJobName(url string) string {
+	name := strings.TrimSuffix(url, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" {
+		return "meli-search"
+	}
+	return name
+}
+
+// printFailures summarises the urls a batch run gave up on after its retries, grouped by failure kind
+func printFailures(failures []gejie.ScrapeFailure) {
+	if len(failures) == 0 {
+		return
+	}
+	byKind := map[string][]gejie.ScrapeFailure{}
+	kinds := []string{}
+	for _, failure := range failures {
+		kind := gejie.FailureKind(failure.Err)
+		if _, ok := byKind[kind]; !ok {
+			kinds = append(kinds, kind)
+		}
+		byKind[kind] = append(byKind[kind], failure)
+	}
+	sort.Strings(kinds)
+
+	fmt.Printf("\n%d urls failed permanently:\n", len(failures))
+	for _, kind := range kinds {
+		fmt.Printf("  %s (%d):\n", kind, len(byKind[kind]))
+		for _, failure := range byKind[kind] {
+			fmt.Printf("    %s: %v\n", failure.Url, failure.Err)
+		}
+	}
+	if len(byKind["blocked"])+len(byKind["captcha"])+len(byKind["login-required"]) > 0 {
+		fmt.Printf("the site is pushing back, lower --rpm or rotate --proxies before running again\n")
 	}
 }
 
@@ -78,5 +262,16 @@ func init() {
 	meliCmd.Flags().String("url", "", "mercadolibre url to scrape - page type will be auto detected")
 	meliCmd.Flags().Bool("only-images", false, "only scrape the images from given product url, no other data will be scraped")
 	meliCmd.Flags().Bool("create-csv", false, "create a csv file of the scraped products")
+	meliCmd.Flags().Bool("only-full", false, "keep only products fulfilled by meli (Full), only for product list urls")
+	meliCmd.Flags().Bool("description-html", false, "keep the sanitized html of product descriptions next to their plain text")
+	meliCmd.Flags().Int("max-variants", 1, "variants of every product to enumerate by following its color, size, ... pickers, 1 keeps the variant the page opens with")
+	meliCmd.Flags().Bool("flatten-variants", false, "print and export a row per variant instead of one per product")
+	meliCmd.Flags().String("resume", "", "job id of an interrupted list url run to continue, the url is taken from the job when not given")
+	meliCmd.Flags().Int("concurrency", 1, "number of product pages scraped at the same time, only for product list urls")
+	meliCmd.Flags().String("engine", string(gejie.EngineBrowser), "how pages are loaded: http downloads the html only, browser renders every page, auto renders only pages whose html is incomplete")
+	meliCmd.Flags().Duration("goto-timeout", time.Duration(gejie.DefaultBrowserOptions().NavigationTimeout)*time.Millisecond, "timeout of every page navigation")
+	addRetryFlags(meliCmd)
+	meliCmd.Flags().String("record-fixtures", "", "save every visited page document to this directory for offline replay")
+	meliCmd.Flags().String("replay-fixtures", "", "serve pages from fixtures saved in this directory instead of the network")
 	rootCmd.AddCommand(meliCmd)
 }
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	gejie "github.com/zshanhui/gejiezhipin/gejielib"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+var meliReviewsCmd = &cobra.Command{
+	Use:   "reviews",
+	Short: "scrape the customer reviews of a meli product",
+	Long: "scrape the customer reviews of a meli product, following the pages of its reviews modal, " +
+		"and print them with a histogram of their stars",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		url, _ := cmd.Flags().GetString("url")
+		max, _ := cmd.Flags().GetInt("max")
+		engineName, _ := cmd.Flags().GetString("engine")
+		recordDir, _ := cmd.Flags().GetString("record-fixtures")
+		replayDir, _ := cmd.Flags().GetString("replay-fixtures")
+		if url == "" {
+			return errors.New("--url of a product page is required")
+		}
+
+		config, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		engine, err := gejie.ParseEngine(engineName)
+		if err != nil {
+			return err
+		}
+		opts := meliBrowserOptions(recordDir, replayDir)
+		if err := applyBrowserFlags(cmd, config, opts, gejie.DefaultMeliFingerprints); err != nil {
+			return err
+		}
+		loader, closeLoader, err := documentLoader(engine, opts)
+		if err != nil {
+			return err
+		}
+		defer closeLoader()
+
+		result, err := gejie.ScrapeReviews(cmd.Context(), loader, url, max)
+		if result != nil {
+			for _, review := range result.Reviews {
+				utils.PrintProduct(review)
+			}
+			printReviewHistogram(result.Histogram)
+		}
+		return err
+	},
+}
+
+// printReviewHistogram prints a bar per star from 5 down to 1
+func printReviewHistogram(histogram gejie.ReviewHistogram) {
+	fmt.Printf("\n%d reviews, %.1f stars on average\n", histogram.Total, histogram.Average)
+	for stars := 5; stars >= 1; stars-- {
+		percent := histogram.Percent(stars)
+		fmt.Printf("  %d stars %-20s %4d (%.0f%%)\n", stars, strings.Repeat("#", int(percent/5)), histogram.Count(stars), percent)
+	}
+}
+
+func init() {
+	meliReviewsCmd.Flags().String("url", "", "product url whose reviews are scraped")
+	meliReviewsCmd.Flags().Int("max", 50, "max reviews to scrape, 0 scrapes every review")
+	meliReviewsCmd.Flags().String("engine", string(gejie.EngineHTTP), "how pages are loaded: http downloads the html only, browser renders every page")
+	meliReviewsCmd.Flags().String("record-fixtures", "", "save every visited page document to this directory for offline replay")
+	meliReviewsCmd.Flags().String("replay-fixtures", "", "serve pages from fixtures saved in this directory instead of the network")
+	meliCmd.AddCommand(meliReviewsCmd)
+}
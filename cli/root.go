@@ -1,7 +1,10 @@
 package cli
 
 import (
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/spf13/cobra"
 )
@@ -12,8 +15,22 @@ var rootCmd = &cobra.Command{
 	Long:  "scrape sites from command line using Golang; eCommerce product data, job listings, etc. add more details later",
 }
 
+// SignalContext returns a context cancelled by the first SIGINT/SIGTERM so running scrapes can stop
+// and flush the results collected so far, a second signal terminates the process right away
+func SignalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		// restore the default signal behaviour
+		stop()
+	}()
+	return ctx, stop
+}
+
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := SignalContext()
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	gejie "github.com/zshanhui/gejiezhipin/gejielib"
+)
+
+const zhipinExampleUrl = "https://www.zhipin.com/job_detail/b6840d4438ff55c41n1609S-FFVT.html"
+
+var zhipinCmd = &cobra.Command{
+	Use:   "zhipin",
+	Short: "scrape zhipin job postings",
+	Long:  "scrape zhipin job postings starting from a job detail url and following the recommended jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+		url, _ := cmd.Flags().GetString("url")
+		collectLinks, _ := cmd.Flags().GetBool("collect-links")
+		maxDepth, _ := cmd.Flags().GetInt("max-depth")
+		resumeId, _ := cmd.Flags().GetString("resume")
+		ignoreRobots, _ := cmd.Flags().GetBool("ignore-robots")
+
+		config, err := loadConfig(cmd)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+
+		var job *gejie.CrawlJob
+		if resumeId != "" {
+			job, err = gejie.ResumeCrawlJob(gejie.JobsDir, resumeId)
+			if err != nil {
+				fmt.Printf("could not resume: %v\n", err)
+				return
+			}
+			if url == "" {
+				url = job.Meta("url")
+			}
+		} else {
+			if url == "" {
+				url = zhipinExampleUrl
+			}
+			job, err = createJob("zhipin", url)
+			if err != nil {
+				fmt.Printf("could not create crawl job: %v\n", err)
+				return
+			}
+		}
+		defer job.Close()
+		fmt.Printf("crawl job: %s (continue an interrupted run with --resume %s)\n", job.ID, job.ID)
+
+		opts := gejie.DefaultZhipinOptions()
+		opts.CollectLinks = collectLinks
+		opts.MaxDepth = maxDepth
+		opts.Job = job
+		opts.Retry = retryPolicy(cmd)
+		opts.RespectRobots = !ignoreRobots
+		bm, err := browserManager(cmd, config, gejie.DefaultZhipinBrowserOptions(), gejie.DefaultZhipinFingerprints)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			return
+		}
+		defer bm.Close()
+		err = gejie.RunZhipin(cmd.Context(), bm, url, opts)
+		if errors.Is(err, context.Canceled) {
+			fmt.Printf("\ninterrupted, resume with --resume %s\n", job.ID)
+		} else if err != nil {
+			fmt.Printf("\nzhipin run failed (%s): %v\n", gejie.FailureKind(err), err)
+		}
+	},
+}
+
+func init() {
+	zhipinCmd.Flags().String("url", "", "zhipin job detail url to start from")
+	zhipinCmd.Flags().Bool("collect-links", true, "also scrape the recommended job postings linked from the first posting")
+	zhipinCmd.Flags().Int("max-depth", 1, "how many links away from the first posting to follow, 0 for no limit")
+	zhipinCmd.Flags().String("resume", "", "job id of an interrupted run to continue, the url is taken from the job when not given")
+	addRetryFlags(zhipinCmd)
+	rootCmd.AddCommand(zhipinCmd)
+}
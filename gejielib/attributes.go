@@ -0,0 +1,143 @@
+package gejie
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MeliAttribute is a row of the product's "Características del producto" tables
+type MeliAttribute struct {
+	Name  string
+	Value string
+}
+
+// MeliAttributeSection is one titled table of attributes, e.g. "Características principales"
+type MeliAttributeSection struct {
+	Title      string
+	Attributes []MeliAttribute
+}
+
+// jsonLDAttributeNames names the schema.org product properties like the spec tables of meli do
+var jsonLDAttributeNames = map[string]string{
+	"brand": "Marca",
+	"model": "Modelo",
+	"color": "Color",
+}
+
+// addAttributes merges sections into product, the first source to have an attribute keeps it.
+// the sections of the first source with titled sections are kept as they are
+func addAttributes(product *MeliProduct, sections []MeliAttributeSection, source FieldSource) {
+	added := false
+	for _, section := range sections {
+		for _, attribute := range section.Attributes {
+			if product.Attributes == nil {
+				product.Attributes = map[string]string{}
+			}
+			if _, ok := product.Attributes[attribute.Name]; !ok {
+				product.Attributes[attribute.Name] = attribute.Value
+				added = true
+			}
+		}
+	}
+	if len(product.AttributeSections) == 0 && len(sections) > 0 && sections[0].Title != "" {
+		product.AttributeSections = sections
+	}
+	if added && product.FieldSources[FieldAttributes] == "" {
+		product.FieldSources[FieldAttributes] = source
+	}
+}
+
+// jsonLDAttributes reads the brand, model, colour and additionalProperty values of a schema.org
+// Product as one untitled section
+func jsonLDAttributes(product map[string]any) []MeliAttributeSection {
+	attributes := []MeliAttribute{}
+	for _, property := range []string{"brand", "model", "color"} {
+		value := jsonString(product[property])
+		if value == "" {
+			// brand is often a Brand object
+			value = jsonString(jsonPath(product, property, "name"))
+		}
+		if value = strings.TrimSpace(value); value != "" {
+			attributes = append(attributes, MeliAttribute{Name: jsonLDAttributeNames[property], Value: value})
+		}
+	}
+	properties, _ := product["additionalProperty"].([]any)
+	for _, property := range properties {
+		name := strings.TrimSpace(jsonString(jsonPath(property, "name")))
+		value := strings.TrimSpace(jsonAttributeValue(jsonPath(property, "value")))
+		if name != "" && value != "" {
+			attributes = append(attributes, MeliAttribute{Name: name, Value: value})
+		}
+	}
+	if len(attributes) == 0 {
+		return nil
+	}
+	return []MeliAttributeSection{{Attributes: attributes}}
+}
+
+// preloadedStateAttributes reads the spec sections of the technical_specifications component,
+// whose attributes name their row in id and value in text
+func preloadedStateAttributes(state any) []MeliAttributeSection {
+	specs, ok := findJSONKey(findJSONKey(state, "technical_specifications"), "specs").([]any)
+	if !ok {
+		return nil
+	}
+	sections := []MeliAttributeSection{}
+	for _, spec := range specs {
+		section := MeliAttributeSection{Title: strings.TrimSpace(jsonString(jsonPath(spec, "title")))}
+		attributes, _ := jsonPath(spec, "attributes").([]any)
+		for _, attribute := range attributes {
+			name := firstJSONString(attribute, [][]string{{"id"}, {"name"}})
+			value := firstJSONString(attribute, [][]string{{"text"}, {"value"}})
+			name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+			if name != "" && value != "" {
+				section.Attributes = append(section.Attributes, MeliAttribute{Name: name, Value: value})
+			}
+		}
+		if len(section.Attributes) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// jsonAttributeValue reads attribute values written as strings, numbers or booleans
+func jsonAttributeValue(data any) string {
+	switch value := data.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
+
+// attributeSectionsFromHTML reads the spec tables of the page, a table without a title of its own
+// belongs to the section before it
+func attributeSectionsFromHTML(doc *goquery.Document, selectors Selectors) []MeliAttributeSection {
+	sections := []MeliAttributeSection{}
+	selectors.Find(doc, SelectorSpecSections).Each(func(_ int, table *goquery.Selection) {
+		section := MeliAttributeSection{Title: strings.TrimSpace(selectors.Find(table, SelectorSpecSectionTitle).First().Text())}
+		selectors.Find(table, SelectorSpecRows).Each(func(_ int, row *goquery.Selection) {
+			name := strings.TrimSpace(selectors.Find(row, SelectorSpecName).First().Text())
+			value := strings.TrimSpace(selectors.Find(row, SelectorSpecValue).First().Text())
+			if name != "" && value != "" {
+				section.Attributes = append(section.Attributes, MeliAttribute{Name: name, Value: value})
+			}
+		})
+		if len(section.Attributes) == 0 {
+			return
+		}
+		if section.Title == "" && len(sections) > 0 {
+			last := &sections[len(sections)-1]
+			last.Attributes = append(last.Attributes, section.Attributes...)
+			return
+		}
+		sections = append(sections, section)
+	})
+	return sections
+}
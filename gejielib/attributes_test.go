@@ -0,0 +1,67 @@
+package gejie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProductAttributesMergeSources(t *testing.T) {
+	html := `<html><head>
+<script type="application/ld+json">{"@type":"Product","name":"Silla Gamer","brand":{"@type":"Brand","name":"Cougar"},
+  "additionalProperty":[{"name":"Carga máxima","value":150}]}</script>
+</head><body>
+<script>window.__PRELOADED_STATE__ = {"components":{"technical_specifications":{"specs":[
+  {"title":"Características principales","attributes":[{"id":"Marca","text":"Cougar Gaming"},{"id":"Modelo","text":"Armor One"}]}]}}};</script>
+<h1 class="ui-pdp-title">Silla Gamer</h1>
+<div class="ui-pdp-price__second-line"><span class="andes-money-amount__fraction">999</span></div>
+<div class="ui-vpp-striped-specs__table"><h3>Otros</h3><table>
+<tr><th>Material</th><td>Cuero sintético</td></tr>
+<tr><th>Modelo</th><td>Otro modelo</td></tr>
+</table></div>
+</body></html>`
+
+	product, _, err := productFromHTML(structuredDoc(t, html), structuredPageUrl, structuredPageUrl)
+	if err != nil {
+		t.Fatalf("productFromHTML failed: %v", err)
+	}
+	expected := map[string]string{
+		"Marca":        "Cougar",
+		"Carga máxima": "150",
+		"Modelo":       "Armor One",
+		"Material":     "Cuero sintético",
+	}
+	if !reflect.DeepEqual(product.Attributes, expected) {
+		t.Errorf("Attributes = %v, expected %v", product.Attributes, expected)
+	}
+	expectedSections := []MeliAttributeSection{{
+		Title:      "Características principales",
+		Attributes: []MeliAttribute{{"Marca", "Cougar Gaming"}, {"Modelo", "Armor One"}},
+	}}
+	if !reflect.DeepEqual(product.AttributeSections, expectedSections) {
+		t.Errorf("AttributeSections = %+v, expected the preloaded state sections", product.AttributeSections)
+	}
+	if source := product.FieldSources[FieldAttributes]; source != SourceJSONLD {
+		t.Errorf("attributes came from %q, expected %q", source, SourceJSONLD)
+	}
+}
+
+func TestAttributeSectionsFromHTML(t *testing.T) {
+	doc := structuredDoc(t, `<html><body>
+<div class="ui-vpp-striped-specs__table"><h3 class="ui-vpp-striped-specs__header">Principales</h3><table>
+<tr class="andes-table__row"><th class="andes-table__header">Marca</th><td class="andes-table__column"><span class="andes-table__column--value">Redragon</span></td></tr>
+<tr class="andes-table__row"><th class="andes-table__header">Vacío</th><td class="andes-table__column"></td></tr>
+</table></div>
+<div class="ui-vpp-striped-specs__table"><table>
+<tr class="andes-table__row"><th class="andes-table__header">Color</th><td class="andes-table__column"><span class="andes-table__column--value">Negro</span></td></tr>
+</table></div>
+</body></html>`)
+
+	sections := attributeSectionsFromHTML(doc, selectorsFor(SiteMeli, structuredPageUrl))
+	expected := []MeliAttributeSection{{
+		Title:      "Principales",
+		Attributes: []MeliAttribute{{"Marca", "Redragon"}, {"Color", "Negro"}},
+	}}
+	if !reflect.DeepEqual(sections, expected) {
+		t.Errorf("attributeSectionsFromHTML() = %+v, expected %+v", sections, expected)
+	}
+}
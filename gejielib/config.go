@@ -0,0 +1,98 @@
+package gejie
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultConfigPath is read when it exists and no other config file is given
+const DefaultConfigPath = "gejie.json"
+
+// Config is the settings file of gejie, every section is optional
+type Config struct {
+	RateLimits RateLimitConfig `json:"rate_limits"`
+}
+
+// RateLimitConfig sets the default host limit and overrides per domain, e.g.
+//
+//	{"default": {"requests_per_minute": 20, "burst": 2, "max_jitter": "1.5s"},
+//	 "hosts": {"mercadolibre.com.mx": {"requests_per_minute": 10}}}
+type RateLimitConfig struct {
+	Default *HostLimitConfig           `json:"default"`
+	Hosts   map[string]HostLimitConfig `json:"hosts"`
+}
+
+// HostLimitConfig is the file form of a HostLimit, fields left out keep the default
+type HostLimitConfig struct {
+	RequestsPerMinute *float64        `json:"requests_per_minute"`
+	Burst             *int            `json:"burst"`
+	MinJitter         *ConfigDuration `json:"min_jitter"`
+	MaxJitter         *ConfigDuration `json:"max_jitter"`
+}
+
+// ConfigDuration reads durations written like "1.5s" or "500ms"
+type ConfigDuration time.Duration
+
+func (d *ConfigDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1.5s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = ConfigDuration(parsed)
+	return nil
+}
+
+// LoadConfig reads the config file at path
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %w", err)
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// apply returns base with the fields set in c
+func (c HostLimitConfig) apply(base HostLimit) HostLimit {
+	if c.RequestsPerMinute != nil {
+		base.RequestsPerMinute = *c.RequestsPerMinute
+	}
+	if c.Burst != nil {
+		base.Burst = *c.Burst
+	}
+	if c.MinJitter != nil {
+		base.MinJitter = time.Duration(*c.MinJitter)
+	}
+	if c.MaxJitter != nil {
+		base.MaxJitter = time.Duration(*c.MaxJitter)
+	}
+	return base
+}
+
+// DefaultLimit returns DefaultHostLimit with the configured default applied
+func (c RateLimitConfig) DefaultLimit() HostLimit {
+	if c.Default == nil {
+		return DefaultHostLimit()
+	}
+	return c.Default.apply(DefaultHostLimit())
+}
+
+// NewRateLimiter builds a limiter with defaults and the configured host limits, hosts fill
+// the fields they leave out from defaults. the fields set in overrides, e.g. from command line
+// flags, win over both
+func (c RateLimitConfig) NewRateLimiter(defaults HostLimit, overrides HostLimitConfig) *RateLimiter {
+	limiter := NewRateLimiter(overrides.apply(defaults))
+	for host, limit := range c.Hosts {
+		limiter.SetHostLimit(host, overrides.apply(limit.apply(defaults)))
+	}
+	return limiter
+}
@@ -0,0 +1,69 @@
+package gejie
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigRateLimits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gejie.json")
+	data := `{
+		"rate_limits": {
+			"default": {"requests_per_minute": 20, "max_jitter": "1.5s"},
+			"hosts": {"mercadolibre.com.mx": {"requests_per_minute": 10, "burst": 3}}
+		}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	defaults := config.RateLimits.DefaultLimit()
+	expected := HostLimit{RequestsPerMinute: 20, Burst: 1, MaxJitter: 1500 * time.Millisecond}
+	if defaults != expected {
+		t.Errorf("DefaultLimit() = %+v, expected %+v", defaults, expected)
+	}
+
+	limiter := config.RateLimits.NewRateLimiter(defaults, HostLimitConfig{})
+	expected = HostLimit{RequestsPerMinute: 10, Burst: 3, MaxJitter: 1500 * time.Millisecond}
+	if limit := limiter.limitFor("articulo.mercadolibre.com.mx"); limit != expected {
+		t.Errorf("limit of articulo.mercadolibre.com.mx = %+v, expected %+v", limit, expected)
+	}
+	if limit := limiter.limitFor("articulo.mercadolibre.com.pe"); limit != defaults {
+		t.Errorf("limit of articulo.mercadolibre.com.pe = %+v, expected the default %+v", limit, defaults)
+	}
+}
+
+func TestRateLimitOverridesWinOverHosts(t *testing.T) {
+	rpm, burst := 10.0, 3
+	config := RateLimitConfig{Hosts: map[string]HostLimitConfig{
+		"mercadolibre.com.mx": {RequestsPerMinute: &rpm, Burst: &burst},
+	}}
+	flagRpm := 40.0
+	limiter := config.NewRateLimiter(DefaultHostLimit(), HostLimitConfig{RequestsPerMinute: &flagRpm})
+
+	expected := DefaultHostLimit()
+	expected.RequestsPerMinute, expected.Burst = 40, 3
+	if limit := limiter.limitFor("articulo.mercadolibre.com.mx"); limit != expected {
+		t.Errorf("limit of articulo.mercadolibre.com.mx = %+v, expected the flag rpm over the host one %+v", limit, expected)
+	}
+	expected.Burst = DefaultHostLimit().Burst
+	if limit := limiter.limitFor("articulo.mercadolibre.com.pe"); limit != expected {
+		t.Errorf("limit of articulo.mercadolibre.com.pe = %+v, expected %+v", limit, expected)
+	}
+}
+
+func TestLoadConfigInvalidDuration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gejie.json")
+	if err := os.WriteFile(path, []byte(`{"rate_limits": {"default": {"max_jitter": 2}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("LoadConfig accepted a duration that is not a string")
+	}
+}
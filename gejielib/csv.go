@@ -4,7 +4,9 @@ import (
 	"encoding/csv"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/zshanhui/gejiezhipin/utils"
@@ -39,40 +41,105 @@ func CreateMeliProductCsv(products []MeliProduct, baseFilename string) error {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	// Write CSV header
-	header := []string{
-		"Title",
-		"Local currency amount",
-		"USD amount",
-		"URL",
-		"Review count",
-		"Rating",
-		"Minimum sold",
-		"Minimum revenue",
-		"Description content",
-		"Store name",
-		"Store url",
-	}
-	headerChinese := []string{
-		"标题",
-		"本地货币价格",
-		"美元价格",
-		"链接",
-		"评论数",
-		"评分",
-		"销量",
-		"最低收入",
-		"描述内容",
-		"店铺名",
-		"店铺链接",
+	fmt.Print("headers in Chinese: ", meliProductCsvHeaderChinese)
+
+	if err := writer.WriteAll(meliProductCsvRecords(products)); err != nil {
+		return fmt.Errorf("failed to write CSV rows: %w", err)
 	}
-	fmt.Print("headers in Chinese: ", headerChinese)
 
-	if err := writer.Write(header); err != nil {
-		return fmt.Errorf("failed to write CSV header: %w", err)
+	return nil
+}
+
+var meliProductCsvHeader = []string{
+	"Title",
+	"Local currency amount",
+	"USD amount",
+	"List price",
+	"Discount %",
+	"Installments",
+	"Installment amount",
+	"Interest free",
+	"Promotions",
+	"URL",
+	"Review count",
+	"Rating",
+	"Minimum sold",
+	"Minimum revenue",
+	"Condition",
+	"Available quantity",
+	"Status",
+	"Warranty",
+	"Description content",
+	"Description html",
+	"Store name",
+	"Store url",
+	"Fulfillment",
+	"Free shipping",
+	"Shipping origin",
+	"Delivery promise",
+	"Delivery earliest",
+	"Delivery latest",
+	"Pickup",
+	"Card price",
+	"Card list price",
+	"Card discount %",
+	"Card installments",
+	"Card promotions",
+	"Card fulfillment",
+	"Card free shipping",
+	"Variant",
+}
+
+var meliProductCsvHeaderChinese = []string{
+	"标题",
+	"本地货币价格",
+	"美元价格",
+	"原价",
+	"折扣%",
+	"分期数",
+	"每期金额",
+	"免息",
+	"促销",
+	"链接",
+	"评论数",
+	"评分",
+	"销量",
+	"最低收入",
+	"成色",
+	"库存",
+	"状态",
+	"保修",
+	"描述内容",
+	"描述HTML",
+	"店铺名",
+	"店铺链接",
+	"配送方式",
+	"免运费",
+	"发货地",
+	"送达承诺",
+	"最早送达",
+	"最晚送达",
+	"自提",
+	"列表页价格",
+	"列表页原价",
+	"列表页折扣%",
+	"列表页分期",
+	"列表页促销",
+	"列表页配送方式",
+	"列表页免运费",
+	"款式",
+}
+
+// meliProductCsvRecords returns the header and a row per product, every attribute any product has
+// gets a column of its own after the fixed ones, e.g. "Attribute: Marca"
+func meliProductCsvRecords(products []MeliProduct) [][]string {
+	attributeNames := productAttributeNames(products)
+	header := append([]string{}, meliProductCsvHeader...)
+	for _, name := range attributeNames {
+		header = append(header, "Attribute: "+name)
 	}
+	records := [][]string{header}
 
-	// Write product data
 	for _, product := range products {
 		// Convert fields to strings, handling nil pointers
 		reviewCount := ""
@@ -99,24 +166,128 @@ func CreateMeliProductCsv(products []MeliProduct, baseFilename string) error {
 			utils.CurrencyAbbrevUnitedStatesDollar, amountDecimal*float32(PeruvianSolUsdRate))
 		usdMinimumRevenue := fmt.Sprintf("US$ %f", amountDecimal*float32(PeruvianSolUsdRate))
 
+		listPrice, discount := "", ""
+		if product.Price.ListAmountCents > 0 {
+			listPrice = fmt.Sprintf("%s %f", utils.CurrencyCodeToAbbrev(product.Price.CurrencyCode), float32(product.Price.ListAmountCents)/100)
+			discount = strconv.Itoa(product.Price.DiscountPercent)
+		}
+		installments, installmentAmount, interestFree := "", "", ""
+		if terms := product.Price.Installments; terms != nil {
+			installments = strconv.Itoa(terms.Count)
+			installmentAmount = fmt.Sprintf("%s %f", utils.CurrencyCodeToAbbrev(product.Price.CurrencyCode), float32(terms.AmountCents)/100)
+			interestFree = strconv.FormatBool(terms.InterestFree)
+		}
+
+		availableQuantity := ""
+		if product.AvailableQuantity != nil {
+			availableQuantity = product.AvailableQuantity.String()
+		}
+
+		freeShipping, earliest, latest := "", "", ""
+		if product.Shipping.Fulfillment != "" {
+			freeShipping = strconv.FormatBool(product.Shipping.FreeShipping)
+		}
+		if delivery := product.Shipping.Delivery; delivery != nil {
+			earliest = delivery.Earliest.Format(time.DateOnly)
+			latest = delivery.Latest.Format(time.DateOnly)
+		}
+
 		row := []string{
 			product.Title,
 			oCurrencyAmount,
 			usdPriceAmount,
+			listPrice,
+			discount,
+			installments,
+			installmentAmount,
+			interestFree,
+			strings.Join(product.Price.Promotions, "; "),
 			product.Url,
 			reviewCount,
 			rating,
 			soldMoreThan,
 			usdMinimumRevenue,
+			string(product.Condition),
+			availableQuantity,
+			string(product.Status),
+			product.Warranty,
 			product.DescriptionContent,
+			product.DescriptionHTML,
 			product.StoreInfo.Name,
 			product.StoreInfo.Url,
+			string(product.Shipping.Fulfillment),
+			freeShipping,
+			string(product.Shipping.Origin),
+			product.Shipping.Promise,
+			earliest,
+			latest,
+			strings.Join(product.Shipping.PickupOptions, "; "),
+		}
+		row = append(row, cardColumns(product.Card)...)
+		row = append(row, variantColumn(product.Variants))
+		for _, name := range attributeNames {
+			row = append(row, product.Attributes[name])
 		}
+		records = append(records, row)
+	}
+	return records
+}
 
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write CSV row: %w", err)
+// productAttributeNames lists the attribute names of all products, sorted
+func productAttributeNames(products []MeliProduct) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, product := range products {
+		for name := range product.Attributes {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
 		}
 	}
+	sort.Strings(names)
+	return names
+}
 
-	return nil
+// cardColumns writes the price terms and shipping the search card of a product showed, empty
+// for products scraped from their url alone
+func cardColumns(card *ListingCard) []string {
+	if card == nil {
+		return make([]string, 7)
+	}
+	currency := utils.CurrencyCodeToAbbrev(card.Price.CurrencyCode)
+	price, listPrice, discount, installments, freeShipping := "", "", "", "", ""
+	if card.Price.AmountCents > 0 {
+		price = fmt.Sprintf("%s %f", currency, float32(card.Price.AmountCents)/100)
+	}
+	if card.Price.ListAmountCents > 0 {
+		listPrice = fmt.Sprintf("%s %f", currency, float32(card.Price.ListAmountCents)/100)
+		discount = strconv.Itoa(card.Price.DiscountPercent)
+	}
+	if terms := card.Price.Installments; terms != nil {
+		installments = fmt.Sprintf("%dx %s %f", terms.Count, currency, float32(terms.AmountCents)/100)
+	}
+	if card.Shipping.Fulfillment != "" || card.Shipping.FreeShipping {
+		freeShipping = strconv.FormatBool(card.Shipping.FreeShipping)
+	}
+	return []string{
+		price,
+		listPrice,
+		discount,
+		installments,
+		strings.Join(card.Price.Promotions, "; "),
+		string(card.Shipping.Fulfillment),
+		freeShipping,
+	}
+}
+
+// variantColumn names the variant of a flattened product, or counts the variants of a product page
+func variantColumn(variants []MeliVariant) string {
+	switch len(variants) {
+	case 0:
+		return ""
+	case 1:
+		return variants[0].Name()
+	}
+	return fmt.Sprintf("%d variants", len(variants))
 }
@@ -1 +1,28 @@
 package gejie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMeliProductCsvRecordsHaveAttributeColumns(t *testing.T) {
+	products := []MeliProduct{
+		{Title: "Teclado", Attributes: map[string]string{"Tipo de switch": "Outemu Blue", "Marca": "Redragon"}},
+		{Title: "Silla", Attributes: map[string]string{"Carga máxima": "150 kg"}},
+	}
+
+	records := meliProductCsvRecords(products)
+	if len(records) != 3 {
+		t.Fatalf("meliProductCsvRecords returned %d records, expected a header and 2 rows", len(records))
+	}
+	fixed := len(meliProductCsvHeader)
+	if got := records[0][fixed:]; !reflect.DeepEqual(got, []string{"Attribute: Carga máxima", "Attribute: Marca", "Attribute: Tipo de switch"}) {
+		t.Errorf("attribute columns = %v", got)
+	}
+	if got := records[1][fixed:]; !reflect.DeepEqual(got, []string{"", "Redragon", "Outemu Blue"}) {
+		t.Errorf("first row attributes = %v", got)
+	}
+	if got := records[2][fixed:]; !reflect.DeepEqual(got, []string{"150 kg", "", ""}) {
+		t.Errorf("second row attributes = %v", got)
+	}
+}
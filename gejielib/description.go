@@ -0,0 +1,144 @@
+package gejie
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// descriptionTags are the tags kept by sanitizeDescriptionHTML, other tags are replaced by their children
+var descriptionTags = map[atom.Atom]bool{
+	atom.P: true, atom.Br: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Strong: true, atom.B: true, atom.Em: true, atom.I: true, atom.U: true,
+	atom.H2: true, atom.H3: true, atom.H4: true,
+	atom.Table: true, atom.Thead: true, atom.Tbody: true, atom.Tr: true, atom.Th: true, atom.Td: true,
+	atom.A: true,
+}
+
+// droppedTags are removed along with everything in them
+var droppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Form: true, atom.Button: true, atom.Svg: true, atom.Template: true,
+}
+
+// blockTags end the line they are on in the plain text of a description
+var blockTags = map[atom.Atom]bool{
+	atom.Div: true, atom.Li: true, atom.Tr: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Ul: true, atom.Ol: true, atom.Table: true,
+}
+
+var (
+	spaceRun     = regexp.MustCompile(`[ \t\f\r\x{00a0}]+`)
+	paragraphGap = regexp.MustCompile(`\n{3,}`)
+)
+
+// descriptionText turns the description element into plain text, a <br> or block ends a line and
+// a <p> or blank line between lines ends a paragraph, paragraphs are separated by an empty line
+func descriptionText(s *goquery.Selection) string {
+	var b strings.Builder
+	for _, node := range s.Nodes {
+		writeDescriptionText(&b, node)
+		b.WriteString("\n\n")
+	}
+	return normalizeDescription(b.String())
+}
+
+func writeDescriptionText(b *strings.Builder, node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		b.WriteString(strings.ReplaceAll(node.Data, "\n", " "))
+		return
+	case html.ElementNode:
+		if droppedTags[node.DataAtom] {
+			return
+		}
+		if node.DataAtom == atom.Br {
+			b.WriteString("\n")
+			return
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeDescriptionText(b, child)
+	}
+	if node.Type == html.ElementNode {
+		if node.DataAtom == atom.P {
+			b.WriteString("\n\n")
+		} else if blockTags[node.DataAtom] {
+			b.WriteString("\n")
+		} else if node.DataAtom == atom.Td || node.DataAtom == atom.Th {
+			b.WriteString(" ")
+		}
+	}
+}
+
+// normalizeDescription trims every line, collapses runs of spaces and keeps at most one empty
+// line between paragraphs
+func normalizeDescription(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spaceRun.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+	return strings.TrimSpace(paragraphGap.ReplaceAllString(text, "\n\n"))
+}
+
+// sanitizeDescriptionHTML keeps the formatting tags of the description element and drops scripts,
+// styles, event handlers and every attribute but the http(s) href of links
+func sanitizeDescriptionHTML(s *goquery.Selection) string {
+	var buf bytes.Buffer
+	for _, node := range s.Nodes {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			for _, clean := range sanitizeNode(child) {
+				if err := html.Render(&buf, clean); err != nil {
+					return ""
+				}
+			}
+		}
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// sanitizeNode returns the sanitized copies of node, none when it is dropped and its children
+// when its tag is not kept
+func sanitizeNode(node *html.Node) []*html.Node {
+	switch node.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: node.Data}}
+	case html.ElementNode:
+	default:
+		return nil
+	}
+	if droppedTags[node.DataAtom] {
+		return nil
+	}
+	children := []*html.Node{}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		children = append(children, sanitizeNode(child)...)
+	}
+	if !descriptionTags[node.DataAtom] {
+		return children
+	}
+
+	clean := &html.Node{Type: html.ElementNode, Data: node.Data, DataAtom: node.DataAtom}
+	if node.DataAtom == atom.A {
+		for _, attr := range node.Attr {
+			if attr.Key == "href" && isHttpUrl(attr.Val) {
+				clean.Attr = []html.Attribute{{Key: "href", Val: attr.Val}}
+			}
+		}
+	}
+	for _, child := range children {
+		clean.AppendChild(child)
+	}
+	return []*html.Node{clean}
+}
+
+func isHttpUrl(s string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(s))
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
@@ -0,0 +1,81 @@
+package gejie
+
+import (
+	"testing"
+)
+
+func TestDescriptionText(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "Line breaks and paragraphs",
+			html:     `<p class="d">Primera línea.<br>Segunda   línea.<br><br>Nuevo párrafo.</p>`,
+			expected: "Primera línea.\nSegunda línea.\n\nNuevo párrafo.",
+		},
+		{
+			name:     "Several paragraphs",
+			html:     `<div class="d"><p>Uno</p><p> Dos </p><ul><li>a</li><li>b</li></ul></div>`,
+			expected: "Uno\n\nDos\n\na\nb",
+		},
+		{
+			name:     "Scripts and buttons are dropped",
+			html:     `<div class="d">Texto<script>alert(1)</script><button>Ver descripción completa</button></div>`,
+			expected: "Texto",
+		},
+		{
+			name:     "Source newlines are spaces",
+			html:     "<p class=\"d\">una\n  frase\tlarga</p>",
+			expected: "una frase larga",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := structuredDoc(t, "<html><body>"+tt.html+"</body></html>")
+			if text := descriptionText(doc.Find(".d")); text != tt.expected {
+				t.Errorf("descriptionText() = %q, expected %q", text, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSanitizeDescriptionHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "Formatting is kept without attributes",
+			html:     `<div class="d"><p class="x" style="color:red">Hola <strong onclick="x()">mundo</strong><br></p></div>`,
+			expected: `<p>Hola <strong>mundo</strong><br/></p>`,
+		},
+		{
+			name:     "Unknown tags are unwrapped",
+			html:     `<div class="d"><span><font>texto</font></span></div>`,
+			expected: `texto`,
+		},
+		{
+			name:     "Scripts, iframes and buttons are dropped",
+			html:     `<div class="d">a<script>alert(1)</script><iframe src="x"></iframe><button>Ver más</button></div>`,
+			expected: `a`,
+		},
+		{
+			name:     "Only http links keep their href",
+			html:     `<div class="d"><a href="https://example.com" target="_blank">ok</a><a href="javascript:alert(1)">no</a></div>`,
+			expected: `<a href="https://example.com">ok</a><a>no</a>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := structuredDoc(t, "<html><body>"+tt.html+"</body></html>")
+			if sanitized := sanitizeDescriptionHTML(doc.Find(".d")); sanitized != tt.expected {
+				t.Errorf("sanitizeDescriptionHTML() = %q, expected %q", sanitized, tt.expected)
+			}
+		})
+	}
+}
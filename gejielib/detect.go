@@ -0,0 +1,147 @@
+package gejie
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// PageOutcome is what a loaded page turned out to be, the names match FailureKind
+type PageOutcome string
+
+const (
+	OutcomeOK            PageOutcome = "ok"
+	OutcomeBlocked       PageOutcome = "blocked"
+	OutcomeCaptcha       PageOutcome = "captcha"
+	OutcomeLoginRequired PageOutcome = "login-required"
+	OutcomeNotFound      PageOutcome = "not-found"
+	OutcomeListingPaused PageOutcome = "listing-paused"
+)
+
+// PageSnapshot is what the detector looks at of a loaded page
+type PageSnapshot struct {
+	Status int
+	Url    string
+	Title  string
+	Text   string
+	// Banner is the status message of a product page, where paused and closed listings say so
+	Banner string
+}
+
+// interstitials are short, phrases only checked on short pages so product descriptions
+// mentioning e.g. "captcha" are not taken for one
+const interstitialMaxText = 3000
+
+var (
+	captchaUrlMarkers = []string{"/gz/account-verification", "captcha", "/web/passport/zp/verify", "/web/common/security-check"}
+	captchaPhrases    = []string{"confirma que eres humano", "no soy un robot", "¿eres un robot?", "eres humano", "recaptcha", "hcaptcha", "安全验证", "请完成验证", "滑块验证"}
+	loginUrlMarkers   = []string{"/jms/", "/lgz/login", "/login", "/web/user/"}
+	loginPhrases      = []string{"ingresa a tu cuenta", "ingresa tu e-mail, teléfono o usuario", "para continuar, ingresa", "扫码登录", "登录/注册", "请登录"}
+	blockedPhrases    = []string{"access denied", "request blocked", "demasiadas solicitudes", "acceso denegado", "您的访问行为异常", "访问异常", "ip存在异常"}
+	notFoundPhrases   = []string{"parece que esta página no existe", "no hay publicaciones que coincidan", "no encontramos publicaciones", "页面不存在", "您访问的页面不存在"}
+	pausedPhrases     = []string{"publicación pausada", "esta publicación está pausada", "publicación finalizada", "esta publicación ya no está disponible", "该职位已关闭", "职位已下线"}
+)
+
+// ClassifyPage tells a usable page apart from the block, captcha, login and missing pages
+// meli and zhipin serve instead of the content
+func ClassifyPage(s PageSnapshot) PageOutcome {
+	url := strings.ToLower(s.Url)
+	title := strings.ToLower(s.Title)
+	text := strings.ToLower(s.Text)
+	banner := strings.ToLower(s.Banner)
+	short := utf8.RuneCountInString(text) <= interstitialMaxText
+	// title phrases count on any page, text phrases only on short ones
+	says := func(phrases []string) bool {
+		return containsAny(title, phrases) || (short && containsAny(text, phrases))
+	}
+
+	switch {
+	case s.Status == 404 || s.Status == 410:
+		return OutcomeNotFound
+	case containsAny(url, captchaUrlMarkers) || says(captchaPhrases):
+		return OutcomeCaptcha
+	case containsAny(url, loginUrlMarkers) || says(loginPhrases):
+		return OutcomeLoginRequired
+	case s.Status == 403 || s.Status == 429 || says(blockedPhrases):
+		return OutcomeBlocked
+	case says(notFoundPhrases):
+		return OutcomeNotFound
+	case isItemPage(s.Url) && (containsAny(title, pausedPhrases) || containsAny(banner, pausedPhrases)):
+		// the paused banner sits on top of a full product page, descriptions and search pages
+		// quoting the phrases are left alone
+		return OutcomeListingPaused
+	}
+	return OutcomeOK
+}
+
+// isItemPage tells whether rawUrl is a meli product or a zhipin job page, the only pages that can be paused
+func isItemPage(rawUrl string) bool {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	return meliItemRegex.MatchString(parsed.Path) || strings.HasPrefix(parsed.Path, "/job_detail/")
+}
+
+// pageSite is the site whose selector pack applies to rawUrl
+func pageSite(rawUrl string) Site {
+	if parsed, err := url.Parse(rawUrl); err == nil && strings.HasSuffix(parsed.Hostname(), "zhipin.com") {
+		return SiteZhipin
+	}
+	return SiteMeli
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the error of a page with this outcome, nil when it is ok
+func (o PageOutcome) Err(url string) error {
+	var kind error
+	switch o {
+	case OutcomeBlocked:
+		kind = ErrBlocked
+	case OutcomeCaptcha:
+		kind = ErrCaptcha
+	case OutcomeLoginRequired:
+		kind = ErrLoginRequired
+	case OutcomeNotFound:
+		kind = ErrNotFound
+	case OutcomeListingPaused:
+		kind = ErrListingPaused
+	default:
+		return nil
+	}
+	return newScrapeError(kind, url, "detect page", nil)
+}
+
+// detectPage classifies the page a navigation to url loaded, resp may be nil. server errors the
+// detector has no outcome for are still reported by statusError
+func detectPage(page playwright.Page, resp playwright.Response, url string) error {
+	snapshot := PageSnapshot{Url: page.URL()}
+	if resp != nil {
+		snapshot.Status = resp.Status()
+	}
+	// a page that cannot be read is left to the selectors that follow
+	snapshot.Title, _ = page.Title()
+	snapshot.Text, _ = page.Locator("body").InnerText(playwright.LocatorInnerTextOptions{
+		Timeout: playwright.Float(2000),
+	})
+	if isItemPage(snapshot.Url) {
+		banner := selectorsFor(pageSite(snapshot.Url), snapshot.Url).Locator(page, SelectorListingStatus).First()
+		if count, err := banner.Count(); err == nil && count > 0 {
+			snapshot.Banner, _ = banner.InnerText(playwright.LocatorInnerTextOptions{Timeout: playwright.Float(2000)})
+		}
+	}
+	if err := ClassifyPage(snapshot).Err(url); err != nil {
+		return err
+	}
+	return statusError(url, snapshot.Status)
+}
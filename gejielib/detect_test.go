@@ -0,0 +1,137 @@
+package gejie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestClassifyPage(t *testing.T) {
+	productText := "Teclado Mecánico Redragon Kumara K552 Rgb S/ 159 " + strings.Repeat("descripción larga del producto, no es un captcha ni recaptcha. ", 80)
+	tests := []struct {
+		name     string
+		snapshot PageSnapshot
+		expected PageOutcome
+	}{
+		{
+			name:     "Product page",
+			snapshot: PageSnapshot{Status: 200, Url: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-_JM", Title: "Teclado Mecánico | MercadoLibre", Text: productText},
+			expected: OutcomeOK,
+		},
+		{
+			name:     "Meli account verification redirect",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.mercadolibre.com.pe/gz/account-verification?go=https%3A%2F%2Farticulo.mercadolibre.com.pe", Title: "Mercado Libre"},
+			expected: OutcomeCaptcha,
+		},
+		{
+			name:     "Are you a robot page",
+			snapshot: PageSnapshot{Status: 403, Url: "https://listado.mercadolibre.com.pe/teclado", Title: "Mercado Libre", Text: "¿Eres un robot? Confirma que eres humano para continuar"},
+			expected: OutcomeCaptcha,
+		},
+		{
+			name:     "Meli login wall",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.mercadolibre.com.pe/jms/mpe/lgz/login?platform_id=ML", Title: "Mercado Libre", Text: "Ingresa tu e-mail, teléfono o usuario de Mercado Libre"},
+			expected: OutcomeLoginRequired,
+		},
+		{
+			name:     "Zhipin login page",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.zhipin.com/job_detail/abc.html", Title: "BOSS直聘", Text: "扫码登录 APP扫码"},
+			expected: OutcomeLoginRequired,
+		},
+		{
+			name:     "Zhipin security check",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.zhipin.com/web/passport/zp/verify.html?callbackUrl=abc", Title: "BOSS直聘", Text: "请完成验证"},
+			expected: OutcomeCaptcha,
+		},
+		{
+			name:     "Too many requests",
+			snapshot: PageSnapshot{Status: 429, Url: "https://listado.mercadolibre.com.pe/teclado"},
+			expected: OutcomeBlocked,
+		},
+		{
+			name:     "Zhipin abnormal access",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.zhipin.com/job_detail/abc.html", Text: "您的访问行为异常，请稍后再试"},
+			expected: OutcomeBlocked,
+		},
+		{
+			name:     "Http not found",
+			snapshot: PageSnapshot{Status: 404, Url: "https://articulo.mercadolibre.com.pe/MPE-1-gone-_JM", Text: "¿Eres un robot?"},
+			expected: OutcomeNotFound,
+		},
+		{
+			name:     "Empty listado",
+			snapshot: PageSnapshot{Status: 200, Url: "https://listado.mercadolibre.com.pe/zzzqqq", Text: "No hay publicaciones que coincidan con tu búsqueda."},
+			expected: OutcomeNotFound,
+		},
+		{
+			name:     "Paused listing on a full product page",
+			snapshot: PageSnapshot{Status: 200, Url: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-_JM", Text: "Publicación pausada " + productText, Banner: "Publicación pausada"},
+			expected: OutcomeListingPaused,
+		},
+		{
+			name:     "Paused listing title",
+			snapshot: PageSnapshot{Status: 200, Url: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-_JM", Title: "Publicación finalizada | MercadoLibre", Text: productText},
+			expected: OutcomeListingPaused,
+		},
+		{
+			name:     "Description quoting a paused phrase",
+			snapshot: PageSnapshot{Status: 200, Url: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-_JM", Text: productText + " si la publicación finalizada no se repone, escríbenos"},
+			expected: OutcomeOK,
+		},
+		{
+			name:     "Search page quoting a paused phrase",
+			snapshot: PageSnapshot{Status: 200, Url: "https://listado.mercadolibre.com.pe/teclado", Title: "Publicación finalizada | MercadoLibre", Text: "Publicación pausada", Banner: "Publicación pausada"},
+			expected: OutcomeOK,
+		},
+		{
+			name:     "Closed zhipin job",
+			snapshot: PageSnapshot{Status: 200, Url: "https://www.zhipin.com/job_detail/abc.html", Text: "该职位已关闭", Banner: "该职位已关闭"},
+			expected: OutcomeListingPaused,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ClassifyPage(tt.snapshot)
+			if result != tt.expected {
+				t.Errorf("ClassifyPage() = %q, expected %q", result, tt.expected)
+			}
+		})
+	}
+}
+
+// the description of this fixture quotes the paused message of meli while the listing is active
+const fixtureQuotingPausedUrl = "https://articulo.mercadolibre.com.pe/MPE-600000004-teclado-mecanico-royal-kludge-rk61-_JM"
+
+func TestClassifyFixtureQuotingPausedPhrase(t *testing.T) {
+	body, err := NewFixtureStore(fixtureTestDir).Load(fixtureQuotingPausedUrl)
+	if err != nil {
+		t.Fatalf("could not load fixture: %v", err)
+	}
+	snapshot := PageSnapshot{Status: 200, Url: fixtureQuotingPausedUrl, Title: "Teclado Mecánico Royal Kludge Rk61 | MercadoLibre", Text: string(body)}
+	if !strings.Contains(strings.ToLower(snapshot.Text), "publicación está pausada") {
+		t.Fatal("the fixture no longer quotes the paused message")
+	}
+	if outcome := ClassifyPage(snapshot); outcome != OutcomeOK {
+		t.Errorf("ClassifyPage() = %q, expected a page whose body quotes the message to be ok", outcome)
+	}
+	snapshot.Banner = "Publicación pausada"
+	if outcome := ClassifyPage(snapshot); outcome != OutcomeListingPaused {
+		t.Errorf("ClassifyPage() with the banner = %q, expected %q", outcome, OutcomeListingPaused)
+	}
+}
+
+func TestPageOutcomeErr(t *testing.T) {
+	if err := OutcomeOK.Err("https://example.com"); err != nil {
+		t.Errorf("OutcomeOK.Err() = %v, expected nil", err)
+	}
+	for _, outcome := range []PageOutcome{OutcomeBlocked, OutcomeCaptcha, OutcomeLoginRequired, OutcomeNotFound, OutcomeListingPaused} {
+		err := outcome.Err("https://example.com")
+		if kind := FailureKind(err); kind != string(outcome) {
+			t.Errorf("FailureKind(%q.Err()) = %q, expected the outcome name", outcome, kind)
+		}
+	}
+	if err := OutcomeCaptcha.Err("https://example.com"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("a captcha error does not match ErrBlocked, proxies would not be quarantined for it")
+	}
+}
@@ -0,0 +1,401 @@
+package gejie
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+// SelectorHealth is how a selector pack field fared on a page
+type SelectorHealth string
+
+const (
+	HealthOK SelectorHealth = "ok"
+	// HealthMissing means no selector of the field's chain matched
+	HealthMissing SelectorHealth = "missing"
+	// HealthEmpty means an element matched but had no text or attribute to read
+	HealthEmpty SelectorHealth = "empty"
+	// HealthUnparseable means the value read could not be parsed, e.g. a price without digits
+	HealthUnparseable SelectorHealth = "unparseable"
+	// HealthUnloaded means the page the field is on could not be loaded
+	HealthUnloaded SelectorHealth = "unloaded"
+)
+
+// PageKind tells which fields of a selector pack a page is checked for
+type PageKind string
+
+const (
+	PageProduct PageKind = "product"
+	PageListing PageKind = "listing"
+	// PageReviews is a page of the reviews modal a product page embeds
+	PageReviews PageKind = "reviews"
+)
+
+// DocumentLoader loads a page for the selector checks, HTTPFetcher and BrowserManager are loaders
+type DocumentLoader interface {
+	Document(ctx context.Context, url string) (*goquery.Document, string, error)
+}
+
+// fieldRule describes how a field is read and parsed. a required field must be ok on every page,
+// an optional one, like the reviews of a product nobody reviewed yet, on at least one page. a rare
+// field, like the variant pickers of a product sold in a single version, may be missing from every
+// page but must be ok wherever it matches
+type fieldRule struct {
+	field    string
+	required bool
+	rare     bool
+	// within names the field the element is looked up in, the whole page when empty
+	within string
+	read   func(s *goquery.Selection) string
+	parse  func(value string) error
+}
+
+var productFieldRules = []fieldRule{
+	{field: SelectorTitle, required: true, read: textOf},
+	{field: SelectorPriceFraction, required: true, read: textOf, parse: func(v string) error {
+		_, err := utils.ParseAmountCents(v)
+		return err
+	}},
+	{field: SelectorPriceCents, read: textOf, parse: parseInt},
+	{field: SelectorPriceCurrency, read: textOf},
+	{field: SelectorReviews, read: textOf},
+	{field: SelectorReviewsRating, within: SelectorReviews, read: textOf, parse: func(v string) error {
+		_, err := strconv.ParseFloat(v, 32)
+		return err
+	}},
+	{field: SelectorReviewsCount, within: SelectorReviews, read: textOf, parse: func(v string) error {
+		return parseInt(cleanReviewCount(v))
+	}},
+	{field: SelectorSold, read: textOf},
+	{field: SelectorImages, read: imageSrc},
+	{field: SelectorDescription, read: textOf},
+	{field: SelectorSpecRows, within: SelectorSpecSections, read: textOf},
+	{field: SelectorStoreName, read: textOf},
+	{field: SelectorStoreUrl, read: attrOf("href"), parse: parseAbsoluteUrl},
+	{field: SelectorStoreLogo, read: func(s *goquery.Selection) string { return s.AttrOr("data-src", s.AttrOr("src", "")) }},
+	{field: SelectorPriceOriginal, read: textOf, parse: parseAmount},
+	{field: SelectorMoneyFraction, within: SelectorPriceOriginal, read: textOf, parse: func(v string) error {
+		_, err := utils.ParseAmountCents(v)
+		return err
+	}},
+	{field: SelectorMoneyCents, rare: true, within: SelectorPriceOriginal, read: textOf, parse: parseInt},
+	{field: SelectorPriceDiscount, read: textOf, parse: func(v string) error {
+		if _, ok := parseDiscountPercent(v); !ok {
+			return fmt.Errorf("%q has no percent", v)
+		}
+		return nil
+	}},
+	{field: SelectorPriceInstallments, read: joinedText, parse: func(v string) error {
+		if parseInstallments(v) == nil {
+			return fmt.Errorf("%q has no cuota count", v)
+		}
+		return nil
+	}},
+	{field: SelectorPricePromotions, read: joinedText},
+	{field: SelectorAvailableQuantity, read: textOf, parse: func(v string) error {
+		if parseAvailableQuantity(v) == nil {
+			return fmt.Errorf("%q shows no stock", v)
+		}
+		return nil
+	}},
+	{field: SelectorListingStatus, rare: true, read: joinedText},
+	{field: SelectorWarranty, read: joinedText},
+	{field: SelectorShipping, read: joinedText},
+	{field: SelectorShippingFull, read: nodeName},
+	{field: SelectorShippingInternational, rare: true, read: nodeName},
+	{field: SelectorShippingPickup, rare: true, read: joinedText},
+	{field: SelectorVariationPickers, rare: true, read: nodeName},
+	{field: SelectorVariationLabel, rare: true, within: SelectorVariationPickers, read: textOf},
+	{field: SelectorVariationSelected, rare: true, within: SelectorVariationPickers, read: textOf},
+	{field: SelectorVariationOptions, rare: true, within: SelectorVariationPickers, read: nodeName},
+	{field: SelectorBuyButton, read: nodeName},
+	{field: SelectorReviewsFrame, read: reviewsFrameSrc},
+}
+
+var listingFieldRules = []fieldRule{
+	{field: SelectorProductLinks, required: true, read: attrOf("href"), parse: func(v string) error {
+		if len(canonicalProductLinks("https://listado.mercadolibre.com", []string{v})) == 0 {
+			return fmt.Errorf("%q is not a product link", v)
+		}
+		return nil
+	}},
+	{field: SelectorNextPage, read: attrOf("href")},
+	// the card fields are looked up on the whole page, the first card may not show every one of them
+	{field: SelectorListingCards, read: nodeName},
+	{field: SelectorCardPrice, read: textOf, parse: parseAmount},
+	{field: SelectorCardPriceOriginal, read: textOf, parse: parseAmount},
+	{field: SelectorCardPriceDiscount, read: textOf, parse: func(v string) error {
+		if _, ok := parseDiscountPercent(v); !ok {
+			return fmt.Errorf("%q has no percent", v)
+		}
+		return nil
+	}},
+	{field: SelectorCardInstallments, read: joinedText, parse: func(v string) error {
+		if parseInstallments(v) == nil {
+			return fmt.Errorf("%q has no cuota count", v)
+		}
+		return nil
+	}},
+	{field: SelectorCardPromotions, read: joinedText},
+	{field: SelectorCardShipping, read: joinedText},
+	{field: SelectorCardShippingFull, read: nodeName},
+	{field: SelectorCardShippingInternational, rare: true, read: nodeName},
+}
+
+var reviewsFieldRules = []fieldRule{
+	{field: SelectorReviewItems, required: true, read: nodeName},
+	{field: SelectorReviewRating, within: SelectorReviewItems, read: textOf, parse: func(v string) error {
+		if parseReviewRating(v) == 0 {
+			return fmt.Errorf("%q has no rating", v)
+		}
+		return nil
+	}},
+	// relative dates like "Hace 2 días" are kept as text, so the date is not parsed
+	{field: SelectorReviewDate, within: SelectorReviewItems, read: textOf},
+	{field: SelectorReviewText, within: SelectorReviewItems, read: textOf},
+	{field: SelectorReviewVariant, rare: true, within: SelectorReviewItems, read: joinedText},
+	{field: SelectorReviewHelpful, within: SelectorReviewItems, read: joinedText},
+	{field: SelectorReviewImages, rare: true, within: SelectorReviewItems, read: imageSrc},
+	{field: SelectorReviewsNextPage, rare: true, read: attrOf("href")},
+}
+
+// fieldRules are the rules pages of each kind are checked with
+var fieldRules = map[PageKind][]fieldRule{
+	PageProduct: productFieldRules,
+	PageListing: listingFieldRules,
+	PageReviews: reviewsFieldRules,
+}
+
+func textOf(s *goquery.Selection) string {
+	return strings.TrimSpace(s.Text())
+}
+
+// joinedText reads the text of elements that nest several lines, like a delivery promise
+func joinedText(s *goquery.Selection) string {
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
+
+// nodeName reads the tag of elements that are only checked for being there, like a badge icon
+func nodeName(s *goquery.Selection) string {
+	return goquery.NodeName(s)
+}
+
+// reviewsFrameSrc reads the url of the reviews modal like reviewsUrlFromHTML does
+func reviewsFrameSrc(s *goquery.Selection) string {
+	src := s.AttrOr("src", "")
+	if src == "" || src == "about:blank" {
+		src = s.AttrOr("data-src", s.AttrOr("href", ""))
+	}
+	return strings.TrimSpace(src)
+}
+
+func attrOf(name string) func(s *goquery.Selection) string {
+	return func(s *goquery.Selection) string {
+		return strings.TrimSpace(s.AttrOr(name, ""))
+	}
+}
+
+// imageSrc reads an image url like productImagesFromHTML does
+func imageSrc(s *goquery.Selection) string {
+	src := s.AttrOr("src", "")
+	if src == "" || strings.HasPrefix(src, "data:") {
+		src = s.AttrOr("data-src", s.AttrOr("data-zoom", ""))
+	}
+	return src
+}
+
+func parseInt(v string) error {
+	_, err := strconv.Atoi(strings.TrimSpace(v))
+	return err
+}
+
+func parseAmount(v string) error {
+	if _, ok := parseAmountText(v); !ok {
+		return fmt.Errorf("%q has no amount", v)
+	}
+	return nil
+}
+
+func parseAbsoluteUrl(v string) error {
+	parsed, err := url.Parse(v)
+	if err != nil {
+		return err
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", v)
+	}
+	return nil
+}
+
+// FieldCheck is the health of one field on one page
+type FieldCheck struct {
+	Field    string
+	Required bool
+	Health   SelectorHealth
+	// Selector is the selector of the chain that matched, empty when none did
+	Selector CssSelector
+	Value    string
+	Err      error
+}
+
+// PageCheck holds the field checks of one page, Err is set when the page could not be loaded
+type PageCheck struct {
+	Url    string
+	Kind   PageKind
+	Fields []FieldCheck
+	Err    error
+}
+
+// CheckSelectors evaluates the active meli selectors of kind's fields on the page
+func CheckSelectors(doc *goquery.Document, pageUrl string, kind PageKind) []FieldCheck {
+	selectors := selectorsFor(SiteMeli, pageUrl)
+	checks := []FieldCheck{}
+	for _, rule := range fieldRules[kind] {
+		check := FieldCheck{Field: rule.field, Required: rule.required, Health: HealthMissing}
+		var root finder = doc
+		if rule.within != "" {
+			if within := selectors.Find(doc, rule.within).First(); within.Length() > 0 {
+				root = within
+			}
+		}
+		for _, selector := range selectors.Chain(rule.field) {
+			found := root.Find(string(selector)).First()
+			if found.Length() == 0 {
+				continue
+			}
+			check.Selector = selector
+			check.Value = rule.read(found)
+			check.Health = HealthOK
+			if check.Value == "" {
+				check.Health = HealthEmpty
+			} else if rule.parse != nil {
+				if check.Err = rule.parse(check.Value); check.Err != nil {
+					check.Health = HealthUnparseable
+				}
+			}
+			break
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// SelectorReport is the outcome of checking the selectors on a sample of pages
+type SelectorReport struct {
+	Pages []PageCheck
+}
+
+// SelectorFailure is a field that failed its check, on Pages of the pages of its kind
+type SelectorFailure struct {
+	Kind   PageKind
+	Field  string
+	Health SelectorHealth
+	Pages  []string
+	// Err is why the page of an unloaded failure could not be loaded
+	Err error
+}
+
+// Failures lists the required fields that are not ok on some page, the optional fields that are
+// not ok on any page of their kind and the rare fields that match on some page but are not ok there,
+// a page that could not be loaded is a failure of its own
+func (r *SelectorReport) Failures() []SelectorFailure {
+	failures := []SelectorFailure{}
+	type key struct {
+		kind  PageKind
+		field string
+	}
+	rules := map[key]fieldRule{}
+	for kind, kindRules := range fieldRules {
+		for _, rule := range kindRules {
+			rules[key{kind, rule.field}] = rule
+		}
+	}
+	failed := map[key]*SelectorFailure{}
+	pagesOfKind := map[PageKind]int{}
+	order := []key{}
+	for _, page := range r.Pages {
+		if page.Err != nil {
+			failures = append(failures, SelectorFailure{Kind: page.Kind, Field: "page", Health: HealthUnloaded, Pages: []string{page.Url}, Err: page.Err})
+			continue
+		}
+		pagesOfKind[page.Kind]++
+		for _, check := range page.Fields {
+			k := key{page.Kind, check.Field}
+			if check.Health == HealthOK || (check.Health == HealthMissing && rules[k].rare) {
+				continue
+			}
+			if failed[k] == nil {
+				failed[k] = &SelectorFailure{Kind: page.Kind, Field: check.Field, Health: check.Health}
+				order = append(order, k)
+			}
+			failed[k].Pages = append(failed[k].Pages, page.Url)
+		}
+	}
+	for _, k := range order {
+		failure := failed[k]
+		if rules[k].required || rules[k].rare || len(failure.Pages) == pagesOfKind[k.kind] {
+			failures = append(failures, *failure)
+		}
+	}
+	return failures
+}
+
+// DoctorSelectors loads the listing and product pages and checks the selectors on them, the first
+// sampleProducts product links of the listings are checked along with the given products. the first
+// page of the reviews modal a product page embeds is checked too
+func DoctorSelectors(ctx context.Context, loader DocumentLoader, listings []string, products []string, sampleProducts int) (*SelectorReport, error) {
+	report := &SelectorReport{}
+	sampled := []string{}
+	for _, listingUrl := range listings {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		doc, finalUrl, err := loader.Document(ctx, listingUrl)
+		if err != nil {
+			report.Pages = append(report.Pages, PageCheck{Url: listingUrl, Kind: PageListing, Err: err})
+			continue
+		}
+		report.Pages = append(report.Pages, PageCheck{Url: listingUrl, Kind: PageListing, Fields: CheckSelectors(doc, finalUrl, PageListing)})
+		if remaining := sampleProducts - len(sampled); remaining > 0 {
+			hrefs := selectorsFor(SiteMeli, finalUrl).Find(doc, SelectorProductLinks).Map(func(_ int, s *goquery.Selection) string {
+				return s.AttrOr("href", "")
+			})
+			links := canonicalProductLinks(finalUrl, hrefs)
+			if len(links) > remaining {
+				links = links[:remaining]
+			}
+			sampled = append(sampled, links...)
+		}
+	}
+
+	for _, productUrl := range append(append([]string{}, products...), sampled...) {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		doc, finalUrl, err := loader.Document(ctx, productUrl)
+		// paused listings still render the whole product page
+		if err != nil && !pausedDocument(doc, err) {
+			report.Pages = append(report.Pages, PageCheck{Url: productUrl, Kind: PageProduct, Err: err})
+			continue
+		}
+		report.Pages = append(report.Pages, PageCheck{Url: productUrl, Kind: PageProduct, Fields: CheckSelectors(doc, finalUrl, PageProduct)})
+
+		// the url built from the item id is not checked, the product page may have no reviews
+		reviewsUrl := reviewsUrlFromHTML(doc, finalUrl)
+		if reviewsUrl == "" {
+			continue
+		}
+		doc, finalUrl, err = loader.Document(ctx, reviewsUrl)
+		if err != nil {
+			report.Pages = append(report.Pages, PageCheck{Url: reviewsUrl, Kind: PageReviews, Err: err})
+			continue
+		}
+		report.Pages = append(report.Pages, PageCheck{Url: reviewsUrl, Kind: PageReviews, Fields: CheckSelectors(doc, finalUrl, PageReviews)})
+	}
+	return report, nil
+}
@@ -0,0 +1,85 @@
+package gejie
+
+import (
+	"context"
+	"testing"
+)
+
+func newReplayDocumentLoader(t *testing.T) DocumentLoader {
+	t.Helper()
+	return newReplayHTTPFetcher(t)
+}
+
+func TestDoctorSelectorsOnFixtures(t *testing.T) {
+	report, err := DoctorSelectors(context.Background(), newReplayDocumentLoader(t), []string{fixtureSearchUrl}, nil, 3)
+	if err != nil {
+		t.Fatalf("DoctorSelectors failed: %v", err)
+	}
+	if len(report.Pages) != 4 {
+		t.Fatalf("DoctorSelectors checked %d pages, expected the listing, the 2 products it links and the reviews of the first", len(report.Pages))
+	}
+	if failures := report.Failures(); len(failures) > 0 {
+		t.Errorf("the built in selectors fail on the fixtures: %+v", failures)
+	}
+}
+
+func TestDoctorSelectorsReportsBrokenSelectors(t *testing.T) {
+	UseSelectorPacks(DefaultSelectorPacks().Override(SelectorPack{
+		Site: SiteMeli, Version: 2,
+		Fields: map[string][]CssSelector{
+			SelectorStoreName:     {"h2.renamed-store"},
+			SelectorPriceFraction: {"h1.ui-pdp-title"},
+			// a rare field that matches must be ok, the missing variant pickers are not reported
+			SelectorVariationSelected: {"img.ui-pdp-gallery__figure__image"},
+			SelectorReviewImages:      {"p.ui-review-capability-comments__comment__content"},
+		},
+	}))
+	t.Cleanup(func() { UseSelectorPacks(DefaultSelectorPacks()) })
+
+	products := []string{fixtureProductUrl, "https://articulo.mercadolibre.com.pe/MPE-1-never-recorded-_JM"}
+	report, err := DoctorSelectors(context.Background(), newReplayDocumentLoader(t), nil, products, 0)
+	if err != nil {
+		t.Fatalf("DoctorSelectors failed: %v", err)
+	}
+
+	got := map[string]SelectorHealth{}
+	for _, failure := range report.Failures() {
+		got[failure.Field] = failure.Health
+	}
+	expected := map[string]SelectorHealth{
+		"page":                    HealthUnloaded,
+		SelectorStoreName:         HealthMissing,
+		SelectorPriceFraction:     HealthUnparseable,
+		SelectorVariationSelected: HealthEmpty,
+		SelectorReviewImages:      HealthEmpty,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Failures() = %v, expected %v", got, expected)
+	}
+	for field, health := range expected {
+		if got[field] != health {
+			t.Errorf("field %s is %q, expected %q", field, got[field], health)
+		}
+	}
+}
+
+func TestCheckSelectorsEmptyFields(t *testing.T) {
+	doc := structuredDoc(t, `<html><body><h1 class="ui-pdp-title">  </h1>
+<div class="ui-pdp-header__info"><a><span class="ui-pdp-review__rating">4.5</span><span class="ui-pdp-review__amount">(muchas)</span></a></div></body></html>`)
+
+	got := map[string]SelectorHealth{}
+	for _, check := range CheckSelectors(doc, structuredPageUrl, PageProduct) {
+		got[check.Field] = check.Health
+	}
+	expected := map[string]SelectorHealth{
+		SelectorTitle:         HealthEmpty,
+		SelectorReviewsRating: HealthOK,
+		SelectorReviewsCount:  HealthUnparseable,
+		SelectorStoreName:     HealthMissing,
+	}
+	for field, health := range expected {
+		if got[field] != health {
+			t.Errorf("field %s is %q, expected %q", field, got[field], health)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// kinds of scrape failures, match them with errors.Is on any error returned by the scrapers
+var (
+	ErrNotFound      = errors.New("page not found")
+	ErrLayoutChanged = errors.New("page layout changed")
+	ErrTimeout       = errors.New("timed out")
+	ErrBlocked       = errors.New("blocked by site")
+	ErrUnavailable   = errors.New("site unavailable")
+	ErrDisallowed    = errors.New("disallowed by robots.txt")
+	ErrListingPaused = errors.New("listing paused")
+	// captchas and login walls are blocks too, they are retried and count against the proxy
+	ErrCaptcha       = fmt.Errorf("captcha challenge: %w", ErrBlocked)
+	ErrLoginRequired = fmt.Errorf("login required: %w", ErrBlocked)
+)
+
+// ScrapeError describes why scraping a single url failed
+type ScrapeError struct {
+	Url  string
+	Op   string
+	Kind error
+	Err  error
+}
+
+func (e *ScrapeError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Url, e.Kind)
+	}
+	return fmt.Sprintf("%s %s: %v: %v", e.Op, e.Url, e.Kind, e.Err)
+}
+
+func (e *ScrapeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func newScrapeError(kind error, url string, op string, err error) *ScrapeError {
+	return &ScrapeError{
+		Url:  url,
+		Op:   op,
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+// wrapBrowserError turns a playwright error into a ScrapeError, timeouts and network errors are
+// kept apart from everything else which is treated as a layout change since an expected element was not usable
+func wrapBrowserError(url string, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var scrapeErr *ScrapeError
+	if errors.As(err, &scrapeErr) {
+		return err
+	}
+	if errors.Is(err, playwright.ErrTimeout) {
+		return newScrapeError(ErrTimeout, url, op, err)
+	}
+	// chromium reports failed connections as e.g. "net::ERR_CONNECTION_RESET at https://..."
+	if strings.Contains(err.Error(), "net::ERR_") {
+		return newScrapeError(ErrUnavailable, url, op, err)
+	}
+	return newScrapeError(ErrLayoutChanged, url, op, err)
+}
+
+// statusError classifies an http status of a navigation response, returning nil for usable pages
+func statusError(url string, status int) error {
+	switch {
+	case status == 404 || status == 410:
+		return newScrapeError(ErrNotFound, url, "goto", fmt.Errorf("http status %d", status))
+	case status == 403 || status == 429:
+		return newScrapeError(ErrBlocked, url, "goto", fmt.Errorf("http status %d", status))
+	case status >= 500:
+		return newScrapeError(ErrUnavailable, url, "goto", fmt.Errorf("http status %d", status))
+	}
+	return nil
+}
+
+// IsRetriable tells whether a failed scrape may succeed when tried again later, timeouts,
+// blocks (429, captcha interstitials) and unavailable sites are retriable, while missing pages,
+// changed layouts, paused listings and cancellations are permanent
+func IsRetriable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrBlocked) || errors.Is(err, ErrUnavailable)
+}
+
+// isInterrupted tells whether err only means the run was stopped, the url was not given up on
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// ScrapeFailure records a url a batch run gave up on
+type ScrapeFailure struct {
+	Url string
+	Err error
+}
+
+// FailureKind returns a short name of the failure kind for reports
+func FailureKind(err error) string {
+	switch {
+	case errors.Is(err, ErrCaptcha):
+		return "captcha"
+	case errors.Is(err, ErrLoginRequired):
+		return "login-required"
+	case errors.Is(err, ErrListingPaused):
+		return "listing-paused"
+	case errors.Is(err, ErrNotFound):
+		return "not-found"
+	case errors.Is(err, ErrLayoutChanged):
+		return "layout-changed"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrBlocked):
+		return "blocked"
+	case errors.Is(err, ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, ErrDisallowed):
+		return "disallowed"
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,104 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+func TestWrapBrowserError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{
+			name:     "Playwright timeout is a timeout",
+			err:      fmt.Errorf("%w: %w", playwright.ErrPlaywright, playwright.ErrTimeout),
+			expected: "timeout",
+		},
+		{
+			name:     "Other playwright error is a layout change",
+			err:      fmt.Errorf("%w: strict mode violation", playwright.ErrPlaywright),
+			expected: "layout-changed",
+		},
+		{
+			name:     "Connection error is unavailable",
+			err:      fmt.Errorf("%w: net::ERR_CONNECTION_RESET at https://example.com", playwright.ErrPlaywright),
+			expected: "unavailable",
+		},
+		{
+			name:     "Scrape error keeps its kind",
+			err:      newScrapeError(ErrBlocked, "https://example.com", "goto", nil),
+			expected: "blocked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wrapBrowserError("https://example.com", "goto", tt.err)
+			if kind := FailureKind(err); kind != tt.expected {
+				t.Errorf("FailureKind(wrapBrowserError(%v)) = %q, expected %q", tt.err, kind, tt.expected)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("wrapBrowserError(%v) does not wrap the original error", tt.err)
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status   int
+		expected string
+	}{
+		{200, ""},
+		{301, ""},
+		{404, "not-found"},
+		{410, "not-found"},
+		{403, "blocked"},
+		{429, "blocked"},
+		{500, "unavailable"},
+		{503, "unavailable"},
+	}
+
+	for _, tt := range tests {
+		err := statusError("https://example.com", tt.status)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("statusError(%d) = %v, expected nil", tt.status, err)
+			}
+			continue
+		}
+		if kind := FailureKind(err); kind != tt.expected {
+			t.Errorf("FailureKind(statusError(%d)) = %q, expected %q", tt.status, kind, tt.expected)
+		}
+	}
+}
+
+func TestIsRetriable(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{newScrapeError(ErrTimeout, "https://example.com", "goto", nil), true},
+		{statusError("https://example.com", 429), true},
+		{statusError("https://example.com", 503), true},
+		{statusError("https://example.com", 404), false},
+		{OutcomeCaptcha.Err("https://example.com"), true},
+		{OutcomeLoginRequired.Err("https://example.com"), true},
+		{OutcomeListingPaused.Err("https://example.com"), false},
+		{newScrapeError(ErrLayoutChanged, "https://example.com", "find product name", nil), false},
+		{fmt.Errorf("gave up: %w", context.Canceled), false},
+		{errors.New("unknown"), false},
+	}
+
+	for _, tt := range tests {
+		if retriable := IsRetriable(tt.err); retriable != tt.expected {
+			t.Errorf("IsRetriable(%v) = %t, expected %t", tt.err, retriable, tt.expected)
+		}
+	}
+}
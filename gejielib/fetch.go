@@ -0,0 +1,208 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Fetcher scrapes meli search and product pages, from their static html or in a browser
+type Fetcher interface {
+	// ListingCards collects up to maxItems product cards of the search at searchUrl that keep keeps,
+	// across its pages
+	ListingCards(ctx context.Context, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error)
+	Product(ctx context.Context, url string) (*MeliProduct, error)
+	ProductImages(ctx context.Context, url string) ([]string, error)
+	// RateLimiter returns the limiter every fetch waits on, nil when fetches are not limited
+	RateLimiter() *RateLimiter
+	// Offline tells whether pages come from saved fixtures instead of the network
+	Offline() bool
+	Close()
+}
+
+// Engine selects the Fetcher of a run
+type Engine string
+
+const (
+	// EngineHTTP only downloads html, no browser is needed
+	EngineHTTP Engine = "http"
+	// EngineBrowser loads every page in playwright
+	EngineBrowser Engine = "browser"
+	// EngineAuto downloads html first and loads the page in a browser when the html is incomplete
+	EngineAuto Engine = "auto"
+)
+
+var Engines = []Engine{EngineHTTP, EngineBrowser, EngineAuto}
+
+func ParseEngine(s string) (Engine, error) {
+	for _, engine := range Engines {
+		if string(engine) == strings.ToLower(s) {
+			return engine, nil
+		}
+	}
+	return "", fmt.Errorf("unknown engine %q, expected http, browser or auto", s)
+}
+
+// NewFetcher returns the fetcher of engine using opts, closing it closes any browser it started.
+// the auto engine only starts a browser once a page needs one
+func NewFetcher(engine Engine, opts *BrowserOptions) (Fetcher, error) {
+	if opts == nil {
+		opts = DefaultBrowserOptions()
+	}
+	switch engine {
+	case EngineHTTP:
+		return NewHTTPFetcher(opts), nil
+	case EngineBrowser:
+		bm, err := NewBrowserManager(opts)
+		if err != nil {
+			return nil, fmt.Errorf("could not start browser: %w", err)
+		}
+		return &BrowserFetcher{bm: bm, owned: true}, nil
+	case EngineAuto:
+		return NewAutoFetcher(NewHTTPFetcher(opts), func() (*BrowserManager, error) {
+			return NewBrowserManager(opts)
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown engine %q", engine)
+}
+
+// BrowserFetcher scrapes pages in the browser of a BrowserManager
+type BrowserFetcher struct {
+	bm *BrowserManager
+	// owned browsers are closed with the fetcher
+	owned bool
+}
+
+// NewBrowserFetcher scrapes in bm, closing the fetcher leaves bm running
+func NewBrowserFetcher(bm *BrowserManager) *BrowserFetcher {
+	return &BrowserFetcher{bm: bm}
+}
+
+func (f *BrowserFetcher) ListingCards(ctx context.Context, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error) {
+	return collectSearchCards(ctx, f.bm, searchUrl, maxItems, keep)
+}
+
+func (f *BrowserFetcher) Product(ctx context.Context, url string) (*MeliProduct, error) {
+	return scrapeProductPage(ctx, f.bm, url)
+}
+
+func (f *BrowserFetcher) ProductImages(ctx context.Context, url string) ([]string, error) {
+	return ScrapeProductImages(ctx, f.bm, url)
+}
+
+func (f *BrowserFetcher) RateLimiter() *RateLimiter {
+	return f.bm.RateLimiter()
+}
+
+func (f *BrowserFetcher) Offline() bool {
+	return f.bm.opts.FixtureMode == FixtureReplay
+}
+
+func (f *BrowserFetcher) Close() {
+	if f.owned {
+		f.bm.Close()
+	}
+}
+
+// AutoFetcher scrapes the static html and falls back to a browser for pages whose html is
+// incomplete or blocked, e.g. a gallery rendered by scripts or a challenge only a browser passes
+type AutoFetcher struct {
+	static       *HTTPFetcher
+	startBrowser func() (*BrowserManager, error)
+
+	once       sync.Once
+	browser    *BrowserFetcher
+	browserErr error
+}
+
+// NewAutoFetcher falls back to the browser startBrowser starts on first use, closing the fetcher closes it
+func NewAutoFetcher(static *HTTPFetcher, startBrowser func() (*BrowserManager, error)) *AutoFetcher {
+	return &AutoFetcher{static: static, startBrowser: startBrowser}
+}
+
+func (f *AutoFetcher) fallback() (*BrowserFetcher, error) {
+	f.once.Do(func() {
+		bm, err := f.startBrowser()
+		if err != nil {
+			f.browserErr = fmt.Errorf("could not start browser: %w", err)
+			return
+		}
+		f.browser = &BrowserFetcher{bm: bm, owned: true}
+	})
+	return f.browser, f.browserErr
+}
+
+// shouldFallBack tells whether a static failure may go away in a browser, missing pages and
+// server errors will not
+func shouldFallBack(err error) bool {
+	return errors.Is(err, ErrLayoutChanged) || errors.Is(err, ErrBlocked)
+}
+
+func (f *AutoFetcher) ListingCards(ctx context.Context, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error) {
+	cards, err := f.static.ListingCards(ctx, searchUrl, maxItems, keep)
+	if err == nil || !shouldFallBack(err) || len(cards) > 0 {
+		return cards, err
+	}
+	log.Printf("static html of %s is not usable (%s), loading it in the browser", searchUrl, FailureKind(err))
+	browser, browserErr := f.fallback()
+	if browserErr != nil {
+		return nil, errors.Join(err, browserErr)
+	}
+	return browser.ListingCards(ctx, searchUrl, maxItems, keep)
+}
+
+func (f *AutoFetcher) Product(ctx context.Context, url string) (*MeliProduct, error) {
+	product, missing, err := f.static.product(ctx, url)
+	if err == nil && len(missing) == 0 {
+		return product, nil
+	}
+	if err != nil && !shouldFallBack(err) {
+		return nil, err
+	}
+	reason := FailureKind(err)
+	if err == nil {
+		reason = "no " + strings.Join(missing, ", ")
+	}
+	log.Printf("static html of %s is not usable (%s), loading it in the browser", url, reason)
+	browser, browserErr := f.fallback()
+	if browserErr != nil {
+		if err == nil {
+			// the incomplete product beats none at all
+			return product, nil
+		}
+		return nil, errors.Join(err, browserErr)
+	}
+	return browser.Product(ctx, url)
+}
+
+func (f *AutoFetcher) ProductImages(ctx context.Context, url string) ([]string, error) {
+	images, err := f.static.ProductImages(ctx, url)
+	if err == nil || !shouldFallBack(err) {
+		return images, err
+	}
+	browser, browserErr := f.fallback()
+	if browserErr != nil {
+		return nil, errors.Join(err, browserErr)
+	}
+	return browser.ProductImages(ctx, url)
+}
+
+func (f *AutoFetcher) RateLimiter() *RateLimiter {
+	return f.static.RateLimiter()
+}
+
+func (f *AutoFetcher) Offline() bool {
+	return f.static.Offline()
+}
+
+func (f *AutoFetcher) Close() {
+	f.static.Close()
+	// a browser starting now waits for once, so it is closed too
+	f.once.Do(func() {})
+	if f.browser != nil {
+		f.browser.Close()
+	}
+}
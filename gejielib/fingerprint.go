@@ -0,0 +1,151 @@
+package gejie
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// Fingerprint is what a browser context tells sites about itself, the fields of a profile are kept
+// consistent with each other, e.g. a Lima timezone goes with an es-PE locale
+type Fingerprint struct {
+	Name           string
+	UserAgent      string
+	ViewportWidth  int
+	ViewportHeight int
+	Locale         string
+	TimezoneID     string
+	AcceptLanguage string
+}
+
+// Fingerprints are the named profiles selectable with --fingerprints, all of them chromium based
+// since a safari or firefox user agent on chromium is easy to spot
+var Fingerprints = map[string]Fingerprint{
+	"chrome-win-pe": {
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+		ViewportWidth:  1920,
+		ViewportHeight: 1080,
+		Locale:         "es-PE",
+		TimezoneID:     "America/Lima",
+		AcceptLanguage: "es-PE,es;q=0.9,en;q=0.8",
+	},
+	"chrome-mac-pe": {
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+		ViewportWidth:  1440,
+		ViewportHeight: 900,
+		Locale:         "es-PE",
+		TimezoneID:     "America/Lima",
+		AcceptLanguage: "es-PE,es-419;q=0.9,es;q=0.8",
+	},
+	"chrome-win-mx": {
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/130.0.0.0 Safari/537.36",
+		ViewportWidth:  1366,
+		ViewportHeight: 768,
+		Locale:         "es-MX",
+		TimezoneID:     "America/Mexico_City",
+		AcceptLanguage: "es-MX,es;q=0.9,en;q=0.8",
+	},
+	"edge-win-mx": {
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36 Edg/131.0.0.0",
+		ViewportWidth:  1536,
+		ViewportHeight: 864,
+		Locale:         "es-MX",
+		TimezoneID:     "America/Mexico_City",
+		AcceptLanguage: "es-MX,es;q=0.9,en-US;q=0.8,en;q=0.7",
+	},
+	"chrome-win-cn": {
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+		ViewportWidth:  1920,
+		ViewportHeight: 1080,
+		Locale:         "zh-CN",
+		TimezoneID:     "Asia/Shanghai",
+		AcceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8",
+	},
+}
+
+// default profiles of each site when none are selected
+var (
+	DefaultMeliFingerprints   = []string{"chrome-win-pe", "chrome-mac-pe", "chrome-win-mx", "edge-win-mx"}
+	DefaultZhipinFingerprints = []string{"chrome-win-cn"}
+)
+
+// FingerprintNames returns the names of the known profiles, sorted
+func FingerprintNames() []string {
+	names := []string{}
+	for name := range Fingerprints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// contextOptions returns the context options presenting the profile, userAgent overrides the
+// profile's user agent when not empty
+func (f Fingerprint) contextOptions(userAgent string) playwright.BrowserNewContextOptions {
+	if userAgent == "" {
+		userAgent = f.UserAgent
+	}
+	options := playwright.BrowserNewContextOptions{
+		UserAgent: playwright.String(userAgent),
+		Viewport: &playwright.Size{
+			Width:  f.ViewportWidth,
+			Height: f.ViewportHeight,
+		},
+		Locale:     playwright.String(f.Locale),
+		TimezoneId: playwright.String(f.TimezoneID),
+	}
+	if f.AcceptLanguage != "" {
+		options.ExtraHttpHeaders = map[string]string{"Accept-Language": f.AcceptLanguage}
+	}
+	return options
+}
+
+// FingerprintRotator hands out its profiles round robin, one per browser context
+type FingerprintRotator struct {
+	mu       sync.Mutex
+	profiles []Fingerprint
+	next     int
+}
+
+// NewFingerprintRotator rotates the named profiles, see FingerprintNames
+func NewFingerprintRotator(names []string) (*FingerprintRotator, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no fingerprint profiles given")
+	}
+	rotator := &FingerprintRotator{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		profile, ok := Fingerprints[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown fingerprint profile %q, known profiles: %s", name, strings.Join(FingerprintNames(), ", "))
+		}
+		profile.Name = name
+		rotator.profiles = append(rotator.profiles, profile)
+	}
+	return rotator, nil
+}
+
+func mustFingerprintRotator(names []string) *FingerprintRotator {
+	rotator, err := NewFingerprintRotator(names)
+	if err != nil {
+		panic(err)
+	}
+	return rotator
+}
+
+// Next returns the profile for the next context, a nil rotator always returns the first default meli profile
+func (r *FingerprintRotator) Next() Fingerprint {
+	if r == nil {
+		profile := Fingerprints[DefaultMeliFingerprints[0]]
+		profile.Name = DefaultMeliFingerprints[0]
+		return profile
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	profile := r.profiles[r.next]
+	r.next = (r.next + 1) % len(r.profiles)
+	return profile
+}
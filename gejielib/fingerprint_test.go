@@ -0,0 +1,128 @@
+package gejie
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintProfilesAreConsistent(t *testing.T) {
+	timezones := map[string]string{
+		"es-PE": "America/Lima",
+		"es-MX": "America/Mexico_City",
+		"zh-CN": "Asia/Shanghai",
+	}
+	for _, name := range FingerprintNames() {
+		profile := Fingerprints[name]
+		if !strings.HasPrefix(profile.AcceptLanguage, profile.Locale+",") {
+			t.Errorf("%s: Accept-Language %q does not start with its locale %q", name, profile.AcceptLanguage, profile.Locale)
+		}
+		if timezones[profile.Locale] != profile.TimezoneID {
+			t.Errorf("%s: timezone %q does not match locale %q", name, profile.TimezoneID, profile.Locale)
+		}
+		if !strings.Contains(profile.UserAgent, "Chrome/") {
+			t.Errorf("%s: user agent %q is not chromium based", name, profile.UserAgent)
+		}
+		if profile.ViewportWidth == 0 || profile.ViewportHeight == 0 {
+			t.Errorf("%s: viewport is not set", name)
+		}
+	}
+	for _, names := range [][]string{DefaultMeliFingerprints, DefaultZhipinFingerprints} {
+		if _, err := NewFingerprintRotator(names); err != nil {
+			t.Errorf("default profiles %v: %v", names, err)
+		}
+	}
+}
+
+func TestFingerprintRotator(t *testing.T) {
+	if _, err := NewFingerprintRotator([]string{"chrome-win-pe", "netscape"}); err == nil {
+		t.Errorf("NewFingerprintRotator with an unknown profile returned no error")
+	}
+	if _, err := NewFingerprintRotator(nil); err == nil {
+		t.Errorf("NewFingerprintRotator without profiles returned no error")
+	}
+
+	rotator, err := NewFingerprintRotator([]string{"chrome-win-pe", " chrome-win-mx"})
+	if err != nil {
+		t.Fatalf("NewFingerprintRotator failed: %v", err)
+	}
+	got := []string{rotator.Next().Name, rotator.Next().Name, rotator.Next().Name}
+	expected := []string{"chrome-win-pe", "chrome-win-mx", "chrome-win-pe"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Next() rotated %v, expected %v", got, expected)
+			break
+		}
+	}
+
+	var none *FingerprintRotator
+	if profile := none.Next(); profile.Name != DefaultMeliFingerprints[0] {
+		t.Errorf("nil rotator Next() = %q, expected %q", profile.Name, DefaultMeliFingerprints[0])
+	}
+}
+
+func TestFingerprintContextOptions(t *testing.T) {
+	profile := Fingerprints["chrome-win-mx"]
+	options := profile.contextOptions("")
+	if *options.UserAgent != profile.UserAgent || *options.Locale != "es-MX" || *options.TimezoneId != "America/Mexico_City" {
+		t.Errorf("contextOptions() = ua %q, locale %q, timezone %q, expected the profile's", *options.UserAgent, *options.Locale, *options.TimezoneId)
+	}
+	if options.Viewport.Width != 1366 || options.Viewport.Height != 768 {
+		t.Errorf("contextOptions() viewport = %+v, expected 1366x768", options.Viewport)
+	}
+	if options.ExtraHttpHeaders["Accept-Language"] != profile.AcceptLanguage {
+		t.Errorf("contextOptions() Accept-Language = %q, expected %q", options.ExtraHttpHeaders["Accept-Language"], profile.AcceptLanguage)
+	}
+	if override := profile.contextOptions("gejie-test"); *override.UserAgent != "gejie-test" {
+		t.Errorf("contextOptions(%q) user agent = %q", "gejie-test", *override.UserAgent)
+	}
+}
+
+func TestBrowserContextsRotateFingerprints(t *testing.T) {
+	if os.Getenv("GEJIE_BROWSER_TESTS") == "" {
+		t.Skip("set GEJIE_BROWSER_TESTS=1 to run tests that need a playwright browser")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><body><p id=ua>%s</p><p id=lang>%s</p></body></html>", r.UserAgent(), r.Header.Get("Accept-Language"))
+	}))
+	defer server.Close()
+
+	opts := DefaultBrowserOptions()
+	opts.Headless = true
+	opts.RateLimiter = nil
+	opts.Fingerprints = mustFingerprintRotator([]string{"chrome-win-pe", "chrome-win-mx"})
+	bm, err := NewBrowserManager(opts)
+	if err != nil {
+		t.Fatalf("could not start browser manager: %v", err)
+	}
+	defer bm.Close()
+
+	// the manager context took chrome-win-pe, the next context presents chrome-win-mx
+	context, err := bm.NewContext(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer context.Close()
+	page, err := context.NewPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := page.Goto(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	profile := Fingerprints["chrome-win-mx"]
+	if ua, _ := page.Locator("#ua").TextContent(); ua != profile.UserAgent {
+		t.Errorf("server saw user agent %q, expected %q", ua, profile.UserAgent)
+	}
+	if lang, _ := page.Locator("#lang").TextContent(); lang != profile.AcceptLanguage {
+		t.Errorf("server saw Accept-Language %q, expected %q", lang, profile.AcceptLanguage)
+	}
+	timezone, err := page.Evaluate("Intl.DateTimeFormat().resolvedOptions().timeZone")
+	if err != nil || timezone != profile.TimezoneID {
+		t.Errorf("page timezone = %v, %v, expected %q", timezone, err, profile.TimezoneID)
+	}
+}
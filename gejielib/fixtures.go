@@ -0,0 +1,138 @@
+package gejie
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+// FixtureMode controls whether a BrowserManager records page documents to disk
+// or serves them back from disk instead of going to the network
+type FixtureMode int
+
+const (
+	FixtureOff FixtureMode = iota
+	FixtureRecord
+	FixtureReplay
+)
+
+var fixtureKeyUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// FixtureStore saves and loads html documents keyed by their url, one file per document
+type FixtureStore struct {
+	dir string
+}
+
+func NewFixtureStore(dir string) *FixtureStore {
+	return &FixtureStore{dir: dir}
+}
+
+func (fs *FixtureStore) Dir() string {
+	return fs.dir
+}
+
+// Path returns the file a document for the given url is stored in
+func (fs *FixtureStore) Path(rawUrl string) string {
+	return filepath.Join(fs.dir, fixtureKey(rawUrl))
+}
+
+func (fs *FixtureStore) Save(rawUrl string, body []byte) error {
+	if err := os.MkdirAll(fs.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(fs.Path(rawUrl), body, 0644); err != nil {
+		return fmt.Errorf("failed to write fixture for %s: %w", rawUrl, err)
+	}
+	return nil
+}
+
+func (fs *FixtureStore) Load(rawUrl string) ([]byte, error) {
+	body, err := os.ReadFile(fs.Path(rawUrl))
+	if err != nil {
+		return nil, fmt.Errorf("no fixture recorded for %s: %w", rawUrl, err)
+	}
+	return body, nil
+}
+
+// fixtureKey turns a url into a file name, e.g.
+// "https://listado.mercadolibre.com.pe/teclado-mecanico" -> "listado.mercadolibre.com.pe_teclado-mecanico.html"
+// fragments are dropped and query strings are reduced to a short hash so keys stay readable
+func fixtureKey(rawUrl string) string {
+	parsedUrl, err := url.Parse(rawUrl)
+	if err != nil || parsedUrl.Host == "" {
+		sum := sha1.Sum([]byte(rawUrl))
+		return hex.EncodeToString(sum[:8]) + ".html"
+	}
+	key := parsedUrl.Host + parsedUrl.Path
+	if parsedUrl.RawQuery != "" {
+		sum := sha1.Sum([]byte(parsedUrl.RawQuery))
+		key += "_q" + hex.EncodeToString(sum[:4])
+	}
+	key = fixtureKeyUnsafeChars.ReplaceAllString(key, "_")
+	key = strings.Trim(key, "_")
+	return key + ".html"
+}
+
+// routeFixtures handles a routed request in record or replay mode, it returns false
+// when the request is not a document and should be handled by the normal routing
+func routeFixtures(route playwright.Route, store *FixtureStore, mode FixtureMode) bool {
+	request := route.Request()
+	isDocument := request.ResourceType() == "document"
+
+	switch mode {
+	case FixtureReplay:
+		if !isDocument {
+			// replay is fully offline, everything except the saved documents is dropped
+			_ = route.Abort()
+			return true
+		}
+		body, err := store.Load(request.URL())
+		if err != nil {
+			log.Printf("fixture replay: %v", err)
+			_ = route.Fulfill(playwright.RouteFulfillOptions{
+				Status:      playwright.Int(404),
+				ContentType: playwright.String("text/plain"),
+				Body:        err.Error(),
+			})
+			return true
+		}
+		_ = route.Fulfill(playwright.RouteFulfillOptions{
+			Status:      playwright.Int(200),
+			ContentType: playwright.String("text/html; charset=utf-8"),
+			Body:        body,
+		})
+		return true
+
+	case FixtureRecord:
+		if !isDocument {
+			return false
+		}
+		resp, err := route.Fetch()
+		if err != nil {
+			log.Printf("fixture record: could not fetch %s: %v", request.URL(), err)
+			_ = route.Abort()
+			return true
+		}
+		body, err := resp.Body()
+		if err != nil {
+			log.Printf("fixture record: could not read body of %s: %v", request.URL(), err)
+		} else if err := store.Save(request.URL(), body); err != nil {
+			log.Printf("fixture record: %v", err)
+		} else {
+			log.Printf("fixture record: saved %s to %s", request.URL(), store.Path(request.URL()))
+		}
+		_ = route.Fulfill(playwright.RouteFulfillOptions{
+			Response: resp,
+		})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+const fixtureTestDir = "testdata/fixtures"
+const fixtureSearchUrl = "https://listado.mercadolibre.com.pe/unit-test"
+
+// newReplayBrowserManager starts a headless browser replaying the saved fixtures,
+// browser tests only run when GEJIE_BROWSER_TESTS is set since they need chromium installed
+func newReplayBrowserManager(t *testing.T) *BrowserManager {
+	t.Helper()
+	if os.Getenv("GEJIE_BROWSER_TESTS") == "" {
+		t.Skip("set GEJIE_BROWSER_TESTS=1 to run tests that need a playwright browser")
+	}
+	opts := DefaultBrowserOptions()
+	opts.Headless = true
+	opts.FixtureMode = FixtureReplay
+	opts.FixtureDir = fixtureTestDir
+	// replayed pages are served from disk, nothing to be polite to
+	opts.RateLimiter = nil
+	bm, err := NewBrowserManager(opts)
+	if err != nil {
+		t.Fatalf("could not start browser manager: %v", err)
+	}
+	t.Cleanup(bm.Close)
+	return bm
+}
+
+func TestFixtureKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Listing url",
+			input:    "https://listado.mercadolibre.com.pe/teclado-mecanico",
+			expected: "listado.mercadolibre.com.pe_teclado-mecanico.html",
+		},
+		{
+			name:     "Product url with fragment",
+			input:    "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-_JM#polycard_client=search-nordic",
+			expected: "articulo.mercadolibre.com.pe_MPE-600000001-teclado-_JM.html",
+		},
+		{
+			name:     "Query string is hashed",
+			input:    "https://www.zhipin.com/web/geek/jobs?query=agents&city=101010100",
+			expected: "www.zhipin.com_web_geek_jobs_qb7bb221a.html",
+		},
+		{
+			name:     "Not a url is hashed",
+			input:    "not a url",
+			expected: "d7aad9a0157a961b.html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := fixtureKey(tt.input)
+			if result != tt.expected {
+				t.Errorf("fixtureKey(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFixtureStoreSaveLoad(t *testing.T) {
+	store := NewFixtureStore(t.TempDir())
+	pageUrl := "https://articulo.mercadolibre.com.pe/MPE-1-unit-test-_JM"
+
+	if _, err := store.Load(pageUrl); err == nil {
+		t.Fatalf("Load(%q) before Save returned no error", pageUrl)
+	}
+	if err := store.Save(pageUrl, []byte("<html></html>")); err != nil {
+		t.Fatalf("Save(%q) failed: %v", pageUrl, err)
+	}
+	body, err := store.Load(pageUrl + "#fragment")
+	if err != nil {
+		t.Fatalf("Load(%q) failed: %v", pageUrl, err)
+	}
+	if string(body) != "<html></html>" {
+		t.Errorf("Load(%q) = %q, expected %q", pageUrl, body, "<html></html>")
+	}
+}
+
+func TestReplayMeliSearch(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	opts.Concurrency = 2
+	result, err := RunMeliSearchWithBrowser(context.Background(), bm, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithBrowser failed: %v", err)
+	}
+	products := result.Products
+	if len(products) != 3 {
+		t.Fatalf("RunMeliSearchWithBrowser scraped %d products, expected 3", len(products))
+	}
+
+	expected := []struct {
+		title       string
+		amountCents int
+		storeName   string
+		imageCount  int
+	}{
+		{"Teclado Mecánico Redragon Kumara K552 Rgb", 15990, "Redragon", 2},
+		{"Teclado Mecánico Inalámbrico Aula F75", 122900, "Aula Store", 1},
+		{"Teclado Gamer Logitech G413 Se", 18900, "Logitech G", 1},
+	}
+	for i, want := range expected {
+		product := products[i]
+		if product.Title != want.title {
+			t.Errorf("products[%d].Title = %q, expected %q", i, product.Title, want.title)
+		}
+		if product.Price.AmountCents != want.amountCents {
+			t.Errorf("products[%d].Price.AmountCents = %d, expected %d", i, product.Price.AmountCents, want.amountCents)
+		}
+		if product.StoreInfo.Name != want.storeName {
+			t.Errorf("products[%d].StoreInfo.Name = %q, expected %q", i, product.StoreInfo.Name, want.storeName)
+		}
+		if len(product.ImageUrls) != want.imageCount {
+			t.Errorf("products[%d] has %d images, expected %d", i, len(product.ImageUrls), want.imageCount)
+		}
+	}
+
+	if products[0].ReviewCount == nil || *products[0].ReviewCount != 152 {
+		t.Errorf("products[0].ReviewCount = %v, expected 152", products[0].ReviewCount)
+	}
+	if products[0].SoldMoreThan == nil || *products[0].SoldMoreThan != 100 {
+		t.Errorf("products[0].SoldMoreThan = %v, expected 100", products[0].SoldMoreThan)
+	}
+	if products[2].ReviewCount != nil {
+		t.Errorf("products[2].ReviewCount = %v, expected nil", *products[2].ReviewCount)
+	}
+}
+
+func TestReplayProductQuotingPausedPhrase(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+
+	product, err := ScrapeProduct(context.Background(), bm, fixtureQuotingPausedUrl)
+	if err != nil {
+		t.Fatalf("ScrapeProduct of an active listing quoting the paused message failed: %v", err)
+	}
+	if product.Title != "Teclado Mecánico Royal Kludge Rk61" {
+		t.Errorf("product.Title = %q", product.Title)
+	}
+}
+
+func TestReplayMissingFixtureIsNotFound(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+
+	_, err := ScrapeProduct(context.Background(), bm, "https://articulo.mercadolibre.com.pe/MPE-1-never-recorded-_JM")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("ScrapeProduct of an unrecorded url returned %v, expected ErrNotFound", err)
+	}
+}
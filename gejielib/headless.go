@@ -2,9 +2,10 @@
 package gejie
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
 	"github.com/playwright-community/playwright-go"
@@ -24,47 +25,113 @@ type JobPosting struct {
 	ScrapedAt    time.Time
 }
 
-func RunZhipin(firstUrl string, collectLinks bool) {
-
-	// directly create url frontier for now
-	urlFrontier := NewURLFrontier()
+type ZhipinOptions struct {
+	// CollectLinks follows the recommended job postings linked from each posting
+	CollectLinks bool
+	// MaxDepth is how many links away from the first posting are followed
+	MaxDepth int
+	// Job checkpoints the crawl so it can be resumed, nil keeps everything in memory
+	Job *CrawlJob
+	// Retry queues postings that failed with a retriable error again, nil tries every posting once
+	Retry *RetryPolicy
+	// RespectRobots skips postings robots.txt disallows and applies its Crawl-delay
+	RespectRobots bool
+}
 
-	// Initialize Playwright
-	pw, err := playwright.Run()
-	if err != nil {
-		log.Fatalf("could not start playwright: %v", err)
+func DefaultZhipinOptions() *ZhipinOptions {
+	return &ZhipinOptions{
+		CollectLinks:  true,
+		MaxDepth:      1,
+		Retry:         DefaultRetryPolicy(),
+		RespectRobots: true,
 	}
-	defer pw.Stop()
+}
 
-	// Launch browser (visible by default for development)
-	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
-		Headless: playwright.Bool(false),
-	})
-	if err != nil {
-		log.Fatalf("could not launch browser: %v", err)
+// DefaultZhipinBrowserOptions returns browser options for zhipin, a visible browser loading
+// every resource and presenting the zhipin fingerprint profiles
+func DefaultZhipinBrowserOptions() *BrowserOptions {
+	opts := DefaultBrowserOptions()
+	opts.Headless = false
+	opts.BlockImages = false
+	opts.BlockMedia = false
+	opts.BlockFonts = false
+	opts.NavigationTimeout = 30000
+	opts.Fingerprints = mustFingerprintRotator(DefaultZhipinFingerprints)
+	return opts
+}
+
+// RunZhipin scrapes job postings starting from firstUrl, postings that fail are skipped
+// and logged, an error is only returned when the browser or the first posting fails.
+// when ctx is cancelled the postings scraped so far are printed before returning the context error.
+// with a job every posting is checkpointed and a job that was stopped continues where it left off.
+// postings are visited on one page of bm, see DefaultZhipinBrowserOptions
+func RunZhipin(ctx context.Context, bm *BrowserManager, firstUrl string, opts *ZhipinOptions) error {
+	if opts == nil {
+		opts = DefaultZhipinOptions()
+	}
+	job := opts.Job
+
+	frontierOpts := DefaultFrontierOptions()
+	frontierOpts.MaxDepth = opts.MaxDepth
+	if opts.RespectRobots {
+		frontierOpts.Robots = NewRobotsChecker(nil, bm.RateLimiter())
+		if !frontierOpts.Robots.Allowed(ctx, firstUrl) {
+			return newScrapeError(ErrDisallowed, firstUrl, "check robots.txt", nil)
+		}
 	}
-	defer browser.Close()
 
-	// Create new browser context and page
-	context, err := browser.NewContext()
-	if err != nil {
-		log.Fatalf("could not create context: %v", err)
+	// postings are visited breadth first, the order links were found in
+	var urlFrontier URLFrontierInterface = NewURLFrontierWithOptions(frontierOpts)
+	resuming := false
+	if job != nil {
+		job.Frontier.SetOptions(frontierOpts)
+		urlFrontier = job.Frontier
+		resuming = job.Frontier.Count() > 0
+		if resuming {
+			requeued := job.Frontier.RequeueFailed()
+			log.Printf("resuming job %s, %d urls left (%d failed urls requeued)", job.ID, job.Frontier.CountRemaining(), requeued)
+		}
 	}
-	defer context.Close()
 
-	page, err := context.NewPage()
+	lease, err := bm.leaseSession()
 	if err != nil {
-		log.Fatalf("could not create page: %v", err)
+		return fmt.Errorf("could not open browser page: %w", err)
+	}
+	// closing the lease aborts any navigation in flight once ctx is cancelled
+	stopClosing := context.AfterFunc(ctx, lease.close)
+	defer stopClosing()
+	defer lease.close()
+	// scrapePosting visits url and queues the postings it links to
+	scrapePosting := func(url string, fullUrl string) (JobPosting, error) {
+		var jp JobPosting
+		err := lease.run(func(page playwright.Page) error {
+			var err error
+			jp, err = ScrapePageUrl(page, fullUrl)
+			if err == nil {
+				collectLinksWithinDepth(ctx, page, urlFrontier, url, opts)
+			}
+			return err
+		})
+		return jp, err
 	}
 
 	scrapedJobPostings := []JobPosting{}
-	urlFrontier.Add(firstUrl)
-	jp := ScrapePageUrl(page, firstUrl)
-	scrapedJobPostings = append(scrapedJobPostings, jp)
-	urlFrontier.MarkVisited(firstUrl)
-
-	if collectLinks {
-		collectMoreLinks(page, urlFrontier)
+	failures := []ScrapeFailure{}
+	if !resuming {
+		urlFrontier.Add(ctx, firstUrl)
+		if err := bm.RateLimiter().Wait(ctx, firstUrl); err != nil {
+			return err
+		}
+		jp, err := scrapePosting(firstUrl, firstUrl)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			urlFrontier.MarkFailed(firstUrl, err)
+			return err
+		}
+		scrapedJobPostings = append(scrapedJobPostings, jp)
+		checkpointJobPosting(job, urlFrontier, firstUrl, jp)
 	}
 
 	// loop until no more urls to visit
@@ -72,16 +139,38 @@ func RunZhipin(firstUrl string, collectLinks bool) {
 	pagesScrapped := 1
 	const scrapePageLimit = 10
 	for hasMore && pagesScrapped <= scrapePageLimit {
-		// Add random delay between 1-3 seconds
-		delay := time.Duration(1000+rand.Intn(2000)) * time.Millisecond
-		time.Sleep(delay)
-
-		fullUrl := fmt.Sprintf("%s%s", zhipinBaseUrl, url)
+		// links are stored absolute, jobs from before links were resolved hold relative paths
+		fullUrl, err := ResolveURL(zhipinBaseUrl, url)
+		if err != nil {
+			fullUrl = zhipinBaseUrl + url
+		}
+		if bm.RateLimiter().Wait(ctx, fullUrl) != nil {
+			break
+		}
 		log.Printf("Scraping URL: %s", fullUrl)
-		jp = ScrapePageUrl(page, fullUrl)
-		scrapedJobPostings = append(scrapedJobPostings, jp)
+		jp, err := scrapePosting(url, fullUrl)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			urlFrontier.MarkFailed(url, err)
+			record, _ := urlFrontier.Get(url)
+			if opts.Retry.ShouldRetry(err, record.Attempts) {
+				delay := opts.Retry.Backoff(record.Attempts)
+				log.Printf("retrying job posting in %v: %v", delay.Round(time.Millisecond), err)
+				if sleepContext(ctx, delay) != nil {
+					break
+				}
+				urlFrontier.Requeue(url)
+			} else {
+				log.Printf("skipping job posting: %v", err)
+				failures = append(failures, ScrapeFailure{Url: fullUrl, Err: gaveUp(err, record.Attempts)})
+			}
+		} else {
+			scrapedJobPostings = append(scrapedJobPostings, jp)
+			checkpointJobPosting(job, urlFrontier, url, jp)
+		}
 
-		urlFrontier.MarkVisited(url)
 		pagesScrapped++
 		url, hasMore = urlFrontier.GetNext()
 		if hasMore {
@@ -91,16 +180,71 @@ func RunZhipin(firstUrl string, collectLinks bool) {
 		}
 	}
 
+	if job != nil {
+		// print everything the job collected, including postings from earlier runs
+		scrapedJobPostings = loadJobPostings(job)
+	}
 	fmt.Print(scrapedJobPostings)
+	for _, failure := range failures {
+		log.Printf("failed (%s): %v", FailureKind(failure.Err), failure.Err)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 	// Add small delay to observe results
-	time.Sleep(2 * time.Second)
+	sleepContext(ctx, 2*time.Second)
+	return nil
+}
+
+// checkpointJobPosting marks url visited and saves its posting when running as a job
+func checkpointJobPosting(job *CrawlJob, urlFrontier URLFrontierInterface, url string, jp JobPosting) {
+	if job != nil {
+		if err := job.SaveResult(url, jp); err != nil {
+			log.Printf("could not checkpoint job posting %s: %v", url, err)
+			return
+		}
+	}
+	urlFrontier.MarkVisited(url)
+}
+
+// collectLinksWithinDepth queues the postings linked from url unless url is already at the max depth
+func collectLinksWithinDepth(ctx context.Context, page playwright.Page, urlFrontier URLFrontierInterface, url string, opts *ZhipinOptions) {
+	if !opts.CollectLinks {
+		return
+	}
+	if record, ok := urlFrontier.Get(url); ok && opts.MaxDepth > 0 && record.Depth >= opts.MaxDepth {
+		return
+	}
+	if err := collectMoreLinks(ctx, page, urlFrontier, url); err != nil {
+		log.Printf("could not collect more job links: %v", err)
+	}
 }
 
-func ScrapePageUrl(page playwright.Page, url string) JobPosting {
+func loadJobPostings(job *CrawlJob) []JobPosting {
+	postings := []JobPosting{}
+	err := job.ForEachResult(func(url string, data []byte) error {
+		jp := JobPosting{}
+		if err := json.Unmarshal(data, &jp); err != nil {
+			return fmt.Errorf("could not decode posting %s: %w", url, err)
+		}
+		postings = append(postings, jp)
+		return nil
+	})
+	if err != nil {
+		log.Printf("could not load job postings: %v", err)
+	}
+	return postings
+}
+
+func ScrapePageUrl(page playwright.Page, url string) (JobPosting, error) {
 
 	// Example: Navigate to a page and scrape title
-	if _, err := page.Goto(url); err != nil {
-		log.Fatalf("could not goto: %v", err)
+	resp, err := page.Goto(url)
+	if err != nil {
+		return JobPosting{}, wrapBrowserError(url, "goto", err)
+	}
+	if err := detectPage(page, resp, url); err != nil {
+		return JobPosting{}, err
 	}
 
 	// Wait for page to load
@@ -111,31 +255,37 @@ func ScrapePageUrl(page playwright.Page, url string) JobPosting {
 	// Get page title
 	pageTitle, err := page.Title()
 	if err != nil {
-		log.Fatalf("could not get title: %v", err)
+		return JobPosting{}, wrapBrowserError(url, "get title", err)
 	}
 
-	jobPostTitleElem := page.Locator("#main > div.job-banner > div > div > div.info-primary > div.name > h1")
-	playwright.NewPlaywrightAssertions(10000).Locator(jobPostTitleElem).ToBeVisible()
+	selectors := selectorsFor(SiteZhipin, url)
+	if err := playwright.NewPlaywrightAssertions(10000).Locator(page.Locator(selectors.Any(SelectorJobTitle)).First()).ToBeVisible(); err != nil {
+		// zhipin swaps in its security check after the scripts ran
+		if detected := detectPage(page, nil, url); detected != nil {
+			return JobPosting{}, detected
+		}
+		return JobPosting{}, wrapBrowserError(url, "wait for job posting title", err)
+	}
 
 	// get the job post name
-	jobPostTitleText, err := jobPostTitleElem.First().TextContent()
+	jobPostTitleText, err := selectors.Locator(page, SelectorJobTitle).First().TextContent()
 	if err != nil {
-		log.Fatalf("could not exctract job posting title")
+		return JobPosting{}, wrapBrowserError(url, "extract job posting title", err)
 	}
 
-	salaryRangeText, err := page.Locator("#main > div.job-banner > div > div > div.info-primary > div.name > span").First().TextContent()
+	salaryRangeText, err := selectors.Locator(page, SelectorSalary).First().TextContent()
 	if err != nil {
-		log.Fatalf("could not extract job salary range")
+		return JobPosting{}, wrapBrowserError(url, "extract job salary range", err)
 	}
 
-	jobPostContent, err := page.Locator("#main > div.job-box > div > div.job-detail > div:nth-child(1) > div.job-sec-text").First().TextContent()
+	jobPostContent, err := selectors.Locator(page, SelectorJobContent).First().TextContent()
 	if err != nil {
-		log.Fatalf("could not extract job salary range")
+		return JobPosting{}, wrapBrowserError(url, "extract job posting content", err)
 	}
 
-	postTagElems, err := page.Locator("#main > div.job-box > div > div.job-detail > div:nth-child(1) > ul > li").AllTextContents()
+	postTagElems, err := selectors.Locator(page, SelectorJobTags).AllTextContents()
 	if err != nil {
-		log.Fatalf("could not extract job posting tags")
+		return JobPosting{}, wrapBrowserError(url, "extract job posting tags", err)
 	}
 
 	// log.Println("post tags", postTagElems)
@@ -158,21 +308,22 @@ func ScrapePageUrl(page playwright.Page, url string) JobPosting {
 	// }
 
 	// collect more job links
-	log.Printf("got job content text: %s", newJobPosting)
+	log.Printf("got job content text: %v", newJobPosting)
 
-	return newJobPosting
+	return newJobPosting, nil
 }
 
-func collectMoreLinks(page playwright.Page, urlFrontier URLFrontierInterface) {
-	moreJobsListSelector := "ul.look-job-list"
-	moreJobsListElems, err := page.Locator(moreJobsListSelector).All()
+// collectMoreLinks queues the recommended postings of the page at parentUrl one level deeper than it
+func collectMoreLinks(ctx context.Context, page playwright.Page, urlFrontier URLFrontierInterface, parentUrl string) error {
+	selectors := selectorsFor(SiteZhipin, page.URL())
+	moreJobsListElems, err := selectors.Locator(page, SelectorMoreJobs).All()
 	if err != nil {
-		log.Fatalf("could not extract recommended job postings")
+		return wrapBrowserError(page.URL(), "extract recommended job postings", err)
 	}
 
 	var moreJobUrls []string
 	for _, elem := range moreJobsListElems {
-		jobLinks, err := elem.Locator("li > a").All()
+		jobLinks, err := elem.Locator(selectors.Any(SelectorMoreJobsLinks)).All()
 		if err != nil {
 			log.Printf("error getting text for element %v", err)
 			continue
@@ -180,7 +331,7 @@ func collectMoreLinks(page playwright.Page, urlFrontier URLFrontierInterface) {
 		for _, jl := range jobLinks {
 			linkUrl, err := jl.GetAttribute("href")
 			if err != nil {
-				log.Fatalf("could not parse job listing url")
+				log.Printf("could not parse job listing url: %v", err)
 				continue
 			}
 			// log.Printf("\njob listing url %s", linkUrl)
@@ -189,8 +340,9 @@ func collectMoreLinks(page playwright.Page, urlFrontier URLFrontierInterface) {
 	}
 
 	log.Println("extra job links: ", moreJobUrls)
-	urlFrontier.BulkAdd(moreJobUrls)
+	urlFrontier.AddEntries(ctx, ChildEntries(urlFrontier, parentUrl, moreJobUrls, 0))
 	log.Printf("\nTotal urls in frontier: %d", urlFrontier.Count())
+	return nil
 }
 
 // const exampleSearchViewUrl = "https://www.zhipin.com/web/geek/jobs?query=agents&city=101010100"
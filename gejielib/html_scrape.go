@@ -1,3 +1,404 @@
 package gejie
 
-// implement classic html scraping with colly
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/playwright-community/playwright-go"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+// pages larger than this are cut, no meli page comes close
+const maxStaticPageSize = 10 << 20
+
+// errIncompleteStatic marks a static page missing fields that are only rendered by scripts
+var errIncompleteStatic = fmt.Errorf("static html is incomplete: %w", ErrLayoutChanged)
+
+// HTTPFetcher scrapes meli pages from the html the server sends, without a browser. it honours the
+// rate limiter, proxies, fingerprints, navigation timeout and fixtures of the browser options
+type HTTPFetcher struct {
+	opts     *BrowserOptions
+	fixtures *FixtureStore
+	mu       sync.Mutex
+	// clients holds one client per proxy, credentials included, the zero Proxy connects directly
+	clients map[Proxy]*http.Client
+}
+
+func NewHTTPFetcher(opts *BrowserOptions) *HTTPFetcher {
+	if opts == nil {
+		opts = DefaultBrowserOptions()
+	}
+	f := &HTTPFetcher{opts: opts, clients: map[Proxy]*http.Client{}}
+	if opts.FixtureMode != FixtureOff {
+		f.fixtures = NewFixtureStore(opts.FixtureDir)
+	}
+	return f
+}
+
+func (f *HTTPFetcher) RateLimiter() *RateLimiter {
+	return f.opts.RateLimiter
+}
+
+func (f *HTTPFetcher) Offline() bool {
+	return f.opts.FixtureMode == FixtureReplay
+}
+
+func (f *HTTPFetcher) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, client := range f.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// client returns the client connecting through proxy, or directly when proxy is nil
+func (f *HTTPFetcher) client(proxy *Proxy) *http.Client {
+	key := Proxy{}
+	if proxy != nil {
+		key = *proxy
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if client, ok := f.clients[key]; ok {
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		proxyUrl, _ := url.Parse(proxy.Server)
+		if proxy.Username != "" {
+			proxyUrl.User = url.UserPassword(proxy.Username, proxy.Password)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+	client := &http.Client{Transport: transport}
+	if f.opts.NavigationTimeout > 0 {
+		client.Timeout = time.Duration(f.opts.NavigationTimeout) * time.Millisecond
+	}
+	f.clients[key] = client
+	return client
+}
+
+// fetch downloads and parses the page at rawUrl once the rate limiter lets its host be visited,
+// returning the url the page ended up at after redirects
+func (f *HTTPFetcher) fetch(ctx context.Context, rawUrl string) (*goquery.Document, string, error) {
+	if err := f.opts.RateLimiter.Wait(ctx, rawUrl); err != nil {
+		return nil, "", err
+	}
+	if f.opts.FixtureMode == FixtureReplay {
+		body, err := f.fixtures.Load(rawUrl)
+		if err != nil {
+			return nil, "", newScrapeError(ErrNotFound, rawUrl, "load fixture", err)
+		}
+		return f.parse(rawUrl, rawUrl, http.StatusOK, body)
+	}
+
+	var proxy *Proxy
+	if f.opts.Proxies != nil {
+		next, err := f.opts.Proxies.Next()
+		if err != nil {
+			return nil, "", err
+		}
+		proxy = &next
+	}
+	doc, finalUrl, err := f.download(ctx, proxy, rawUrl)
+	if proxy != nil && ctx.Err() == nil {
+		f.opts.Proxies.Report(*proxy, err)
+	}
+	return doc, finalUrl, err
+}
+
+func (f *HTTPFetcher) download(ctx context.Context, proxy *Proxy, rawUrl string) (*goquery.Document, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawUrl, nil)
+	if err != nil {
+		return nil, "", newScrapeError(ErrNotFound, rawUrl, "get", err)
+	}
+	fingerprint := f.opts.Fingerprints.Next()
+	userAgent := f.opts.UserAgent
+	if userAgent == "" {
+		userAgent = fingerprint.UserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	if fingerprint.AcceptLanguage != "" {
+		req.Header.Set("Accept-Language", fingerprint.AcceptLanguage)
+	}
+
+	resp, err := f.client(proxy).Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return nil, "", newScrapeError(ErrTimeout, rawUrl, "get", err)
+		}
+		return nil, "", newScrapeError(ErrUnavailable, rawUrl, "get", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxStaticPageSize))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		return nil, "", newScrapeError(ErrUnavailable, rawUrl, "read body", err)
+	}
+	if f.opts.FixtureMode == FixtureRecord && resp.StatusCode == http.StatusOK {
+		if err := f.fixtures.Save(rawUrl, body); err != nil {
+			log.Printf("fixture record: %v", err)
+		}
+	}
+	return f.parse(rawUrl, resp.Request.URL.String(), resp.StatusCode, body)
+}
+
+// parse reads a downloaded page, classifying it like detectPage does for browser pages
+func (f *HTTPFetcher) parse(rawUrl string, finalUrl string, status int, body []byte) (*goquery.Document, string, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, "", newScrapeError(ErrLayoutChanged, rawUrl, "parse html", err)
+	}
+	// scripts are left out of the text the detector reads, they are not shown on the page
+	text := doc.Find("body").Clone()
+	text.Find("script, style, noscript").Remove()
+	snapshot := PageSnapshot{
+		Status: status,
+		Url:    finalUrl,
+		Title:  doc.Find("title").First().Text(),
+		Text:   strings.TrimSpace(text.Text()),
+		Banner: selectorsFor(pageSite(finalUrl), finalUrl).Find(doc, SelectorListingStatus).Text(),
+	}
+	if err := ClassifyPage(snapshot).Err(rawUrl); err != nil {
+		if errors.Is(err, ErrListingPaused) {
+			// the paused banner sits on top of a full product page, still read for its status
+			return doc, finalUrl, err
+		}
+		return nil, "", err
+	}
+	if err := statusError(rawUrl, status); err != nil {
+		return nil, "", err
+	}
+	return doc, finalUrl, nil
+}
+
+// Document downloads the page at url and returns its html and final url
+func (f *HTTPFetcher) Document(ctx context.Context, url string) (*goquery.Document, string, error) {
+	return f.fetch(ctx, url)
+}
+
+// ListingCards collects up to maxItems product cards following the next page links of the search
+func (f *HTTPFetcher) ListingCards(ctx context.Context, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error) {
+	return ScrapeListingCards(ctx, f, searchUrl, maxItems, keep)
+}
+
+// Product scrapes the product page at url, failing when its title or price is not in the html
+func (f *HTTPFetcher) Product(ctx context.Context, url string) (*MeliProduct, error) {
+	product, missing, err := f.product(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		log.Printf("static html of %s has no %s", url, strings.Join(missing, ", "))
+	}
+	return product, nil
+}
+
+// product scrapes the product page at url, listing the fields the html did not have
+func (f *HTTPFetcher) product(ctx context.Context, url string) (*MeliProduct, []string, error) {
+	doc, finalUrl, err := f.fetch(ctx, url)
+	paused := pausedDocument(doc, err)
+	if err != nil && !paused {
+		return nil, nil, err
+	}
+	product, missing, err := productFromHTML(doc, finalUrl, url)
+	if err != nil {
+		return nil, missing, err
+	}
+	if paused && product.Status == ListingActive {
+		product.Status = ListingPaused
+	}
+	if !f.opts.KeepDescriptionHTML {
+		product.DescriptionHTML = ""
+	}
+	enumerateVariants(ctx, f, product, f.opts.MaxVariants)
+	return product, missing, nil
+}
+
+// ProductImages scrapes only the image urls of a product page
+func (f *HTTPFetcher) ProductImages(ctx context.Context, url string) ([]string, error) {
+	doc, finalUrl, err := f.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	images := productImagesFromHTML(doc, selectorsFor(SiteMeli, finalUrl))
+	if len(images) == 0 {
+		return nil, newScrapeError(errIncompleteStatic, url, "find product images", nil)
+	}
+	return images, nil
+}
+
+// productFromHTML extracts a product from a page's html, from its json-ld and preloaded state first
+// and from the css selectors for the fields those did not have. a missing title or price is an
+// error, other missing fields are listed so a browser can be tried instead
+func productFromHTML(doc *goquery.Document, pageUrl string, url string) (*MeliProduct, []string, error) {
+	product := MeliProduct{Url: url, FieldSources: map[string]FieldSource{}}
+	for _, structured := range structuredSources {
+		if data, ok := structured.read(doc); ok {
+			fillStructured(&product, data, structured.source)
+		}
+	}
+	if err := fillFromSelectors(&product, doc, pageUrl, url); err != nil {
+		return nil, missingFields(&product), err
+	}
+	if product.Price.CurrencyCode == "" {
+		product.Price.CurrencyCode = utils.DomainToCurrencyCode(utils.Domain(pageUrl))
+	}
+	if variant, links := variantFromHTML(doc, pageUrl, selectorsFor(SiteMeli, pageUrl)); variant != nil {
+		variant.Price = product.Price
+		variant.ImageUrls = product.ImageUrls
+		variant.Url = url
+		product.Variants = []MeliVariant{*variant}
+		product.variantLinks = links
+	}
+	return &product, missingFields(&product), nil
+}
+
+// fillFromSelectors sets the fields of product the structured data did not have from the page's elements
+func fillFromSelectors(product *MeliProduct, doc *goquery.Document, pageUrl string, url string) error {
+	selectors := selectorsFor(SiteMeli, pageUrl)
+	if product.Title == "" {
+		product.Title = strings.TrimSpace(selectors.Find(doc, SelectorTitle).First().Text())
+		if product.Title == "" {
+			return newScrapeError(ErrLayoutChanged, url, "find product name", nil)
+		}
+		product.FieldSources[FieldTitle] = SourceCSS
+	}
+
+	if product.FieldSources[FieldPrice] == "" {
+		amount := selectors.Find(doc, SelectorPriceFraction).First().Text()
+		amountInt, err := utils.ParseAmountCents(amount)
+		if err != nil {
+			return newScrapeError(ErrLayoutChanged, url, "parse price amount", err)
+		}
+		// amount cent is not always available to scrape
+		amountCentsInt := 0
+		if cents := selectors.Find(doc, SelectorPriceCents).First(); cents.Length() > 0 {
+			amountCentsInt, err = parseCents(selectionText{cents})
+			if err != nil {
+				log.Printf("failed to parse amount cents, continuing with 0: %v", err)
+			}
+		}
+		product.Price = Price{
+			AmountCents:  amountInt + amountCentsInt,
+			CurrencyCode: utils.DomainToCurrencyCode(utils.Domain(pageUrl)),
+		}
+		product.FieldSources[FieldPrice] = SourceCSS
+	}
+	fillPriceTerms(&product.Price, doc, selectors, productPriceFields)
+
+	reviews := selectors.Find(doc, SelectorReviews).First()
+	if product.ReviewCount == nil {
+		product.ReviewCount = convertStrUint32(cleanReviewCount(selectors.Find(reviews, SelectorReviewsCount).First().Text()))
+		recordSource(product, FieldReviewCount, product.ReviewCount != nil)
+	}
+	if product.Rating == nil {
+		product.Rating = convertStrToFloat32(strings.TrimSpace(selectors.Find(reviews, SelectorReviewsRating).First().Text()))
+		recordSource(product, FieldRating, product.Rating != nil)
+	}
+	subtitle := selectors.Find(doc, SelectorSold).First()
+	if product.SoldMoreThan == nil {
+		// products without a "Nuevo | +100 vendidos" subtitle have sold 0
+		soldCount := parseSoldCount(subtitle.Text())
+		product.SoldMoreThan = &soldCount
+		recordSource(product, FieldSold, subtitle.Length() > 0)
+	}
+	if product.Condition == "" {
+		product.Condition = parseCondition(subtitle.Text())
+		recordSource(product, FieldCondition, product.Condition != "")
+	}
+	product.AvailableQuantity = parseAvailableQuantity(selectors.Find(doc, SelectorAvailableQuantity).First().Text())
+	product.Status = parseListingStatus(selectors.Find(doc, SelectorListingStatus).Text())
+	warranties := selectors.Find(doc, SelectorWarranty).Map(func(_ int, s *goquery.Selection) string {
+		return parseWarranty(s.Text())
+	})
+	product.Warranty = strings.Join(warranties, "; ")
+	if len(product.ImageUrls) == 0 {
+		product.ImageUrls = productImagesFromHTML(doc, selectors)
+		recordSource(product, FieldImages, len(product.ImageUrls) > 0)
+	}
+	if description := selectors.Find(doc, SelectorDescription); description.Length() > 0 {
+		if product.DescriptionContent == "" {
+			product.DescriptionContent = descriptionText(description)
+			recordSource(product, FieldDescription, product.DescriptionContent != "")
+		}
+		product.DescriptionHTML = sanitizeDescriptionHTML(description)
+	}
+	addAttributes(product, attributeSectionsFromHTML(doc, selectors), SourceCSS)
+	product.Shipping = shippingFromHTML(doc, selectors, productShippingFields, time.Now())
+	if product.StoreInfo.Name == "" {
+		product.StoreInfo.Name = strings.TrimSpace(selectors.Find(doc, SelectorStoreName).First().Text())
+		recordSource(product, FieldStoreName, product.StoreInfo.Name != "")
+	}
+	if product.StoreInfo.Url == "" {
+		product.StoreInfo.Url = parseUrlBase(selectors.Find(doc, SelectorStoreUrl).First().AttrOr("href", ""))
+		recordSource(product, FieldStoreUrl, product.StoreInfo.Url != "")
+	}
+	storeLogo := selectors.Find(doc, SelectorStoreLogo).First()
+	product.StoreInfo.LogoImageSrcOriginal = storeLogo.AttrOr("data-src", storeLogo.AttrOr("src", ""))
+	return nil
+}
+
+func recordSource(product *MeliProduct, field string, found bool) {
+	if found {
+		product.FieldSources[field] = SourceCSS
+	}
+}
+
+// missingFields lists the fields no source had, only images are worth a browser retry today
+func missingFields(product *MeliProduct) []string {
+	missing := []string{}
+	if product.Title == "" {
+		missing = append(missing, FieldTitle)
+	}
+	if product.FieldSources[FieldPrice] == "" {
+		missing = append(missing, FieldPrice)
+	}
+	if len(product.ImageUrls) == 0 {
+		// the gallery is lazy loaded on some pages
+		missing = append(missing, FieldImages)
+	}
+	return missing
+}
+
+// selectionText lets parseCents read a goquery selection like a playwright locator
+type selectionText struct {
+	*goquery.Selection
+}
+
+func (s selectionText) TextContent(...playwright.LocatorTextContentOptions) (string, error) {
+	return s.Text(), nil
+}
+
+// productImagesFromHTML reads the gallery image urls, lazy images keep theirs in data-src
+func productImagesFromHTML(doc *goquery.Document, selectors Selectors) []string {
+	imageUrls := []string{}
+	selectors.Find(doc, SelectorImages).Each(func(_ int, s *goquery.Selection) {
+		src := s.AttrOr("src", "")
+		if src == "" || strings.HasPrefix(src, "data:") {
+			src = s.AttrOr("data-src", s.AttrOr("data-zoom", ""))
+		}
+		if src != "" {
+			imageUrls = append(imageUrls, src)
+		}
+	})
+	return imageUrls
+}
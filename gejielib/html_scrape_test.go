@@ -0,0 +1,239 @@
+package gejie
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newReplayHTTPFetcher scrapes the saved fixtures without a browser
+func newReplayHTTPFetcher(t *testing.T) *HTTPFetcher {
+	t.Helper()
+	opts := DefaultBrowserOptions()
+	opts.FixtureMode = FixtureReplay
+	opts.FixtureDir = fixtureTestDir
+	opts.RateLimiter = nil
+	fetcher := NewHTTPFetcher(opts)
+	t.Cleanup(fetcher.Close)
+	return fetcher
+}
+
+func TestHTTPFetcherReplayMeliSearch(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	opts.Concurrency = 2
+	result, err := RunMeliSearchWithFetcher(context.Background(), fetcher, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithFetcher failed: %v", err)
+	}
+	products := result.Products
+	if len(products) != 3 {
+		t.Fatalf("RunMeliSearchWithFetcher scraped %d products, expected 3", len(products))
+	}
+
+	expected := []struct {
+		title       string
+		amountCents int
+		storeName   string
+		imageCount  int
+	}{
+		{"Teclado Mecánico Redragon Kumara K552 Rgb", 15990, "Redragon", 2},
+		{"Teclado Mecánico Inalámbrico Aula F75", 122900, "Aula Store", 1},
+		{"Teclado Gamer Logitech G413 Se", 18900, "Logitech G", 1},
+	}
+	for i, want := range expected {
+		product := products[i]
+		if product.Title != want.title {
+			t.Errorf("products[%d].Title = %q, expected %q", i, product.Title, want.title)
+		}
+		if product.Price.AmountCents != want.amountCents {
+			t.Errorf("products[%d].Price.AmountCents = %d, expected %d", i, product.Price.AmountCents, want.amountCents)
+		}
+		if product.StoreInfo.Name != want.storeName {
+			t.Errorf("products[%d].StoreInfo.Name = %q, expected %q", i, product.StoreInfo.Name, want.storeName)
+		}
+		if len(product.ImageUrls) != want.imageCount {
+			t.Errorf("products[%d] has %d images, expected %d", i, len(product.ImageUrls), want.imageCount)
+		}
+	}
+	if products[0].ReviewCount == nil || *products[0].ReviewCount != 152 {
+		t.Errorf("products[0].ReviewCount = %v, expected 152", products[0].ReviewCount)
+	}
+	if products[0].SoldMoreThan == nil || *products[0].SoldMoreThan != 100 {
+		t.Errorf("products[0].SoldMoreThan = %v, expected 100", products[0].SoldMoreThan)
+	}
+	if products[2].ReviewCount != nil {
+		t.Errorf("products[2].ReviewCount = %v, expected nil", *products[2].ReviewCount)
+	}
+	expectedDescription := "Teclado mecánico Redragon Kumara K552 con iluminación RGB.\nSwitches Outemu Blue, táctiles y con click.\n\nContenido de la caja:\n- Teclado\n- Manual"
+	if products[0].DescriptionContent != expectedDescription {
+		t.Errorf("products[0].DescriptionContent = %q, expected %q", products[0].DescriptionContent, expectedDescription)
+	}
+	if products[0].DescriptionHTML != "" {
+		t.Errorf("products[0].DescriptionHTML = %q, expected it dropped without KeepDescriptionHTML", products[0].DescriptionHTML)
+	}
+	if products[0].Attributes["Tipo de switch"] != "Outemu Blue" || len(products[0].AttributeSections) != 2 {
+		t.Errorf("products[0] attributes = %v in %d sections, expected the 2 spec tables", products[0].Attributes, len(products[0].AttributeSections))
+	}
+	// the fixtures have no structured data
+	if source := products[0].FieldSources[FieldTitle]; source != SourceCSS {
+		t.Errorf("products[0] title came from %q, expected %q", source, SourceCSS)
+	}
+}
+
+func TestHTTPFetcherReplayMissingFixtureIsNotFound(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+
+	_, err := fetcher.Product(context.Background(), "https://articulo.mercadolibre.com.pe/MPE-1-never-recorded-_JM")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Product of an unrecorded url returned %v, expected ErrNotFound", err)
+	}
+}
+
+// newStaticSite serves the product fixture at /product, the fixture without its gallery at
+// /no-gallery and the responses of the other paths, recording the headers of the last request
+func newStaticSite(t *testing.T) (*httptest.Server, *http.Header) {
+	t.Helper()
+	product, err := os.ReadFile(filepath.Join(fixtureTestDir, fixtureKey(fixtureProductUrl)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noGallery := strings.ReplaceAll(string(product), "ui-pdp-gallery__figure__image", "ui-pdp-gallery__lazy")
+	lastHeader := &http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*lastHeader = r.Header.Clone()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/product":
+			w.Write(product)
+		case "/no-gallery":
+			w.Write([]byte(noGallery))
+		case "/captcha":
+			w.Write([]byte("<html><head><title>Mercado Libre</title></head><body><p>¿Eres un robot?</p></body></html>"))
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, lastHeader
+}
+
+func TestHTTPFetcherClassifiesResponses(t *testing.T) {
+	server, lastHeader := newStaticSite(t)
+	opts := DefaultBrowserOptions()
+	opts.RateLimiter = nil
+	opts.Fingerprints = mustFingerprintRotator([]string{"chrome-win-mx"})
+	fetcher := NewHTTPFetcher(opts)
+	defer fetcher.Close()
+
+	product, err := fetcher.Product(context.Background(), server.URL+"/product")
+	if err != nil {
+		t.Fatalf("Product failed: %v", err)
+	}
+	if product.Title != "Teclado Mecánico Redragon Kumara K552 Rgb" || len(product.ImageUrls) != 2 {
+		t.Errorf("Product = %q with %d images, expected the fixture product with 2", product.Title, len(product.ImageUrls))
+	}
+	profile := Fingerprints["chrome-win-mx"]
+	if lastHeader.Get("User-Agent") != profile.UserAgent || lastHeader.Get("Accept-Language") != profile.AcceptLanguage {
+		t.Errorf("request headers = %q, %q, expected the chrome-win-mx profile", lastHeader.Get("User-Agent"), lastHeader.Get("Accept-Language"))
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/captcha", "captcha"},
+		{"/forbidden", "blocked"},
+		{"/gone", "not-found"},
+	}
+	for _, tt := range tests {
+		_, err := fetcher.Product(context.Background(), server.URL+tt.path)
+		if kind := FailureKind(err); kind != tt.expected {
+			t.Errorf("Product(%s) failed with %q (%v), expected %q", tt.path, kind, err, tt.expected)
+		}
+	}
+}
+
+func TestHTTPFetcherClientPerProxyCredentials(t *testing.T) {
+	auth := make(chan string, 1)
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Proxy-Authorization")
+	}))
+	t.Cleanup(proxyServer.Close)
+	fetcher := NewHTTPFetcher(DefaultBrowserOptions())
+	defer fetcher.Close()
+
+	// the same server with other credentials is another account of the pool
+	first := Proxy{Server: proxyServer.URL, Username: "first", Password: "secret"}
+	second := Proxy{Server: proxyServer.URL, Username: "second", Password: "secret"}
+	for _, proxy := range []Proxy{first, second, first} {
+		resp, err := fetcher.client(&proxy).Get("http://articulo.mercadolibre.com.pe/")
+		if err != nil {
+			t.Fatalf("request through proxy %s failed: %v", proxy.Username, err)
+		}
+		resp.Body.Close()
+		header := <-auth
+		if decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic ")); !strings.HasPrefix(string(decoded), proxy.Username+":") {
+			t.Errorf("proxy %s was sent credentials %q", proxy.Username, decoded)
+		}
+	}
+	if fetcher.client(&first) == fetcher.client(&second) || fetcher.client(nil) == fetcher.client(&first) {
+		t.Error("proxies with other credentials share a client")
+	}
+}
+
+func TestAutoFetcherFallsBackOnIncompleteHTML(t *testing.T) {
+	server, _ := newStaticSite(t)
+	opts := DefaultBrowserOptions()
+	opts.RateLimiter = nil
+	starts := 0
+	fetcher := NewAutoFetcher(NewHTTPFetcher(opts), func() (*BrowserManager, error) {
+		starts++
+		return nil, errors.New("no browser in this test")
+	})
+	defer fetcher.Close()
+
+	if _, err := fetcher.Product(context.Background(), server.URL+"/product"); err != nil {
+		t.Fatalf("Product of a complete page failed: %v", err)
+	}
+	if starts != 0 {
+		t.Errorf("browser was started for a complete static page")
+	}
+
+	// without a browser the incomplete static product is still returned
+	product, err := fetcher.Product(context.Background(), server.URL+"/no-gallery")
+	if err != nil || product == nil || len(product.ImageUrls) != 0 {
+		t.Errorf("Product of a page without gallery = %v, %v, expected the product without images", product, err)
+	}
+	if _, err := fetcher.Product(context.Background(), server.URL+"/captcha"); !errors.Is(err, ErrCaptcha) {
+		t.Errorf("Product of a captcha page returned %v, expected ErrCaptcha", err)
+	}
+	if _, err := fetcher.Product(context.Background(), server.URL+"/gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Product of a missing page returned %v, expected ErrNotFound", err)
+	}
+	if starts != 1 {
+		t.Errorf("browser was started %d times, expected once for the first incomplete page", starts)
+	}
+}
+
+func TestParseEngine(t *testing.T) {
+	for _, engine := range Engines {
+		if parsed, err := ParseEngine(strings.ToUpper(string(engine))); err != nil || parsed != engine {
+			t.Errorf("ParseEngine(%q) = %q, %v", engine, parsed, err)
+		}
+	}
+	if _, err := ParseEngine("colly"); err == nil {
+		t.Errorf("ParseEngine(%q) returned no error", "colly")
+	}
+}
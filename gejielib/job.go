@@ -0,0 +1,146 @@
+package gejie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const JobsDir = "jobs"
+
+var (
+	jobMetaBucket    = []byte("meta")
+	jobResultsBucket = []byte("results")
+)
+
+// CrawlJob is a resumable crawl checkpointed to jobs/<id>.db, it holds the url frontier,
+// a few settings needed to resume and the scraped result of every visited url
+type CrawlJob struct {
+	ID       string
+	Path     string
+	Frontier *BoltURLFrontier
+	db       *bolt.DB
+}
+
+// NewCrawlJobID builds a job id from a name and the current epoch, e.g. "teclado-mecanico-1755255900"
+func NewCrawlJobID(name string) string {
+	return fmt.Sprintf("%s-%d", name, time.Now().Unix())
+}
+
+// CreateCrawlJob starts a new job file in dir
+func CreateCrawlJob(dir string, id string) (*CrawlJob, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create jobs directory: %w", err)
+	}
+	return openCrawlJob(filepath.Join(dir, id+".db"), id)
+}
+
+// ResumeCrawlJob opens a job created earlier in dir
+func ResumeCrawlJob(dir string, id string) (*CrawlJob, error) {
+	path := filepath.Join(dir, id+".db")
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no job %s to resume: %w", id, err)
+	}
+	return openCrawlJob(path, id)
+}
+
+func openCrawlJob(path string, id string) (*CrawlJob, error) {
+	// a short timeout fails fast when another run still holds the job open
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open job %s: %w", id, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(jobMetaBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(jobResultsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not initialise job %s: %w", id, err)
+	}
+	frontier, err := NewBoltURLFrontier(db, nil)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not initialise job %s frontier: %w", id, err)
+	}
+	return &CrawlJob{
+		ID:       id,
+		Path:     path,
+		Frontier: frontier,
+		db:       db,
+	}, nil
+}
+
+func (j *CrawlJob) Close() error {
+	return j.db.Close()
+}
+
+func (j *CrawlJob) SetMeta(key string, value string) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobMetaBucket).Put([]byte(key), []byte(value))
+	})
+}
+
+// Meta returns a setting saved with SetMeta, or "" when it was never set
+func (j *CrawlJob) Meta(key string) string {
+	value := ""
+	j.db.View(func(tx *bolt.Tx) error {
+		value = string(tx.Bucket(jobMetaBucket).Get([]byte(key)))
+		return nil
+	})
+	return value
+}
+
+// SaveResult stores the scraped data of url as json
+func (j *CrawlJob) SaveResult(url string, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("could not encode result of %s: %w", url, err)
+	}
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobResultsBucket).Put([]byte(url), data)
+	})
+}
+
+var errNoJobResult = errors.New("no result saved")
+
+// LoadResult decodes the saved data of url into result
+func (j *CrawlJob) LoadResult(url string, result interface{}) error {
+	return j.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(jobResultsBucket).Get([]byte(url))
+		if data == nil {
+			return fmt.Errorf("%s: %w", url, errNoJobResult)
+		}
+		return json.Unmarshal(data, result)
+	})
+}
+
+// ForEachResult calls fn with the raw json of every saved result
+func (j *CrawlJob) ForEachResult(fn func(url string, data []byte) error) error {
+	return j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobResultsBucket).ForEach(func(url, data []byte) error {
+			return fn(string(url), data)
+		})
+	})
+}
+
+// shouldResume tells whether a url of a resumed job still needs scraping, failures other
+// than missing pages are retried since a crash or ban is usually why the job stopped
+func shouldResume(record URLRecord) bool {
+	switch record.Status {
+	case StatusPending:
+		return true
+	case StatusFailed:
+		return record.FailureKind != "not-found"
+	default:
+		return false
+	}
+}
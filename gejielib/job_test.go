@@ -0,0 +1,99 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBoltURLFrontierResume(t *testing.T) {
+	dir := t.TempDir()
+	job, err := CreateCrawlJob(dir, "unit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{
+		"https://articulo.mercadolibre.com.pe/MPE-1",
+		"https://articulo.mercadolibre.com.pe/MPE-2",
+		"https://articulo.mercadolibre.com.pe/MPE-3",
+		"https://articulo.mercadolibre.com.pe/MPE-4",
+	}
+	job.Frontier.BulkAdd(context.Background(), urls)
+	job.Frontier.Add(context.Background(), urls[0])
+	if next, _ := job.Frontier.GetNext(); next != urls[0] {
+		t.Errorf("GetNext() = %q, expected %q", next, urls[0])
+	}
+
+	job.Frontier.MarkVisited(urls[0])
+	if err := job.SaveResult(urls[0], &MeliProduct{Title: "Redragon", Url: urls[0]}); err != nil {
+		t.Fatal(err)
+	}
+	job.Frontier.MarkFailed(urls[1], newScrapeError(ErrTimeout, urls[1], "goto", nil))
+	job.Frontier.MarkFailed(urls[2], newScrapeError(ErrNotFound, urls[2], "goto", nil))
+	if err := job.SetMeta("url", "https://listado.mercadolibre.com.pe/unit-test"); err != nil {
+		t.Fatal(err)
+	}
+	job.Close()
+
+	job, err = ResumeCrawlJob(dir, "unit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer job.Close()
+
+	if got := job.Frontier.URLs(); len(got) != len(urls) || got[3] != urls[3] {
+		t.Errorf("URLs() = %v, expected %v", got, urls)
+	}
+	if meta := job.Meta("url"); meta != "https://listado.mercadolibre.com.pe/unit-test" {
+		t.Errorf("Meta(url) = %q", meta)
+	}
+	record, ok := job.Frontier.Get(urls[1])
+	if !ok || record.Status != StatusFailed || record.Attempts != 1 || record.FailureKind != "timeout" || record.LastError == "" {
+		t.Errorf("record of failed url = %+v", record)
+	}
+	if next, _ := job.Frontier.GetNext(); next != urls[3] {
+		t.Errorf("GetNext() = %q, expected %q", next, urls[3])
+	}
+
+	product := &MeliProduct{}
+	if err := job.LoadResult(urls[0], product); err != nil || product.Title != "Redragon" {
+		t.Errorf("LoadResult = (%+v, %v), expected the saved product", product, err)
+	}
+	if err := job.LoadResult(urls[3], product); !errors.Is(err, errNoJobResult) {
+		t.Errorf("LoadResult of unscraped url returned %v, expected errNoJobResult", err)
+	}
+
+	// the timeout is retried, the missing page is not
+	if requeued := job.Frontier.RequeueFailed(); requeued != 1 {
+		t.Errorf("RequeueFailed() = %d, expected 1", requeued)
+	}
+	if remaining := job.Frontier.CountRemaining(); remaining != 2 {
+		t.Errorf("CountRemaining() = %d, expected 2", remaining)
+	}
+	if next, _ := job.Frontier.GetNext(); next != urls[1] {
+		t.Errorf("GetNext() after requeue = %q, expected %q", next, urls[1])
+	}
+}
+
+func TestResumeMissingCrawlJob(t *testing.T) {
+	if _, err := ResumeCrawlJob(t.TempDir(), "missing"); err == nil {
+		t.Error("resuming a missing job returned no error")
+	}
+}
+
+func TestShouldResume(t *testing.T) {
+	tests := []struct {
+		record   URLRecord
+		expected bool
+	}{
+		{URLRecord{Status: StatusPending}, true},
+		{URLRecord{Status: StatusVisited}, false},
+		{URLRecord{Status: StatusFailed, FailureKind: "timeout"}, true},
+		{URLRecord{Status: StatusFailed, FailureKind: "not-found"}, false},
+	}
+	for _, test := range tests {
+		if got := shouldResume(test.record); got != test.expected {
+			t.Errorf("shouldResume(%+v) = %v, expected %v", test.record, got, test.expected)
+		}
+	}
+}
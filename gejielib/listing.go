@@ -0,0 +1,120 @@
+package gejie
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+// ListingCard is a product as a search page shows it, before its own page is visited
+type ListingCard struct {
+	Url      string
+	Title    string
+	Price    Price
+	Shipping Shipping
+}
+
+// CardFilter tells which search cards a search collects, nil collects every card
+type CardFilter func(card ListingCard) bool
+
+// fullCards keeps the cards of products meli fulfils
+func fullCards(card ListingCard) bool {
+	return card.Shipping.Full()
+}
+
+// apply returns the cards keep keeps
+func (keep CardFilter) apply(cards []ListingCard) []ListingCard {
+	if keep == nil {
+		return cards
+	}
+	kept := []ListingCard{}
+	for _, card := range cards {
+		if keep(card) {
+			kept = append(kept, card)
+		}
+	}
+	return kept
+}
+
+// listingCardsFromHTML reads the product cards of a search page, ads linking through click
+// trackers are left out. pages whose cards are not found give a card per product link
+func listingCardsFromHTML(doc *goquery.Document, pageUrl string) []ListingCard {
+	selectors := selectorsFor(SiteMeli, pageUrl)
+	currency := utils.DomainToCurrencyCode(utils.Domain(pageUrl))
+	cards := []ListingCard{}
+	selectors.Find(doc, SelectorListingCards).Each(func(_ int, s *goquery.Selection) {
+		link := selectors.Find(s, SelectorProductLinks).First()
+		urls := canonicalProductLinks(pageUrl, []string{link.AttrOr("href", "")})
+		if len(urls) == 0 {
+			return
+		}
+		card := ListingCard{Url: urls[0], Title: strings.TrimSpace(link.Text()), Price: Price{CurrencyCode: currency}}
+		card.Price.AmountCents, _ = moneyCents(selectors.Find(s, SelectorCardPrice).First(), selectors)
+		fillPriceTerms(&card.Price, s, selectors, cardPriceFields)
+		card.Shipping = shippingFromHTML(s, selectors, cardShippingFields, time.Now())
+		cards = append(cards, card)
+	})
+	if len(cards) > 0 {
+		return cards
+	}
+	links := selectors.Find(doc, SelectorProductLinks).Map(func(_ int, s *goquery.Selection) string {
+		return s.AttrOr("href", "")
+	})
+	for _, url := range canonicalProductLinks(pageUrl, links) {
+		cards = append(cards, ListingCard{Url: url, Price: Price{CurrencyCode: currency}})
+	}
+	return cards
+}
+
+// ScrapeListingCards collects up to maxItems product cards following the next page links of the search,
+// only the cards keep keeps count toward maxItems
+func ScrapeListingCards(ctx context.Context, loader DocumentLoader, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error) {
+	allCards := []ListingCard{}
+	seen := NewURLFrontier()
+	pageUrl := searchUrl
+	for currentPage := 1; len(allCards) < maxItems; currentPage++ {
+		doc, finalUrl, err := loader.Document(ctx, pageUrl)
+		if err != nil {
+			return allCards, err
+		}
+		cards := []ListingCard{}
+		for _, card := range listingCardsFromHTML(doc, finalUrl) {
+			if seen.AddEntries(ctx, []FrontierEntry{{Url: card.Url}}) == 1 {
+				cards = append(cards, card)
+			}
+		}
+		fmt.Printf("found %d product links on page %d\n", len(cards), currentPage)
+		if currentPage == 1 && len(cards) == 0 {
+			return allCards, newScrapeError(errIncompleteStatic, searchUrl, "find product links", nil)
+		}
+		cards = keep.apply(cards)
+		if remaining := maxItems - len(allCards); len(cards) > remaining {
+			cards = cards[:remaining]
+		}
+		allCards = append(allCards, cards...)
+
+		next := selectorsFor(SiteMeli, finalUrl).Find(doc, SelectorNextPage).First().AttrOr("href", "")
+		if next == "" || len(allCards) >= maxItems {
+			break
+		}
+		if pageUrl, err = ResolveURL(finalUrl, next); err != nil {
+			log.Printf("could not follow next page link %q: %v", next, err)
+			break
+		}
+	}
+	return allCards, nil
+}
+
+// cardUrls lists the urls of cards in order
+func cardUrls(cards []ListingCard) []string {
+	urls := make([]string, 0, len(cards))
+	for _, card := range cards {
+		urls = append(urls, card.Url)
+	}
+	return urls
+}
@@ -1,33 +1,51 @@
 package gejie
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/url"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/playwright-community/playwright-go"
-	"github.com/zshanhui/gejiezhipin/utils"
 )
 
-type Price struct {
-	AmountCents  int
-	CurrencyCode utils.CurrencyCode
-}
-
 type MeliProduct struct {
-	Title              string
-	Price              Price
-	Url                string
-	ReviewCount        *uint32
-	Rating             *float32
-	ImageUrls          []string
-	SoldMoreThan       *uint32
+	Title        string
+	Price        Price
+	Url          string
+	ReviewCount  *uint32
+	Rating       *float32
+	ImageUrls    []string
+	SoldMoreThan *uint32
+	Condition    Condition
+	// AvailableQuantity is the stock shown next to the buy button, nil when none is shown
+	AvailableQuantity *AvailableQuantity
+	Status            ListingStatus
+	// Warranty is the warranty text, e.g. "Garantía de fábrica: 12 meses"
+	Warranty string
+	// DescriptionContent is the plain text of the full description, paragraphs separated by an empty line
 	DescriptionContent string
-	StoreInfo          MeliStoreInfo
+	// DescriptionHTML is the sanitized html of the description, only kept with BrowserOptions.KeepDescriptionHTML
+	DescriptionHTML string
+	// Attributes holds every row of the spec tables by name, e.g. "Marca": "Redragon"
+	Attributes map[string]string
+	// AttributeSections keeps the spec rows grouped and ordered as the page shows them
+	AttributeSections []MeliAttributeSection
+	// Variants lists the picker combinations of the product, the one the page opened with first
+	Variants  []MeliVariant
+	Shipping  Shipping
+	StoreInfo MeliStoreInfo
+	// Card is the product as the search page showed it, nil when it was scraped from its url alone
+	Card *ListingCard
+	// FieldSources records where each field was read from, keyed by FieldTitle, FieldPrice, ...
+	FieldSources map[string]FieldSource
+	// variantLinks are the picker options of the page, followed to enumerate Variants
+	variantLinks []string
 }
 
 type MeliStoreInfo struct {
@@ -38,9 +56,19 @@ type MeliStoreInfo struct {
 }
 
 type BrowserManager struct {
-	pw      *playwright.Playwright
-	browser playwright.Browser
-	context playwright.BrowserContext
+	pw       *playwright.Playwright
+	browser  playwright.Browser
+	context  playwright.BrowserContext
+	opts     *BrowserOptions
+	fixtures *FixtureStore
+	// proxy is the proxy of the manager context, nil without a proxy pool
+	proxy *Proxy
+
+	// mu guards the product sessions and closed
+	mu       sync.Mutex
+	sessions map[*browserSession]bool
+	idle     []*browserSession
+	closed   bool
 }
 
 type BrowserOptions struct {
@@ -48,20 +76,45 @@ type BrowserOptions struct {
 	BlockImages bool
 	BlockMedia  bool
 	BlockFonts  bool
-	UserAgent   string
-	Timeout     float64
+	// UserAgent overrides the user agent of the fingerprint profiles when not empty
+	UserAgent string
+	Timeout   float64
+	// Fingerprints gives every new context the next profile, nil presents the first default meli profile
+	Fingerprints *FingerprintRotator
+	// NavigationTimeout bounds every goto in milliseconds, 0 keeps the playwright default
+	NavigationTimeout float64
+	// PageRecycleAfter is how many navigations a reused product page makes before it is replaced
+	// by a fresh one, 0 never replaces it
+	PageRecycleAfter int
+	// RateLimiter spaces out the navigations of every page of the manager, share one across
+	// managers of the same run. nil navigates without waiting
+	RateLimiter *RateLimiter
+	// Proxies gives every new context a proxy of the pool, share one across managers of the same run.
+	// nil connects directly
+	Proxies *ProxyPool
+	// FixtureMode and FixtureDir record page documents to disk or replay them offline
+	FixtureMode FixtureMode
+	FixtureDir  string
+	// KeepDescriptionHTML keeps the sanitized description html of products next to its plain text
+	KeepDescriptionHTML bool
+	// MaxVariants is how many variants of a product are enumerated by following its picker options,
+	// 0 or 1 keeps the variant the product page opened with only
+	MaxVariants int
 }
 
 const browserHeadlessMode = false
 
 func DefaultBrowserOptions() *BrowserOptions {
 	return &BrowserOptions{
-		Headless:    browserHeadlessMode,
-		BlockImages: true,
-		BlockMedia:  true,
-		BlockFonts:  true,
-		UserAgent:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		Timeout:     15000,
+		Headless:          browserHeadlessMode,
+		BlockImages:       true,
+		BlockMedia:        true,
+		BlockFonts:        true,
+		Fingerprints:      mustFingerprintRotator(DefaultMeliFingerprints),
+		Timeout:           15000,
+		NavigationTimeout: 8000,
+		PageRecycleAfter:  25,
+		RateLimiter:       NewRateLimiter(DefaultHostLimit()),
 	}
 }
 
@@ -76,26 +129,79 @@ func NewBrowserManager(opts *BrowserOptions) (*BrowserManager, error) {
 		return nil, err
 	}
 
-	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
+	launchOptions := playwright.BrowserTypeLaunchOptions{
 		Headless: playwright.Bool(opts.Headless),
 		Timeout:  playwright.Float(opts.Timeout),
-	})
+	}
+	if opts.Proxies != nil {
+		// chromium only honours per context proxies when launched with one, every context overrides it
+		launchOptions.Proxy = &playwright.Proxy{Server: "http://per-context"}
+	}
+	browser, err := pw.Chromium.Launch(launchOptions)
 	if err != nil {
 		pw.Stop()
 		return nil, err
 	}
 
-	context, err := browser.NewContext(playwright.BrowserNewContextOptions{
-		UserAgent: playwright.String(opts.UserAgent),
-	})
+	bm := &BrowserManager{
+		pw:       pw,
+		browser:  browser,
+		opts:     opts,
+		sessions: map[*browserSession]bool{},
+	}
+	if opts.FixtureMode != FixtureOff {
+		bm.fixtures = NewFixtureStore(opts.FixtureDir)
+	}
+
+	context, proxy, err := bm.newContext(nil)
 	if err != nil {
 		browser.Close()
 		pw.Stop()
 		return nil, err
 	}
+	bm.context = context
+	bm.proxy = proxy
+
+	return bm, nil
+}
+
+// NewContext creates a browser context with the manager's resource blocking and fixture routing,
+// presenting the next fingerprint profile. opts overrides the blocking and user agent of the
+// manager options when not nil
+func (bm *BrowserManager) NewContext(opts *BrowserOptions) (playwright.BrowserContext, error) {
+	context, _, err := bm.newContext(opts)
+	return context, err
+}
+
+// newContext creates a context like NewContext, returning the proxy of the pool it connects through
+func (bm *BrowserManager) newContext(opts *BrowserOptions) (playwright.BrowserContext, *Proxy, error) {
+	if opts == nil {
+		opts = bm.opts
+	}
+
+	contextOptions := bm.opts.Fingerprints.Next().contextOptions(opts.UserAgent)
+	var proxy *Proxy
+	if bm.opts.Proxies != nil {
+		next, err := bm.opts.Proxies.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		proxy = &next
+		contextOptions.Proxy = next.playwright()
+	}
+	context, err := bm.browser.NewContext(contextOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.NavigationTimeout > 0 {
+		context.SetDefaultNavigationTimeout(opts.NavigationTimeout)
+	}
 
-	if opts.BlockImages || opts.BlockMedia || opts.BlockFonts {
-		context.Route("**/*", func(route playwright.Route) {
+	if opts.BlockImages || opts.BlockMedia || opts.BlockFonts || bm.fixtures != nil {
+		err = context.Route("**/*", func(route playwright.Route) {
+			if bm.fixtures != nil && routeFixtures(route, bm.fixtures, bm.opts.FixtureMode) {
+				return
+			}
 			rt := route.Request().ResourceType()
 			switch rt {
 			case "image":
@@ -116,13 +222,38 @@ func NewBrowserManager(opts *BrowserOptions) (*BrowserManager, error) {
 			}
 			route.Continue()
 		})
+		if err != nil {
+			context.Close()
+			return nil, nil, err
+		}
+	}
+
+	return context, proxy, nil
+}
+
+// RateLimiter returns the limiter shared by the pages of the manager, nil when navigations are not limited
+func (bm *BrowserManager) RateLimiter() *RateLimiter {
+	return bm.opts.RateLimiter
+}
+
+// Proxies returns the proxy pool the contexts of the manager draw from, nil when connecting directly
+func (bm *BrowserManager) Proxies() *ProxyPool {
+	return bm.opts.Proxies
+}
+
+// reportProxy records the outcome of a navigation of the manager context against its proxy
+func (bm *BrowserManager) reportProxy(err error) {
+	if bm.proxy != nil {
+		bm.opts.Proxies.Report(*bm.proxy, err)
 	}
+}
 
-	return &BrowserManager{
-		pw:      pw,
-		browser: browser,
-		context: context,
-	}, nil
+// Goto navigates page to url once the rate limiter lets the url's host be visited
+func (bm *BrowserManager) Goto(ctx context.Context, page playwright.Page, url string, options ...playwright.PageGotoOptions) (playwright.Response, error) {
+	if err := bm.RateLimiter().Wait(ctx, url); err != nil {
+		return nil, err
+	}
+	return page.Goto(url, options...)
 }
 
 func (bm *BrowserManager) NewPage() (playwright.Page, error) {
@@ -135,7 +266,22 @@ func (bm *BrowserManager) ClosePage(page playwright.Page) {
 	}
 }
 
+// Close closes every context of the manager, including product sessions still in use, then the
+// browser and the playwright driver. calling it again does nothing
 func (bm *BrowserManager) Close() {
+	bm.mu.Lock()
+	if bm.closed {
+		bm.mu.Unlock()
+		return
+	}
+	bm.closed = true
+	sessions := bm.sessions
+	bm.sessions, bm.idle = nil, nil
+	bm.mu.Unlock()
+
+	for session := range sessions {
+		session.close()
+	}
 	if bm.context != nil {
 		bm.context.Close()
 	}
@@ -155,163 +301,360 @@ func (bm *BrowserManager) GetBrowser() playwright.Browser {
 	return bm.browser
 }
 
-func RunMeliSearch(searchUrl *string, maxItemsInput int8, createCsv bool) []MeliProduct {
+// MeliSearchResult holds the products of a search run and the product urls that could not be scraped
+type MeliSearchResult struct {
+	Products []MeliProduct
+	// Cards are the search cards of the products in search rank, failed products included. a resumed
+	// job only has the cards its checkpointed products kept
+	Cards    []ListingCard
+	Failures []ScrapeFailure
+	// Interrupted counts the products the run was stopped before scraping, they are not failures
+	// and a resumed job scrapes them
+	Interrupted int
+}
+
+type MeliSearchOptions struct {
+	MaxItems  int
+	CreateCsv bool
+	// Concurrency is the number of product pages scraped at once, each worker keeps its own browser context
+	Concurrency int
+	// Job checkpoints every product to disk, a job that already has links resumes instead of searching again
+	Job *CrawlJob
+	// Retry queues products that failed with a retriable error again, nil tries every product once
+	Retry *RetryPolicy
+	// RespectRobots skips products robots.txt disallows and applies its Crawl-delay,
+	// fixtures being replayed are never checked
+	RespectRobots bool
+	// OnlyFull keeps the products meli fulfils only, judged by their search cards before any product
+	// page is loaded, so MaxItems counts the products kept
+	OnlyFull bool
+	// FlattenVariants returns, and exports, a product per variant instead of one per product page
+	FlattenVariants bool
+}
+
+func DefaultMeliSearchOptions() *MeliSearchOptions {
+	return &MeliSearchOptions{
+		MaxItems:      10,
+		CreateCsv:     false,
+		Concurrency:   1,
+		Retry:         DefaultRetryPolicy(),
+		RespectRobots: true,
+	}
+}
+
+func RunMeliSearch(ctx context.Context, searchUrl *string, opts *MeliSearchOptions) (*MeliSearchResult, error) {
+	bm, err := NewBrowserManager(DefaultBrowserOptions())
+	if err != nil {
+		return nil, fmt.Errorf("could not start browser: %w", err)
+	}
+	defer bm.Close()
+
+	return RunMeliSearchWithBrowser(ctx, bm, searchUrl, opts)
+}
+
+// RunMeliSearchWithBrowser runs a search crawl with an existing browser manager,
+// e.g. one replaying saved fixtures. products that fail are skipped and listed in the result failures,
+// an error is only returned when the search itself could not be scraped.
+// when ctx is cancelled the products scraped so far are still returned (and written to csv) with the context error
+func RunMeliSearchWithBrowser(ctx context.Context, bm *BrowserManager, searchUrl *string, opts *MeliSearchOptions) (*MeliSearchResult, error) {
+	return RunMeliSearchWithFetcher(ctx, NewBrowserFetcher(bm), searchUrl, opts)
+}
+
+// RunMeliSearchWithFetcher runs a search like RunMeliSearchWithBrowser, scraping with fetcher
+func RunMeliSearchWithFetcher(ctx context.Context, fetcher Fetcher, searchUrl *string, opts *MeliSearchOptions) (*MeliSearchResult, error) {
 	if searchUrl == nil {
 		defaultUrl := exampleMercadoLibreKeyboard
 		searchUrl = &defaultUrl
 	}
-	pw, err := playwright.Run()
-	if err != nil {
-		log.Fatalf("could not start playwright: %v", err)
+	if opts == nil {
+		opts = DefaultMeliSearchOptions()
 	}
-	defer pw.Stop()
 
-	browser, err := pw.Chromium.Launch(playwright.BrowserTypeLaunchOptions{
-		Headless: playwright.Bool(browserHeadlessMode),
-	})
-	if err != nil {
-		log.Fatalf("could not launch browser: %v", err)
+	var productLinks []string
+	cards := map[string]*ListingCard{}
+	if opts.Job != nil && opts.Job.Frontier.Count() > 0 {
+		// links were collected by the run that created the job, only unfinished products are scraped again
+		productLinks = opts.Job.Frontier.URLs()
+		fmt.Printf("resuming job %s with %d product links\n", opts.Job.ID, len(productLinks))
+	} else {
+		var robots *RobotsChecker
+		if opts.RespectRobots && !fetcher.Offline() {
+			robots = NewRobotsChecker(nil, fetcher.RateLimiter())
+		}
+		if !robots.Allowed(ctx, *searchUrl) {
+			return nil, newScrapeError(ErrDisallowed, *searchUrl, "check robots.txt", nil)
+		}
+		var keep CardFilter
+		if opts.OnlyFull {
+			keep = fullCards
+		}
+		searchCards, err := fetcher.ListingCards(ctx, *searchUrl, opts.MaxItems, keep)
+		if ctx.Err() != nil {
+			return &MeliSearchResult{Products: []MeliProduct{}, Cards: []ListingCard{}, Failures: []ScrapeFailure{}}, ctx.Err()
+		}
+		if err != nil && len(searchCards) == 0 {
+			return nil, err
+		}
+		for i := range searchCards {
+			cards[searchCards[i].Url] = &searchCards[i]
+		}
+		productLinks = robots.Filter(ctx, cardUrls(searchCards))
+		if opts.Job != nil {
+			opts.Job.Frontier.BulkAdd(ctx, productLinks)
+		}
 	}
-	defer browser.Close()
+	fmt.Printf("\ntotal product links scraped: %d\n", len(productLinks))
 
-	// Create new browser context and page
-	context, err := browser.NewContext()
-	if err != nil {
-		log.Fatalf("could not create context: %v", err)
+	toScrape := productLinks
+	if opts.Job != nil {
+		toScrape = []string{}
+		for _, link := range productLinks {
+			if record, ok := opts.Job.Frontier.Get(link); !ok || shouldResume(record) {
+				toScrape = append(toScrape, link)
+			}
+		}
+		fmt.Printf("%d of %d products left to scrape in job %s\n", len(toScrape), len(productLinks), opts.Job.ID)
 	}
-	defer context.Close()
 
-	// Speed up navigation: block heavy resources not needed for scraping links
-	_ = context.Route("**/*", func(route playwright.Route) {
-		rt := route.Request().ResourceType()
-		switch rt {
-		case "image", "media", "font":
-			_ = route.Abort()
-		default:
-			_ = route.Continue()
+	newScraper := func() (productScraper, error) {
+		return &fetcherProductScraper{ctx: ctx, fetcher: fetcher, cards: cards}, nil
+	}
+	var onResult func(url string, product *MeliProduct, err error)
+	if opts.Job != nil {
+		onResult = func(url string, product *MeliProduct, err error) {
+			checkpointProduct(opts.Job, url, product, err)
 		}
-	})
+	}
+	// the fetcher waits on its rate limiter itself
+	products, errs := scrapeProductPages(ctx, toScrape, opts.Concurrency, nil, opts.Retry, newScraper, onResult)
+
+	scraped := make(map[string]int, len(toScrape))
+	for i, url := range toScrape {
+		scraped[url] = i
+	}
+	result := &MeliSearchResult{
+		Products: []MeliProduct{},
+		Cards:    []ListingCard{},
+		Failures: []ScrapeFailure{},
+	}
+	// assemble in search rank, taking products finished by earlier runs of the job from the checkpoint
+	for _, url := range productLinks {
+		if card := cards[url]; card != nil {
+			result.Cards = append(result.Cards, *card)
+		}
+		i, ok := scraped[url]
+		if !ok {
+			product := MeliProduct{}
+			if err := opts.Job.LoadResult(url, &product); err != nil {
+				record, _ := opts.Job.Frontier.Get(url)
+				result.Failures = append(result.Failures, ScrapeFailure{Url: url, Err: fmt.Errorf("failed in an earlier run: %s", record.LastError)})
+				continue
+			}
+			if product.Card != nil {
+				result.Cards = append(result.Cards, *product.Card)
+			}
+			result.Products = append(result.Products, product)
+			continue
+		}
+		if errs[i] != nil && isInterrupted(errs[i]) {
+			result.Interrupted++
+			continue
+		}
+		if errs[i] != nil {
+			log.Printf("skipping product: %v", errs[i])
+			result.Failures = append(result.Failures, ScrapeFailure{Url: url, Err: errs[i]})
+			continue
+		}
+		result.Products = append(result.Products, *products[i])
+	}
+	fmt.Printf("total meli products scraped: %d, failed: %d\n\n", len(result.Products), len(result.Failures))
+	if opts.OnlyFull {
+		// products of a resumed job may not have kept their card
+		result.Products = fullProducts(result.Products)
+		fmt.Printf("%d products are fulfilled by meli\n", len(result.Products))
+	}
+	if opts.FlattenVariants {
+		result.Products = FlattenVariants(result.Products)
+	}
 
-	pageIndex, err := context.NewPage()
+	searchUrlParsed, _ := url.Parse(*searchUrl)
+	// fmt.Printf("searchUrl path: %s\n", searchUrlParsed.Path)
+	if len(searchUrlParsed.Path) > 0 && searchUrlParsed.Path[0] == '/' {
+		searchUrlParsed.Path = searchUrlParsed.Path[1:]
+	}
+
+	if opts.CreateCsv {
+		fmt.Printf("creating csv for %s, number of products: %d\n", searchUrlParsed.Path, len(result.Products))
+		if err := CreateMeliProductCsv(result.Products, searchUrlParsed.Path); err != nil {
+			return result, err
+		}
+	}
+
+	return result, ctx.Err()
+}
+
+// checkpointProduct records the outcome of a product scrape in the job, cancelled scrapes stay pending
+func checkpointProduct(job *CrawlJob, url string, product *MeliProduct, err error) {
 	if err != nil {
-		log.Fatalf("could not create page: %v", err)
+		if isInterrupted(err) {
+			return
+		}
+		job.Frontier.MarkFailed(url, err)
+		return
+	}
+	if saveErr := job.SaveResult(url, product); saveErr != nil {
+		log.Printf("could not checkpoint product %s: %v", url, saveErr)
+		return
+	}
+	job.Frontier.MarkVisited(url)
+}
+
+// collectSearchCards opens the search page and collects up to maxItems product cards across its pages
+func collectSearchCards(ctx context.Context, bm *BrowserManager, searchUrl string, maxItems int, keep CardFilter) ([]ListingCard, error) {
+	// the manager context blocks heavy resources not needed for scraping links
+	pageIndex, err := bm.NewPage()
+	if err != nil {
+		return nil, fmt.Errorf("could not create page: %w", err)
 	}
 	defer pageIndex.Close()
+	stopClosing := context.AfterFunc(ctx, func() { pageIndex.Close() })
+	defer stopClosing()
 
 	// Navigate and wait only for DOMContentLoaded to avoid long waits for lazy resources
-	_, err = pageIndex.Goto(*searchUrl, playwright.PageGotoOptions{
+	resp, err := bm.Goto(ctx, pageIndex, searchUrl, playwright.PageGotoOptions{
 		WaitUntil: playwright.WaitUntilStateDomcontentloaded,
 	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil {
-		log.Fatalf("failed to navigate: %v", err)
+		err = wrapBrowserError(searchUrl, "goto", err)
+		bm.reportProxy(err)
+		return nil, err
 	}
+	if err := detectPage(pageIndex, resp, searchUrl); err != nil {
+		bm.reportProxy(err)
+		return nil, err
+	}
+	bm.reportProxy(nil)
 
 	// Wait just for product links to appear instead of network idle
-	err = pageIndex.Locator(productLinksSelector).WaitFor(playwright.LocatorWaitForOptions{
+	err = pageIndex.Locator(selectorsFor(SiteMeli, searchUrl).Any(SelectorProductLinks)).WaitFor(playwright.LocatorWaitForOptions{
 		State: playwright.WaitForSelectorStateAttached,
 	})
-	if err != nil {
-		log.Fatalf("product links did not appear: %v", err)
-		pageIndex.Close()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
-
-	fmt.Print("page loaded, proceeding to scrape links")
-
-	productLinks := ScrapeProductLinksWithPagination(pageIndex, int(maxItemsInput))
-	fmt.Printf("\ntotal product links scraped: %d\n", len(productLinks))
-
-	scrapeProducts := []MeliProduct{}
-	for _, url := range productLinks {
-		product := scrapeProductPage(browser, url)
-		if product != nil {
-			scrapeProducts = append(scrapeProducts, *product)
-		} else {
-			fmt.Print("product is nil")
+	if err != nil {
+		// a captcha or login wall may only show up once the page scripts ran
+		if detected := detectPage(pageIndex, nil, searchUrl); detected != nil {
+			bm.reportProxy(detected)
+			return nil, detected
 		}
-	}
-	fmt.Printf("total meli products scraped: %d\n\n", len(scrapeProducts))
-
-	searchUrlParsed, _ := url.Parse(*searchUrl)
-	// fmt.Printf("searchUrl path: %s\n", searchUrlParsed.Path)
-	if len(searchUrlParsed.Path) > 0 && searchUrlParsed.Path[0] == '/' {
-		searchUrlParsed.Path = searchUrlParsed.Path[1:]
+		return nil, wrapBrowserError(searchUrl, "wait for product links", err)
 	}
 
-	if createCsv {
-		fmt.Printf("creating csv for %s, number of products: %d\n", searchUrlParsed.Path, len(scrapeProducts))
-		CreateMeliProductCsv(scrapeProducts, searchUrlParsed.Path)
-	}
+	fmt.Print("page loaded, proceeding to scrape links")
 
-	return scrapeProducts
+	return ScrapeListingCardsWithPagination(ctx, pageIndex, maxItems, bm.RateLimiter(), keep)
 }
 
 func ScrapeSinglePageProductLinks(page playwright.Page) ([]string, error) {
-	productLinks, err := page.Locator(productLinksSelector).All()
+	productLinks, err := selectorsFor(SiteMeli, page.URL()).Locator(page, SelectorProductLinks).All()
 	if err != nil {
-		return []string{}, fmt.Errorf("could not extract product links: %w", err)
+		return []string{}, wrapBrowserError(page.URL(), "extract product links", err)
 	}
 
-	productLinkUrls := []string{}
-	baseURL, _ := url.Parse(page.URL())
+	hrefs := []string{}
 	for _, productLink := range productLinks {
 		linkUrl, err := productLink.GetAttribute("href")
+		if err != nil {
+			log.Printf("could not read product link url: %v", err)
+			continue
+		}
+		hrefs = append(hrefs, linkUrl)
+	}
+	return canonicalProductLinks(page.URL(), hrefs), nil
+}
 
+// canonicalProductLinks resolves the product links of the search page at pageUrl, dropping ad
+// links and search tracking params
+func canonicalProductLinks(pageUrl string, hrefs []string) []string {
+	productLinkUrls := []string{}
+	normalizer := DefaultURLNormalizer()
+	for _, linkUrl := range hrefs {
 		// skip click1 links since they are not product links
 		if strings.HasPrefix(linkUrl, "https://click1") {
 			continue
 		}
-		if err != nil {
-			log.Fatalf("could not parse product link url")
-			continue
-		}
 		if linkUrl == "" {
 			continue
 		}
 
-		ref, err := url.Parse(linkUrl)
+		abs, err := ResolveURL(pageUrl, linkUrl)
 		if err != nil {
 			// skip malformed URLs
 			continue
 		}
-		abs := baseURL.ResolveReference(ref)
-		// strip query params and fragments
-		abs.RawQuery = ""
-		abs.Fragment = ""
-
-		productLinkUrls = append(productLinkUrls, abs.String())
+		// strips search tracking params and fragments
+		canonical, _, err := normalizer.Normalize(abs)
+		if err != nil {
+			continue
+		}
+		productLinkUrls = append(productLinkUrls, canonical)
 	}
+	return productLinkUrls
+}
 
-	return productLinkUrls, nil
+// ScrapeProductLinksWithPagination collects up to maxItems product links following the next page button,
+// links collected before a pagination error or cancellation are returned along with the error.
+// an item linked more than once, e.g. as an ad and as a result, is only collected once
+func ScrapeProductLinksWithPagination(ctx context.Context, page playwright.Page, maxItems int, limiter *RateLimiter) ([]string, error) {
+	cards, err := ScrapeListingCardsWithPagination(ctx, page, maxItems, limiter, nil)
+	return cardUrls(cards), err
 }
 
-func ScrapeProductLinksWithPagination(page playwright.Page, maxItems int) []string {
-	allProductLinks := []string{}
+// ScrapeListingCardsWithPagination collects up to maxItems product cards like ScrapeProductLinksWithPagination
+// collects their links, only the cards keep keeps count toward maxItems
+func ScrapeListingCardsWithPagination(ctx context.Context, page playwright.Page, maxItems int, limiter *RateLimiter, keep CardFilter) ([]ListingCard, error) {
+	allCards := []ListingCard{}
+	seen := NewURLFrontier()
 	currentPage := 1
 
-	for len(allProductLinks) < maxItems {
+	for len(allCards) < maxItems {
+		if err := ctx.Err(); err != nil {
+			return allCards, err
+		}
 		fmt.Printf("scraping page %d...\n", currentPage)
 
-		curPageProductLinks, err := ScrapeSinglePageProductLinks(page)
+		pageCards, err := pageListingCards(page)
 		if err != nil {
-			fmt.Printf("error scraping product links: %v", err)
-			return []string{}
+			return allCards, err
+		}
+		fmt.Printf("found %d product links on page %d\n", len(pageCards), currentPage)
+		curPageCards := []ListingCard{}
+		for _, card := range pageCards {
+			if seen.AddEntries(ctx, []FrontierEntry{{Url: card.Url}}) == 1 {
+				curPageCards = append(curPageCards, card)
+			}
 		}
-		fmt.Printf("found %d product links on page %d\n", len(curPageProductLinks), currentPage)
+		curPageCards = keep.apply(curPageCards)
 
-		remainingItems := maxItems - len(allProductLinks)
-		if len(curPageProductLinks) <= remainingItems {
-			allProductLinks = append(allProductLinks, curPageProductLinks...)
+		remainingItems := maxItems - len(allCards)
+		if len(curPageCards) <= remainingItems {
+			allCards = append(allCards, curPageCards...)
 		} else {
-			allProductLinks = append(allProductLinks, curPageProductLinks[:remainingItems]...)
+			allCards = append(allCards, curPageCards[:remainingItems]...)
 		}
 
-		if len(allProductLinks) >= maxItems {
+		if len(allCards) >= maxItems {
 			fmt.Printf("reached max items (%d), stopping pagination\n", maxItems)
 			break
 		}
 
 		// next page
-		nextButton := page.Locator(string(paginationNextButtonSelector))
+		selectors := selectorsFor(SiteMeli, page.URL())
+		nextButton := selectors.Locator(page, SelectorNextPage)
 		nextExists, err := nextButton.Count()
 		if err != nil {
 			log.Printf("error checking next page button: %v", err)
@@ -322,14 +665,17 @@ func ScrapeProductLinksWithPagination(page playwright.Page, maxItems int) []stri
 			break
 		}
 
-		// click next button
+		// the click navigates to the next page, so it waits its turn like any other navigation
+		if err := limiter.Wait(ctx, page.URL()); err != nil {
+			return allCards, err
+		}
 		err = nextButton.Click()
 		if err != nil {
-			log.Printf("error clicking next page button: %w", err)
+			log.Printf("error clicking next page button: %v", err)
 			break
 		}
 
-		err = page.Locator(string(productLinksSelector)).Last().WaitFor(playwright.LocatorWaitForOptions{
+		err = page.Locator(selectors.Any(SelectorProductLinks)).Last().WaitFor(playwright.LocatorWaitForOptions{
 			State:   playwright.WaitForSelectorStateAttached,
 			Timeout: playwright.Float(5000),
 		})
@@ -338,250 +684,185 @@ func ScrapeProductLinksWithPagination(page playwright.Page, maxItems int) []stri
 			break
 		}
 
-		// Sleep for 1 second between pages
-		time.Sleep(time.Duration(1+rand.Intn(3)) * time.Second)
 		currentPage++
 	}
 
-	fmt.Printf("total products links scraped across %d pages: %d\n", currentPage, len(allProductLinks))
-	return allProductLinks
+	fmt.Printf("total products links scraped across %d pages: %d\n", currentPage, len(allCards))
+	return allCards, nil
 }
 
-func ScrapeProductPageDirect(url string) *MeliProduct {
-	bm, err := NewBrowserManager(&BrowserOptions{
-		Headless:    false,
-		BlockImages: false,
-		BlockMedia:  false,
-		BlockFonts:  false,
-	})
+// pageListingCards reads the product cards of the search page open in page
+func pageListingCards(page playwright.Page) ([]ListingCard, error) {
+	content, err := page.Content()
 	if err != nil {
-		fmt.Printf("could not create browser manager: %v", err)
+		return nil, wrapBrowserError(page.URL(), "read page content", err)
 	}
-	return scrapeProductPage(bm.browser, url)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return nil, newScrapeError(ErrLayoutChanged, page.URL(), "parse page content", err)
+	}
+	return listingCardsFromHTML(doc, page.URL()), nil
 }
 
-func scrapeProductPage(browser playwright.Browser, url string) *MeliProduct {
-	var productPage playwright.Page
-	defaultTimeout := float64(8000)
+// ScrapeProduct scrapes a single product page with an existing browser manager
+func ScrapeProduct(ctx context.Context, bm *BrowserManager, url string) (*MeliProduct, error) {
+	return scrapeProductPage(ctx, bm, url)
+}
 
-	// create new context allowing media/images to load
-	context, err := browser.NewContext()
-	if err != nil {
-		log.Fatalf("could not create context: %v", err)
+// ScrapeProductPageDirect scrapes a single product page on a browser of its own, opts defaults to a
+// visible browser loading all resources. use ScrapeProduct to scrape with a browser already running
+func ScrapeProductPageDirect(ctx context.Context, url string, opts *BrowserOptions) (*MeliProduct, error) {
+	if opts == nil {
+		opts = &BrowserOptions{
+			Headless:    false,
+			BlockImages: false,
+			BlockMedia:  false,
+			BlockFonts:  false,
+		}
 	}
-	defer context.Close()
-
-	productPage, err = context.NewPage()
+	bm, err := NewBrowserManager(opts)
 	if err != nil {
-		log.Fatalf("could not create page: %v", err)
+		return nil, fmt.Errorf("could not create browser manager: %w", err)
 	}
-	defer productPage.Close()
+	defer bm.Close()
+	return scrapeProductPage(ctx, bm, url)
+}
 
-	_, err = productPage.Goto(url, playwright.PageGotoOptions{
-		Timeout: &defaultTimeout,
-	})
+// newProductContext creates a new context allowing media/images to load, along with the proxy it uses
+func newProductContext(bm *BrowserManager) (playwright.BrowserContext, *Proxy, error) {
+	contextOpts := *bm.opts
+	contextOpts.BlockImages = false
+	contextOpts.BlockMedia = false
+	contextOpts.BlockFonts = false
+	context, proxy, err := bm.newContext(&contextOpts)
 	if err != nil {
-		log.Fatalf("could not goto url: %v", err)
+		return nil, nil, fmt.Errorf("could not create context: %w", err)
 	}
+	return context, proxy, nil
+}
 
-	reviewsContainer := productPage.Locator(string(reviewsContainerSelector))
-	ratingCount := ""
-	ratingScore := ""
-	soldCount := uint32(0)
-
-	// Check if reviews container exists without waiting
-	reviewsContainerExists, err := reviewsContainer.Count()
-	if err != nil {
-		log.Printf("error checking reviews container count: %v", err)
-		reviewsContainerExists = 0
-	}
-	if reviewsContainerExists > 0 {
-		// Only wait for visibility if the container exists
-		err = reviewsContainer.WaitFor(playwright.LocatorWaitForOptions{
-			State:   playwright.WaitForSelectorStateVisible,
-			Timeout: playwright.Float(defaultTimeout),
-		})
-		if err != nil {
-			log.Printf("reviews container not visible after waiting: %v", err)
-			// Continue with empty values if reviews container is not visible
-		} else {
-			reviewRating := reviewsContainer.Locator(string(reviewsRatingSelector))
-			reviewCount := reviewsContainer.Locator(string(reviewsCountSelector))
-
-			ratingCount, err = reviewCount.First().TextContent()
-			if err != nil {
-				ratingCount = ""
-			}
-			// Clean the review count by removing parentheses, e.g., "(5)" -> "5"
-			ratingCount = cleanReviewCount(ratingCount)
-			ratingScore, err = reviewRating.First().TextContent()
-			if err != nil {
-				ratingScore = ""
-			}
-			soldCount = scrapeSoldCount(productPage)
-		}
-	} else {
-		log.Printf("reviews container not found on page, continuing with empty values")
-		// Set default values when no reviews container exists
-		ratingCount = ""
-		ratingScore = ""
-		soldCount = 0
+func scrapeProductPage(ctx context.Context, bm *BrowserManager, url string) (*MeliProduct, error) {
+	if err := bm.RateLimiter().Wait(ctx, url); err != nil {
+		return nil, err
 	}
-
-	productName := ""
-	nameCount, err := productPage.Locator(string(nameSelector)).Count()
-	if nameCount == 0 || err != nil {
-		log.Print("product name not found, product page not found")
-		return nil
-	} else {
-		productName, err = productPage.Locator(string(nameSelector)).First().TextContent()
-		if err != nil {
-			log.Print("productName text not founded")
-			return nil
-		}
+	var product *MeliProduct
+	err := bm.withSessionPage(ctx, func(page playwright.Page) error {
+		var err error
+		product, err = scrapeProductFromPage(page, url)
+		return err
+	})
+	if product != nil && !bm.opts.KeepDescriptionHTML {
+		product.DescriptionHTML = ""
 	}
-
-	pageUrl := productPage.URL()
-	curCode := utils.DomainToCurrencyCode(utils.Domain(pageUrl))
-
-	amount, err := productPage.Locator(string(priceAmountFractionSelector)).First().TextContent()
 	if err != nil {
-		log.Fatalf("amount whole not founded")
-		return nil
+		return nil, err
 	}
+	enumerateVariants(ctx, bm, product, bm.opts.MaxVariants)
+	return product, nil
+}
 
-	// amount cent is not always available to scrape
-	var amountCentsElem playwright.Locator = nil
-	centCount, err := productPage.Locator(string(priceAmountCentSelector)).Count()
+// scrapeProductFromPage navigates an open page to the product url and extracts the product from it
+func scrapeProductFromPage(productPage playwright.Page, url string) (*MeliProduct, error) {
+	doc, err := loadPageDocument(productPage, url)
+	paused := pausedDocument(doc, err)
+	if err != nil && !paused {
+		return nil, err
+	}
+	product, missing, err := productFromHTML(doc, productPage.URL(), url)
 	if err != nil {
-		log.Fatalf("failed to scrape cent count")
-		centCount = 0
+		return nil, err
 	}
-	if centCount > 0 {
-		amountCentsElem = productPage.Locator(string(priceAmountCentSelector)).First()
-	} else {
-		fmt.Printf("amount cent not found, continuing with 0\n")
+	if paused && product.Status == ListingActive {
+		product.Status = ListingPaused
 	}
-
-	var amountCentsInt = 0
-	if amountCentsElem != nil {
-		amountCentsInt = parseCents(amountCentsElem)
+	if len(missing) > 0 {
+		log.Printf("product page %s has no %s", url, strings.Join(missing, ", "))
 	}
+	fmt.Printf("total product images scraped: %d\n", len(product.ImageUrls))
+	return product, nil
+}
 
-	amountInt := utils.StandardizeAmountCents(amount, "")
-	fmt.Printf("amount cents parsed: %d, amount whole parsed: %d\n", amountCentsInt, amountInt)
-
-	storeInfo := scrapeStoreInfo(productPage)
-
-	images := ScrapeProductImages(productPage, url)
-	fmt.Printf("total product images scraped: %d - first image src: %s\n", len(images), images[0])
+// loadPageDocument opens url in productPage and parses the rendered html, once the product name,
+// its structured data or the product links of a search page are there. paused listings are
+// parsed too and returned along with their ErrListingPaused
+func loadPageDocument(productPage playwright.Page, url string) (*goquery.Document, error) {
+	// waits for elements, the goto itself is bounded by the navigation timeout of the browser options
+	defaultTimeout := float64(8000)
 
-	product := MeliProduct{
-		Title: productName,
-		Price: Price{
-			AmountCents:  amountInt + amountCentsInt,
-			CurrencyCode: curCode,
-		},
-		// to be filled in later
-		Url:                url,
-		ReviewCount:        convertStrUint32(ratingCount),
-		Rating:             convertStrToFloat32(ratingScore),
-		ImageUrls:          images,
-		SoldMoreThan:       &soldCount,
-		StoreInfo:          storeInfo,
-		DescriptionContent: "",
+	resp, err := productPage.Goto(url)
+	if err != nil {
+		return nil, wrapBrowserError(url, "goto", err)
 	}
-
-	return &product
-}
-
-func ScrapeProductImages(page playwright.Page, url string) []string {
-	var productPage playwright.Page
-	if page == nil {
-		// direct scrape from url
-		opts := DefaultBrowserOptions()
-		opts.BlockImages = false
-		opts.BlockMedia = false
-		opts.BlockFonts = false
-		bm, err := NewBrowserManager(opts)
-		if err != nil {
-			log.Fatalf("could not create browser manager: %v", err)
-		}
-		defer bm.Close()
-		productPage, err = bm.NewPage()
-		if err != nil {
-			log.Fatalf("could not create page: %v", err)
-		}
-		defer bm.ClosePage(productPage)
-		_, err = productPage.Goto(url, playwright.PageGotoOptions{
-			Timeout: playwright.Float(opts.Timeout),
-		})
-		if err != nil {
-			log.Fatalf("could not goto url: %v", err)
-		}
-		defer bm.ClosePage(productPage)
-	} else {
-		productPage = page
+	detected := detectPage(productPage, resp, url)
+	if detected != nil && !errors.Is(detected, ErrListingPaused) {
+		return nil, detected
 	}
 
-	images, err := productPage.Locator(string(productImagesSelector)).All()
+	selectors := selectorsFor(SiteMeli, url)
+	loaded := selectors.Any(SelectorTitle) + ", " + selectors.Any(SelectorProductLinks) + ", " + selectors.Any(SelectorReviewItems) +
+		`, script[type="application/ld+json"]`
+	err = productPage.Locator(loaded).First().WaitFor(playwright.LocatorWaitForOptions{
+		State:   playwright.WaitForSelectorStateAttached,
+		Timeout: playwright.Float(defaultTimeout),
+	})
 	if err != nil {
-		log.Fatalf("could not extract product images")
+		log.Printf("page content not found, reading what was rendered: %v", err)
 	}
-
-	imageUrls := []string{}
-	for _, im := range images {
-		imageSrc, err := im.GetAttribute("src")
-		if err != nil {
-			log.Fatalf("could not extract product image url")
-		}
-		imageUrls = append(imageUrls, imageSrc)
+	expandDescription(productPage, selectors)
+	content, err := productPage.Content()
+	if err != nil {
+		return nil, wrapBrowserError(url, "read page content", err)
 	}
-	return imageUrls
-}
-
-func scrapeSoldCount(page playwright.Page) uint32 {
-	texts, err := page.Locator("div.ui-pdp-header__subtitle > span.ui-pdp-subtitle").AllInnerTexts()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
 	if err != nil {
-		log.Fatalf("failed to scrape sold count")
+		return nil, newScrapeError(ErrLayoutChanged, url, "parse page content", err)
 	}
-	// fmt.Printf("scrapeSoldCount / sold text elem: %v", texts)
-	soldText := texts[0]
-	soldCount := parseSoldCount(soldText)
-	return soldCount
+	return doc, detected
 }
 
-func scrapeStoreInfo(page playwright.Page) MeliStoreInfo {
-	storeNameElem := page.Locator(string(storeNameSelector)).First()
-	storeName, err := storeNameElem.TextContent()
-	if err != nil {
-		fmt.Printf("failed to scrape store name: %v", err)
+// expandDescription clicks "ver descripción completa" so the whole description is rendered, pages
+// without the button are left as they are
+func expandDescription(page playwright.Page, selectors Selectors) {
+	expand := page.Locator(selectors.Any(SelectorDescriptionExpand)).First()
+	if count, err := expand.Count(); err != nil || count == 0 {
+		return
 	}
-	storeUrlElem := page.Locator(string(storeUrlSelector)).First()
-	storeUrl, err := storeUrlElem.GetAttribute("href")
-	if err != nil {
-		fmt.Printf("failed to scrape store url: %v", err)
-		storeUrl = ""
+	if err := expand.Click(playwright.LocatorClickOptions{Timeout: playwright.Float(2000)}); err != nil {
+		log.Printf("could not expand the description, reading the collapsed one: %v", err)
 	}
-	storeUrl = parseUrlBase(storeUrl)
-	storeLogoImageCount, err := page.Locator(string(storeLogoImageSelector)).Count()
-	if err != nil {
-		fmt.Printf("failed to scrape store logo image does not exist: %v", err)
-		storeLogoImageCount = 0
+}
+
+// Document loads the page at url in a session and returns its rendered html and final url
+func (bm *BrowserManager) Document(ctx context.Context, url string) (*goquery.Document, string, error) {
+	if err := bm.RateLimiter().Wait(ctx, url); err != nil {
+		return nil, "", err
+	}
+	var doc *goquery.Document
+	var finalUrl string
+	err := bm.withSessionPage(ctx, func(page playwright.Page) error {
+		var err error
+		doc, err = loadPageDocument(page, url)
+		finalUrl = page.URL()
+		return err
+	})
+	return doc, finalUrl, err
+}
+
+// ScrapeProductImages scrapes only the image urls of a product page
+func ScrapeProductImages(ctx context.Context, bm *BrowserManager, url string) ([]string, error) {
+	if err := bm.RateLimiter().Wait(ctx, url); err != nil {
+		return nil, err
 	}
-	imageSrc := ""
-	if storeLogoImageCount > 0 {
-		imageSrc, err = page.Locator(string(storeLogoImageSelector)).First().GetAttribute("data-src")
+	var images []string
+	err := bm.withSessionPage(ctx, func(page playwright.Page) error {
+		doc, err := loadPageDocument(page, url)
 		if err != nil {
-			fmt.Printf("failed to scrape store logo image src: %v", err)
-			imageSrc = ""
+			return err
 		}
-	}
-	return MeliStoreInfo{
-		Name:                 storeName,
-		Url:                  storeUrl,
-		LogoImageSrcOriginal: imageSrc,
-	}
+		images = productImagesFromHTML(doc, selectorsFor(SiteMeli, page.URL()))
+		return nil
+	})
+	return images, err
 }
 
 func parseUrlBase(s string) string {
@@ -600,25 +881,24 @@ func parseUrlBase(s string) string {
 	return simpleUrl
 }
 
-func parseCents(amountCentsElem playwright.Locator) int {
-	amountCentsInt := 0
+// textContenter is the part of playwright.Locator parseCents needs
+type textContenter interface {
+	TextContent(options ...playwright.LocatorTextContentOptions) (string, error)
+}
+
+func parseCents(amountCentsElem textContenter) (int, error) {
 	if amountCentsElem == nil {
-		fmt.Println("amount cents element not found")
-		log.Fatalf("amount cents not founded")
-		amountCentsInt = 0
-	} else {
-		amountCentsText, err := amountCentsElem.TextContent()
-		if err != nil {
-			amountCentsInt = 0
-		} else {
-			amountCentsInt, err = strconv.Atoi(amountCentsText)
-			if err != nil {
-				log.Fatalf("failed to parse amountCents to int: %v", err)
-				amountCentsInt = 0
-			}
-		}
+		return 0, errors.New("amount cents element not found")
+	}
+	amountCentsText, err := amountCentsElem.TextContent()
+	if err != nil {
+		return 0, fmt.Errorf("could not read amount cents: %w", err)
+	}
+	amountCentsInt, err := strconv.Atoi(strings.TrimSpace(amountCentsText))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse amountCents to int: %w", err)
 	}
-	return amountCentsInt
+	return amountCentsInt, nil
 }
 
 func convertStrToFloat32(s string) *float32 {
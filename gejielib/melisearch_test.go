@@ -2,8 +2,9 @@ package gejie
 
 import (
 	"fmt"
-	"strconv"
 	"testing"
+
+	"github.com/playwright-community/playwright-go"
 )
 
 func TestConvertStrToFloat32(t *testing.T) {
@@ -224,15 +225,16 @@ type mockLocator struct {
 	err  error
 }
 
-func (m *mockLocator) TextContent() (string, error) {
+func (m *mockLocator) TextContent(options ...playwright.LocatorTextContentOptions) (string, error) {
 	return m.text, m.err
 }
 
 func TestParseCents(t *testing.T) {
 	tests := []struct {
-		name     string
-		locator  *mockLocator
-		expected int
+		name      string
+		locator   *mockLocator
+		expected  int
+		expectErr bool
 	}{
 		{
 			name:     "Valid cents text",
@@ -250,42 +252,33 @@ func TestParseCents(t *testing.T) {
 			expected: 999,
 		},
 		{
-			name:     "TextContent error returns 0",
-			locator:  &mockLocator{text: "", err: fmt.Errorf("mock error")},
-			expected: 0,
+			name:      "TextContent error returns 0",
+			locator:   &mockLocator{text: "", err: fmt.Errorf("mock error")},
+			expected:  0,
+			expectErr: true,
 		},
 		{
-			name:     "Invalid number text returns 0",
-			locator:  &mockLocator{text: "not a number", err: nil},
-			expected: 0,
+			name:      "Invalid number text returns 0",
+			locator:   &mockLocator{text: "not a number", err: nil},
+			expected:  0,
+			expectErr: true,
 		},
 		{
-			name:     "Empty text returns 0",
-			locator:  &mockLocator{text: "", err: nil},
-			expected: 0,
+			name:      "Empty text returns 0",
+			locator:   &mockLocator{text: "", err: nil},
+			expected:  0,
+			expectErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// We need to mock the playwright.Locator interface
-			// For now, we'll test the logic by creating a simple test
-			// that simulates the behavior
-			if tt.locator.err != nil {
-				// Simulate error case
-				result := 0
-				if result != tt.expected {
-					t.Errorf("parseCents with error = %d, expected %d", result, tt.expected)
-				}
-			} else {
-				// Simulate success case
-				if num, err := strconv.Atoi(tt.locator.text); err == nil {
-					if num != tt.expected {
-						t.Errorf("parseCents(%q) = %d, expected %d", tt.locator.text, num, tt.expected)
-					}
-				} else if tt.expected != 0 {
-					t.Errorf("parseCents(%q) failed to parse, expected %d", tt.locator.text, tt.expected)
-				}
+			result, err := parseCents(tt.locator)
+			if (err != nil) != tt.expectErr {
+				t.Errorf("parseCents(%q) error = %v, expectErr %t", tt.locator.text, err, tt.expectErr)
+			}
+			if result != tt.expected {
+				t.Errorf("parseCents(%q) = %d, expected %d", tt.locator.text, result, tt.expected)
 			}
 		})
 	}
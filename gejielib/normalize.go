@@ -0,0 +1,236 @@
+package gejie
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// URLNormalizer canonicalises urls of a site so the frontier can tell duplicates apart
+type URLNormalizer interface {
+	// Normalize returns the url to visit and the key shared by every url of the same page
+	Normalize(rawUrl string) (canonical string, key string, err error)
+}
+
+// SiteNormalizers picks a normalizer by site name, the host label before the public suffix,
+// e.g. "mercadolibre" for articulo.mercadolibre.com.pe. other sites use GenericNormalizer
+type SiteNormalizers map[string]URLNormalizer
+
+func DefaultURLNormalizer() URLNormalizer {
+	return SiteNormalizers{
+		"mercadolibre": MeliNormalizer{},
+		"mercadolivre": MeliNormalizer{},
+		"zhipin":       ZhipinNormalizer{},
+	}
+}
+
+func (s SiteNormalizers) Normalize(rawUrl string) (string, string, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", "", err
+	}
+	host := "." + strings.ToLower(parsed.Hostname()) + "."
+	for _, site := range s.sortedSites() {
+		if strings.Contains(host, "."+site+".") {
+			return s[site].Normalize(rawUrl)
+		}
+	}
+	return GenericNormalizer{}.Normalize(rawUrl)
+}
+
+// trackingParams are query params that only identify where a click came from
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"_ga":     true,
+	"ref_src": true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"spm":     true,
+	"_gl":     true,
+	"dclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"srsltid": true,
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return trackingParams[name] || strings.HasPrefix(name, "utm_")
+}
+
+// GenericNormalizer lowercases the scheme and host, drops default ports, fragments and
+// tracking params and sorts the remaining query, the canonical url is also the key
+type GenericNormalizer struct{}
+
+func (GenericNormalizer) Normalize(rawUrl string) (string, string, error) {
+	parsed, err := canonicalURL(rawUrl, isTrackingParam)
+	if err != nil {
+		return "", "", err
+	}
+	canonical := parsed.String()
+	return canonical, canonical, nil
+}
+
+// canonicalURL applies the generic normalisation, dropping the query params drop matches
+func canonicalURL(rawUrl string, drop func(name string) bool) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil {
+		return nil, err
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		parsed.Host = parsed.Hostname()
+	}
+	if parsed.Host != "" && parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	query := parsed.Query()
+	for name := range query {
+		if drop(name) {
+			query.Del(name)
+		}
+	}
+	// Encode sorts by key so the same params in another order give the same url
+	parsed.RawQuery = query.Encode()
+	return parsed, nil
+}
+
+var (
+	// meliItemRegex matches item ids such as MPE-600000001 in articulo urls or MLM123456789
+	meliItemRegex = regexp.MustCompile(`(?i)(?:^|/)(M[A-Z]{2})-?(\d{6,})`)
+	// meliCatalogRegex matches catalog product pages such as /teclado-redragon/p/MPE19045732
+	meliCatalogRegex = regexp.MustCompile(`(?i)/p/(M[A-Z]{2})(\d+)`)
+	// meliItemFilterRegex matches the item a catalog page is filtered to, e.g. pdp_filters=item_id:MPE600000001
+	meliItemFilterRegex = regexp.MustCompile(`(?i)item_id[:=](M[A-Z]{2})-?(\d+)`)
+)
+
+// meliTrackingParams are added by meli search results and recommendations
+var meliTrackingParams = map[string]bool{
+	"tracking_id":     true,
+	"position":        true,
+	"search_layout":   true,
+	"type":            true,
+	"sid":             true,
+	"polycard_client": true,
+	"wid":             true,
+	"source":          true,
+}
+
+func isMeliTrackingParam(name string) bool {
+	lower := strings.ToLower(name)
+	return isTrackingParam(name) || meliTrackingParams[lower] ||
+		strings.HasPrefix(lower, "reco_") || strings.HasPrefix(lower, "c_")
+}
+
+// MeliNormalizer keys item pages by their MLx item id, so articulo. and www. urls, tracking
+// params, fragments and the title slug all collapse to the same item. item pages drop the whole
+// query since it only carries search tracking, other pages keep non tracking params
+type MeliNormalizer struct{}
+
+func (MeliNormalizer) Normalize(rawUrl string) (string, string, error) {
+	parsed, err := canonicalURL(rawUrl, isMeliTrackingParam)
+	if err != nil {
+		return "", "", err
+	}
+	parsed.Scheme = "https"
+
+	if id := meliItemId(parsed); id != "" {
+		if !meliCatalogRegex.MatchString(parsed.Path) {
+			parsed.RawQuery = ""
+		}
+		return parsed.String(), "meli:item:" + id, nil
+	}
+	if match := meliCatalogRegex.FindStringSubmatch(parsed.Path); match != nil {
+		return parsed.String(), "meli:product:" + strings.ToUpper(match[1]) + match[2], nil
+	}
+	canonical := parsed.String()
+	return canonical, canonical, nil
+}
+
+// meliItemId returns the item id of u like "MPE600000001", or "" when u is not an item page
+func meliItemId(u *url.URL) string {
+	if meliCatalogRegex.MatchString(u.Path) {
+		// a catalog page only points at a single item when filtered to it
+		query, _ := url.QueryUnescape(u.RawQuery)
+		if match := meliItemFilterRegex.FindStringSubmatch(query); match != nil {
+			return strings.ToUpper(match[1]) + match[2]
+		}
+		return ""
+	}
+	if match := meliItemRegex.FindStringSubmatch(u.Path); match != nil {
+		return strings.ToUpper(match[1]) + match[2]
+	}
+	return ""
+}
+
+var zhipinJobRegex = regexp.MustCompile(`^/job_detail/([^/]+)\.html$`)
+
+// ZhipinNormalizer keys job postings by their id and drops the session and tracking params
+// (lid, securityId, sessionId, ka) zhipin appends to every recommended posting link
+type ZhipinNormalizer struct{}
+
+func (ZhipinNormalizer) Normalize(rawUrl string) (string, string, error) {
+	parsed, err := canonicalURL(rawUrl, func(name string) bool {
+		return isTrackingParam(name) || name == "ka"
+	})
+	if err != nil {
+		return "", "", err
+	}
+	if match := zhipinJobRegex.FindStringSubmatch(parsed.Path); match != nil {
+		parsed.RawQuery = ""
+		if parsed.Host != "" {
+			parsed.Scheme = "https"
+		}
+		return parsed.String(), "zhipin:job:" + match[1], nil
+	}
+	canonical := parsed.String()
+	return canonical, canonical, nil
+}
+
+// ResolveURL resolves ref, e.g. a relative link, against the url of the page it was found on
+func ResolveURL(base string, ref string) (string, error) {
+	baseUrl, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	refUrl, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return "", err
+	}
+	if !baseUrl.IsAbs() {
+		return refUrl.String(), nil
+	}
+	return baseUrl.ResolveReference(refUrl).String(), nil
+}
+
+// normalizeURL returns the canonical url and key of rawUrl, falling back to rawUrl itself
+// when there is no normalizer or rawUrl does not parse
+func normalizeURL(normalizer URLNormalizer, rawUrl string) (string, string) {
+	if normalizer == nil {
+		return rawUrl, rawUrl
+	}
+	canonical, key, err := normalizer.Normalize(rawUrl)
+	if err != nil {
+		return rawUrl, rawUrl
+	}
+	return canonical, key
+}
+
+// sortedSites lists the sites of s, used to match hosts in a stable order
+func (s SiteNormalizers) sortedSites() []string {
+	sites := make([]string, 0, len(s))
+	for site := range s {
+		sites = append(sites, site)
+	}
+	sort.Strings(sites)
+	return sites
+}
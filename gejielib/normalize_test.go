@@ -0,0 +1,88 @@
+package gejie
+
+import "testing"
+
+func TestDefaultURLNormalizer(t *testing.T) {
+	tests := []struct {
+		input     string
+		canonical string
+		key       string
+	}{
+		{
+			input:     "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM#position=3&search_layout=stack&type=item",
+			canonical: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM",
+			key:       "meli:item:MPE600000001",
+		},
+		{
+			input:     "http://ARTICULO.MercadoLibre.com.pe/MPE-600000001-otro-slug-_JM?tracking_id=1f2e&polycard_client=search",
+			canonical: "https://articulo.mercadolibre.com.pe/MPE-600000001-otro-slug-_JM",
+			key:       "meli:item:MPE600000001",
+		},
+		{
+			input:     "https://www.mercadolibre.com.pe/teclado-redragon/p/MPE19045732?pdp_filters=item_id%3AMPE600000001#reco_item_pos=0",
+			canonical: "https://www.mercadolibre.com.pe/teclado-redragon/p/MPE19045732?pdp_filters=item_id%3AMPE600000001",
+			key:       "meli:item:MPE600000001",
+		},
+		{
+			input:     "https://www.mercadolibre.com.pe/teclado-redragon/p/MPE19045732?reco_id=9",
+			canonical: "https://www.mercadolibre.com.pe/teclado-redragon/p/MPE19045732",
+			key:       "meli:product:MPE19045732",
+		},
+		{
+			input:     "https://articulo.mercadolibre.com.mx/MLM-1234567890-audifonos-_JM",
+			canonical: "https://articulo.mercadolibre.com.mx/MLM-1234567890-audifonos-_JM",
+			key:       "meli:item:MLM1234567890",
+		},
+		{
+			input:     "https://listado.mercadolibre.com.pe/teclado-mecanico_Desde_51_NoIndex_True?sid=search#D[A:teclado]",
+			canonical: "https://listado.mercadolibre.com.pe/teclado-mecanico_Desde_51_NoIndex_True",
+			key:       "https://listado.mercadolibre.com.pe/teclado-mecanico_Desde_51_NoIndex_True",
+		},
+		{
+			input:     "https://www.zhipin.com/job_detail/b6840d4438ff55c41n1609S-FFVT.html?lid=abc&securityId=xyz&sessionId=&ka=job_detail_recommend",
+			canonical: "https://www.zhipin.com/job_detail/b6840d4438ff55c41n1609S-FFVT.html",
+			key:       "zhipin:job:b6840d4438ff55c41n1609S-FFVT",
+		},
+		{
+			input:     "https://www.zhipin.com/web/geek/jobs?query=agents&city=101010100&ka=header-jobs",
+			canonical: "https://www.zhipin.com/web/geek/jobs?city=101010100&query=agents",
+			key:       "https://www.zhipin.com/web/geek/jobs?city=101010100&query=agents",
+		},
+		{
+			input:     "HTTP://Example.com:80?b=2&a=1&utm_source=news&fbclid=x#top",
+			canonical: "http://example.com/?a=1&b=2",
+			key:       "http://example.com/?a=1&b=2",
+		},
+	}
+
+	normalizer := DefaultURLNormalizer()
+	for _, test := range tests {
+		canonical, key, err := normalizer.Normalize(test.input)
+		if err != nil {
+			t.Errorf("Normalize(%q) returned error %v", test.input, err)
+			continue
+		}
+		if canonical != test.canonical || key != test.key {
+			t.Errorf("Normalize(%q) = (%q, %q), expected (%q, %q)", test.input, canonical, key, test.canonical, test.key)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	tests := []struct {
+		base     string
+		ref      string
+		expected string
+	}{
+		{"https://www.zhipin.com/job_detail/a.html", "/job_detail/b.html", "https://www.zhipin.com/job_detail/b.html"},
+		{"https://www.zhipin.com/job_detail/a.html", "c.html", "https://www.zhipin.com/job_detail/c.html"},
+		{"https://listado.mercadolibre.com.pe/teclado", "https://articulo.mercadolibre.com.pe/MPE-1", "https://articulo.mercadolibre.com.pe/MPE-1"},
+		{"/job_detail/a.html", "/job_detail/b.html", "/job_detail/b.html"},
+	}
+	for _, test := range tests {
+		resolved, err := ResolveURL(test.base, test.ref)
+		if err != nil || resolved != test.expected {
+			t.Errorf("ResolveURL(%q, %q) = (%q, %v), expected %q", test.base, test.ref, resolved, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package gejie
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// productScraper scrapes product urls one after another, each worker of the pool owns one
+type productScraper interface {
+	scrape(url string) (*MeliProduct, error)
+	close()
+}
+
+// fetcherProductScraper scrapes products with a fetcher, which aborts the scrape in flight itself
+// once ctx is done and reuses its browser sessions across scrapes
+type fetcherProductScraper struct {
+	ctx     context.Context
+	fetcher Fetcher
+	// cards are the search cards of the urls, set on their products
+	cards map[string]*ListingCard
+}
+
+func (s *fetcherProductScraper) scrape(url string) (*MeliProduct, error) {
+	product, err := s.fetcher.Product(s.ctx, url)
+	if product != nil {
+		product.Card = s.cards[url]
+	}
+	return product, err
+}
+
+func (s *fetcherProductScraper) close() {}
+
+// scrapeProductPages scrapes urls on up to concurrency workers, products and errors are
+// returned at the same index as their url so the search rank is kept. urls failing with a retriable
+// error are queued again after the retry backoff, so workers move on to other urls meanwhile.
+// when ctx is done the open pages are closed to abort navigations in flight and every unfinished
+// url gets the context error. onResult, when not nil, is called from the workers as soon as each
+// url is done, after its last attempt
+func scrapeProductPages(ctx context.Context, urls []string, concurrency int, limiter *RateLimiter, retry *RetryPolicy, newScraper func() (productScraper, error), onResult func(url string, product *MeliProduct, err error)) ([]*MeliProduct, []error) {
+	products := make([]*MeliProduct, len(urls))
+	errs := make([]error, len(urls))
+	if len(urls) == 0 {
+		return products, errs
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(urls) {
+		concurrency = len(urls)
+	}
+
+	// workers are set up front so a context that fails to open only shrinks the pool
+	scrapers := []productScraper{}
+	var setupErr error
+	for w := 0; w < concurrency; w++ {
+		scraper, err := newScraper()
+		if err != nil {
+			setupErr = err
+			continue
+		}
+		scrapers = append(scrapers, scraper)
+	}
+	if len(scrapers) == 0 {
+		for i := range urls {
+			errs[i] = setupErr
+		}
+		return products, errs
+	}
+
+	var closeOnce sync.Once
+	closeScrapers := func() {
+		closeOnce.Do(func() {
+			for _, scraper := range scrapers {
+				scraper.close()
+			}
+		})
+	}
+	stopClosing := context.AfterFunc(ctx, closeScrapers)
+	defer stopClosing()
+	defer closeScrapers()
+
+	// every url is either queued, being scraped or waiting out its backoff, so the buffer never fills.
+	// attempts[i] is only touched by whoever holds url i at the time
+	jobs := make(chan int, len(urls))
+	attempts := make([]int, len(urls))
+	var remaining sync.WaitGroup
+	remaining.Add(len(urls))
+	for i := range urls {
+		jobs <- i
+	}
+	go func() {
+		remaining.Wait()
+		close(jobs)
+	}()
+
+	var wg sync.WaitGroup
+	for _, scraper := range scrapers {
+		wg.Add(1)
+		go func(scraper productScraper) {
+			defer wg.Done()
+			for i := range jobs {
+				if err := limiter.Wait(ctx, urls[i]); err != nil {
+					errs[i] = err
+					remaining.Done()
+					continue
+				}
+				attempts[i]++
+				products[i], errs[i] = scraper.scrape(urls[i])
+				if errs[i] != nil && ctx.Err() != nil {
+					// the page was closed under the scrape, report the cancellation instead
+					products[i], errs[i] = nil, ctx.Err()
+				}
+				if errs[i] != nil && retry.ShouldRetry(errs[i], attempts[i]) {
+					delay := retry.Backoff(attempts[i])
+					log.Printf("retrying %s in %v (attempt %d failed: %s)", urls[i], delay.Round(time.Millisecond), attempts[i], FailureKind(errs[i]))
+					go func(i int) {
+						if err := sleepContext(ctx, delay); err != nil {
+							errs[i] = err
+							remaining.Done()
+							return
+						}
+						jobs <- i
+					}(i)
+					continue
+				}
+				if errs[i] != nil {
+					errs[i] = gaveUp(errs[i], attempts[i])
+				}
+				if onResult != nil {
+					onResult(urls[i], products[i], errs[i])
+				}
+				remaining.Done()
+			}
+		}(scraper)
+	}
+	wg.Wait()
+
+	return products, errs
+}
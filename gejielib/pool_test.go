@@ -0,0 +1,195 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeProductScraper struct {
+	running    *int32
+	maxRunning *int32
+	closed     *int32
+}
+
+func (s *fakeProductScraper) scrape(url string) (*MeliProduct, error) {
+	running := atomic.AddInt32(s.running, 1)
+	for {
+		max := atomic.LoadInt32(s.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(s.maxRunning, max, running) {
+			break
+		}
+	}
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+	atomic.AddInt32(s.running, -1)
+
+	if url == "https://articulo.mercadolibre.com.pe/broken" {
+		return nil, newScrapeError(ErrLayoutChanged, url, "find product name", nil)
+	}
+	return &MeliProduct{Title: url, Url: url}, nil
+}
+
+func (s *fakeProductScraper) close() {
+	atomic.AddInt32(s.closed, 1)
+}
+
+func TestScrapeProductPagesKeepsOrder(t *testing.T) {
+	urls := []string{}
+	for i := 0; i < 20; i++ {
+		urls = append(urls, fmt.Sprintf("https://articulo.mercadolibre.com.pe/MPE-%d", i))
+	}
+	urls[7] = "https://articulo.mercadolibre.com.pe/broken"
+
+	var running, maxRunning, created, closed int32
+	products, errs := scrapeProductPages(context.Background(), urls, 4, nil, nil, func() (productScraper, error) {
+		atomic.AddInt32(&created, 1)
+		return &fakeProductScraper{running: &running, maxRunning: &maxRunning, closed: &closed}, nil
+	}, nil)
+
+	for i, url := range urls {
+		if i == 7 {
+			if !errors.Is(errs[i], ErrLayoutChanged) || products[i] != nil {
+				t.Errorf("result %d = (%v, %v), expected a layout changed error", i, products[i], errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("result %d returned error %v", i, errs[i])
+		}
+		if products[i].Url != url {
+			t.Errorf("products[%d].Url = %q, expected %q", i, products[i].Url, url)
+		}
+	}
+	if maxRunning > 4 {
+		t.Errorf("%d scrapes ran at once, expected at most 4", maxRunning)
+	}
+	if created != 4 || closed != 4 {
+		t.Errorf("created %d and closed %d scrapers, expected 4 of each", created, closed)
+	}
+}
+
+func TestScrapeProductPagesSetupFailure(t *testing.T) {
+	urls := []string{"https://articulo.mercadolibre.com.pe/MPE-1", "https://articulo.mercadolibre.com.pe/MPE-2"}
+	setupErr := errors.New("could not create context")
+
+	products, errs := scrapeProductPages(context.Background(), urls, 2, nil, nil, func() (productScraper, error) {
+		return nil, setupErr
+	}, nil)
+	for i := range urls {
+		if products[i] != nil || !errors.Is(errs[i], setupErr) {
+			t.Errorf("result %d = (%v, %v), expected the setup error", i, products[i], errs[i])
+		}
+	}
+}
+
+func TestScrapeProductPagesCancelled(t *testing.T) {
+	urls := []string{}
+	for i := 0; i < 10; i++ {
+		urls = append(urls, fmt.Sprintf("https://articulo.mercadolibre.com.pe/MPE-%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var running, maxRunning, closed int32
+	limiter := NewRateLimiter(HostLimit{RequestsPerMinute: 3000, Burst: 1})
+	time.AfterFunc(30*time.Millisecond, cancel)
+
+	products, errs := scrapeProductPages(ctx, urls, 1, limiter, nil, func() (productScraper, error) {
+		return &fakeProductScraper{running: &running, maxRunning: &maxRunning, closed: &closed}, nil
+	}, nil)
+
+	if products[0] == nil {
+		t.Errorf("first product was not scraped before cancelling: %v", errs[0])
+	}
+	last := len(urls) - 1
+	if products[last] != nil || !errors.Is(errs[last], context.Canceled) {
+		t.Errorf("last result = (%v, %v), expected context.Canceled", products[last], errs[last])
+	}
+	if closed == 0 {
+		t.Errorf("scraper was not closed after cancelling")
+	}
+}
+
+// flakyProductScraper times out on the first failures attempts of every url, a 404 url never succeeds
+type flakyProductScraper struct {
+	mu       *sync.Mutex
+	attempts map[string]int
+	failures int
+}
+
+func (s *flakyProductScraper) scrape(url string) (*MeliProduct, error) {
+	s.mu.Lock()
+	s.attempts[url]++
+	attempt := s.attempts[url]
+	s.mu.Unlock()
+
+	if url == "https://articulo.mercadolibre.com.pe/removed" {
+		return nil, statusError(url, 404)
+	}
+	if attempt <= s.failures {
+		return nil, newScrapeError(ErrTimeout, url, "goto", nil)
+	}
+	return &MeliProduct{Title: url, Url: url}, nil
+}
+
+func (s *flakyProductScraper) close() {}
+
+func TestScrapeProductPagesRetries(t *testing.T) {
+	urls := []string{
+		"https://articulo.mercadolibre.com.pe/MPE-1",
+		"https://articulo.mercadolibre.com.pe/removed",
+		"https://articulo.mercadolibre.com.pe/MPE-2",
+	}
+	tests := []struct {
+		name       string
+		failures   int
+		expectOk   bool
+		expectRuns int
+	}{
+		{name: "succeeds on the last attempt", failures: 2, expectOk: true, expectRuns: 3},
+		{name: "gives up after max attempts", failures: 5, expectOk: false, expectRuns: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &flakyProductScraper{mu: &sync.Mutex{}, attempts: map[string]int{}, failures: tt.failures}
+			retry := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+			results := map[string]int{}
+			var mu sync.Mutex
+
+			products, errs := scrapeProductPages(context.Background(), urls, 2, nil, retry, func() (productScraper, error) {
+				return scraper, nil
+			}, func(url string, product *MeliProduct, err error) {
+				mu.Lock()
+				results[url]++
+				mu.Unlock()
+			})
+
+			for i, url := range urls {
+				if results[url] != 1 {
+					t.Errorf("onResult called %d times for %s, expected once", results[url], url)
+				}
+				if i == 1 {
+					if !errors.Is(errs[i], ErrNotFound) || scraper.attempts[url] != 1 {
+						t.Errorf("removed url = %v after %d attempts, expected one not found attempt", errs[i], scraper.attempts[url])
+					}
+					continue
+				}
+				if scraper.attempts[url] != tt.expectRuns {
+					t.Errorf("%s scraped %d times, expected %d", url, scraper.attempts[url], tt.expectRuns)
+				}
+				if tt.expectOk && (errs[i] != nil || products[i] == nil) {
+					t.Errorf("%s = (%v, %v), expected a product", url, products[i], errs[i])
+				}
+				if !tt.expectOk && !errors.Is(errs[i], ErrTimeout) {
+					t.Errorf("%s returned %v, expected the last timeout", url, errs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package gejie
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+// Price is what a product sells for along with the promotion terms shown next to it
+type Price struct {
+	// AmountCents is the price paid, the sale price when the product is discounted
+	AmountCents  int
+	CurrencyCode utils.CurrencyCode
+	// ListAmountCents is the struck through price before the discount, 0 when there is none
+	ListAmountCents int
+	// DiscountPercent is the "% OFF" of the sale price
+	DiscountPercent int
+	// Installments are the cuotas offered under the price, nil when none are shown
+	Installments *Installments
+	// Promotions are the Mercado Pago offers shown with the price, e.g. "10% OFF con Mercado Pago"
+	Promotions []string
+}
+
+// Installments are terms like "en 12 cuotas de S/ 13.33 sin interés"
+type Installments struct {
+	Count       int
+	AmountCents int
+	// InterestFree tells the cuotas add up to the price, "sin interés" or "mismo precio"
+	InterestFree bool
+}
+
+// ListPriceCents is the price before any discount
+func (p Price) ListPriceCents() int {
+	if p.ListAmountCents > 0 {
+		return p.ListAmountCents
+	}
+	return p.AmountCents
+}
+
+// priceFields names the selector fields of the price terms of a product page or a listing card
+type priceFields struct {
+	original, discount, installments, promotions string
+}
+
+var (
+	productPriceFields = priceFields{SelectorPriceOriginal, SelectorPriceDiscount, SelectorPriceInstallments, SelectorPricePromotions}
+	cardPriceFields    = priceFields{SelectorCardPriceOriginal, SelectorCardPriceDiscount, SelectorCardInstallments, SelectorCardPromotions}
+)
+
+// fillPriceTerms reads the list price, discount, installments and promotions under root into price,
+// a discount without a label of its own is worked out from the list price
+func fillPriceTerms(price *Price, root finder, selectors Selectors, fields priceFields) {
+	if price.ListAmountCents == 0 {
+		if original := selectors.Find(root, fields.original).First(); original.Length() > 0 {
+			price.ListAmountCents, _ = moneyCents(original, selectors)
+		}
+	}
+	if price.ListAmountCents <= price.AmountCents {
+		price.ListAmountCents = 0
+	}
+	if price.DiscountPercent == 0 {
+		price.DiscountPercent, _ = parseDiscountPercent(selectors.Find(root, fields.discount).First().Text())
+	}
+	if price.DiscountPercent == 0 && price.ListAmountCents > 0 {
+		price.DiscountPercent = int(math.Round(float64(price.ListAmountCents-price.AmountCents) * 100 / float64(price.ListAmountCents)))
+	}
+	if price.Installments == nil {
+		if installments := selectors.Find(root, fields.installments).First(); installments.Length() > 0 {
+			price.Installments = parseInstallments(strings.Join(strings.Fields(installments.Text()), " "))
+			// the cuota amount is rendered like the price when the page splits its cents off
+			if amount, ok := moneyCents(selectors.Find(installments, SelectorMoneyFraction).First().Parent(), selectors); ok && price.Installments != nil {
+				price.Installments.AmountCents = amount
+			}
+		}
+	}
+	if len(price.Promotions) == 0 {
+		selectors.Find(root, fields.promotions).Each(func(_ int, s *goquery.Selection) {
+			if promotion := strings.Join(strings.Fields(s.Text()), " "); promotion != "" {
+				price.Promotions = append(price.Promotions, promotion)
+			}
+		})
+	}
+}
+
+// moneyCents reads an andes money amount, its fraction and cents elements or else its text
+func moneyCents(s *goquery.Selection, selectors Selectors) (int, bool) {
+	if s.Length() == 0 {
+		return 0, false
+	}
+	fraction := selectors.Find(s, SelectorMoneyFraction).First()
+	if fraction.Length() == 0 {
+		return parseAmountText(s.Text())
+	}
+	cents, err := utils.ParseAmountCents(fraction.Text())
+	if err != nil {
+		return 0, false
+	}
+	if centsText := strings.TrimSpace(selectors.Find(s, SelectorMoneyCents).First().Text()); centsText != "" {
+		if value, err := strconv.Atoi(centsText); err == nil {
+			cents += value
+		}
+	}
+	return cents, true
+}
+
+var (
+	amountRegex       = regexp.MustCompile(`\d[\d.,]*`)
+	discountRegex     = regexp.MustCompile(`(\d{1,2})\s*%`)
+	installmentsRegex = regexp.MustCompile(`(?i)(\d+)\s*(?:x\b|cuotas|meses|pagos)`)
+	// the cuota amount follows "de" or "x", e.g. "12 cuotas de S/ 13.33" or "12x $ 83,25"
+	installmentAmountRegex = regexp.MustCompile(`(?i)(?:\bde|\dx)\s*(?:[a-z]{0,3}\$|s/)?\s*(\d[\d.,]*)`)
+)
+
+// parseAmountText reads an amount like "S/ 1,234.56" or "$ 1.234,56" in cents, a separator followed
+// by exactly two digits at the end is the decimal one and every other is a thousands separator
+func parseAmountText(s string) (int, bool) {
+	number := strings.TrimRight(amountRegex.FindString(s), ".,")
+	if number == "" {
+		return 0, false
+	}
+	cents := 0
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 && len(number)-i == 3 {
+		cents, _ = strconv.Atoi(number[i+1:])
+		number = number[:i]
+	}
+	whole, err := utils.ParseAmountCents(number)
+	if err != nil {
+		return 0, false
+	}
+	return whole + cents, true
+}
+
+// parseDiscountPercent reads labels like "20% OFF"
+func parseDiscountPercent(s string) (int, bool) {
+	match := discountRegex.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+	percent, err := strconv.Atoi(match[1])
+	return percent, err == nil
+}
+
+// parseInstallments reads terms like "Mismo precio en 12 cuotas de S/ 13.33" or "12 meses sin
+// intereses de $ 83.25", nil when s has no cuota count
+func parseInstallments(s string) *Installments {
+	match := installmentsRegex.FindStringSubmatch(s)
+	if match == nil {
+		return nil
+	}
+	count, err := strconv.Atoi(match[1])
+	if err != nil || count < 2 {
+		return nil
+	}
+	installments := &Installments{Count: count}
+	if amount := installmentAmountRegex.FindStringSubmatch(s); amount != nil {
+		installments.AmountCents, _ = parseAmountText(amount[1])
+	}
+	lower := strings.ToLower(s)
+	installments.InterestFree = strings.Contains(lower, "sin inter") || strings.Contains(lower, "mismo precio")
+	return installments
+}
@@ -0,0 +1,180 @@
+package gejie
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseInstallments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *Installments
+	}{
+		{
+			name:     "Same price installments",
+			input:    "Mismo precio en 12 cuotas de S/ 13.33",
+			expected: &Installments{Count: 12, AmountCents: 1333, InterestFree: true},
+		},
+		{
+			name:     "Interest free card installments",
+			input:    "12 cuotas de S/ 13.33 sin interés",
+			expected: &Installments{Count: 12, AmountCents: 1333, InterestFree: true},
+		},
+		{
+			name:     "Installments with interest",
+			input:    "en 6 cuotas de $ 25.990",
+			expected: &Installments{Count: 6, AmountCents: 2599000},
+		},
+		{
+			name:     "Mexican months without interest",
+			input:    "12 meses sin intereses de $ 1,083.25",
+			expected: &Installments{Count: 12, AmountCents: 108325, InterestFree: true},
+		},
+		{
+			name:     "Short format",
+			input:    "18x $ 5.555,50 sin interés",
+			expected: &Installments{Count: 18, AmountCents: 555550, InterestFree: true},
+		},
+		{
+			name:     "Count without amount",
+			input:    "Hasta 12 cuotas sin interés",
+			expected: &Installments{Count: 12, InterestFree: true},
+		},
+		{
+			name:     "Single payment",
+			input:    "en 1 cuota de S/ 159.90",
+			expected: nil,
+		},
+		{
+			name:     "No installments",
+			input:    "Envío gratis",
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseInstallments(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseInstallments(%q) = %+v, expected %+v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseDiscountPercent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+		ok       bool
+	}{
+		{name: "Off label", input: "20% OFF", expected: 20, ok: true},
+		{name: "Spaced label", input: "35 % OFF", expected: 35, ok: true},
+		{name: "Mercado Pago promo", input: "10% OFF con Mercado Pago", expected: 10, ok: true},
+		{name: "No percent", input: "Oferta del día", expected: 0, ok: false},
+		{name: "Empty", input: "", expected: 0, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseDiscountPercent(tt.input)
+			if result != tt.expected || ok != tt.ok {
+				t.Errorf("parseDiscountPercent(%q) = %d, %t, expected %d, %t", tt.input, result, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseAmountText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+		ok       bool
+	}{
+		{name: "Decimal point", input: "S/ 13.33", expected: 1333, ok: true},
+		{name: "Thousands and decimal point", input: "$ 1,083.25", expected: 108325, ok: true},
+		{name: "Thousands point and decimal comma", input: "$ 5.555,50", expected: 555550, ok: true},
+		{name: "Thousands point only", input: "$ 25.990", expected: 2599000, ok: true},
+		{name: "Whole amount", input: "199", expected: 19900, ok: true},
+		{name: "No amount", input: "S/", expected: 0, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseAmountText(tt.input)
+			if result != tt.expected || ok != tt.ok {
+				t.Errorf("parseAmountText(%q) = %d, %t, expected %d, %t", tt.input, result, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPriceTermsOfProductAndCards(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	product, err := fetcher.Product(context.Background(), fixtureProductUrl)
+	if err != nil {
+		t.Fatalf("Product failed: %v", err)
+	}
+	expected := Price{
+		AmountCents:     15990,
+		CurrencyCode:    product.Price.CurrencyCode,
+		ListAmountCents: 19990,
+		DiscountPercent: 20,
+		Installments:    &Installments{Count: 12, AmountCents: 1333, InterestFree: true},
+		Promotions:      []string{"10% OFF con Mercado Pago"},
+	}
+	if !reflect.DeepEqual(product.Price, expected) {
+		t.Errorf("product price = %+v, expected %+v", product.Price, expected)
+	}
+
+	cards, err := ScrapeListingCards(context.Background(), fetcher, fixtureSearchUrl, 10, nil)
+	if err != nil {
+		t.Fatalf("ScrapeListingCards failed: %v", err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("ScrapeListingCards returned %d cards, expected the 3 product cards of both pages", len(cards))
+	}
+	first := cards[0]
+	if first.Url != fixtureProductUrl || first.Title != "Teclado Mecánico Redragon Kumara K552 Rgb" {
+		t.Errorf("first card = %q %q", first.Url, first.Title)
+	}
+	expected.ListAmountCents = 19900
+	if !reflect.DeepEqual(first.Price, expected) {
+		t.Errorf("first card price = %+v, expected %+v", first.Price, expected)
+	}
+	if second := cards[1].Price; second.AmountCents != 22900 || second.ListAmountCents != 0 || second.Installments != nil {
+		t.Errorf("undiscounted card price = %+v", second)
+	}
+}
+
+func TestSearchResultCarriesListingCards(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	result, err := RunMeliSearchWithFetcher(context.Background(), fetcher, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithFetcher failed: %v", err)
+	}
+	if len(result.Cards) != 3 || result.Cards[0].Url != fixtureProductUrl {
+		t.Fatalf("result cards = %+v, expected the 3 cards in search rank", result.Cards)
+	}
+	card := result.Products[0].Card
+	if card == nil || card.Price.ListAmountCents != 19900 || card.Price.DiscountPercent != 20 || !card.Shipping.Full() {
+		t.Fatalf("first product card = %+v, expected the discounted full card", card)
+	}
+
+	records := meliProductCsvRecords(result.Products)
+	column := map[string]int{}
+	for i, name := range records[0] {
+		column[name] = i
+	}
+	row := records[1]
+	if row[column["Card list price"]] == "" || row[column["Card discount %"]] != "20" || row[column["Card fulfillment"]] != string(FulfillmentFull) {
+		t.Errorf("card columns of the first row = %q %q %q", row[column["Card list price"]], row[column["Card discount %"]], row[column["Card fulfillment"]])
+	}
+}
@@ -0,0 +1,178 @@
+package gejie
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit is how hard a single host may be hit, a token bucket refilled at RequestsPerMinute
+// holding up to Burst navigations, every wait is lengthened by a random jitter in [MinJitter, MaxJitter]
+type HostLimit struct {
+	RequestsPerMinute float64
+	Burst             int
+	MinJitter         time.Duration
+	MaxJitter         time.Duration
+}
+
+// DefaultHostLimit spaces navigations to a host 2 to 3 seconds apart
+func DefaultHostLimit() HostLimit {
+	return HostLimit{
+		RequestsPerMinute: 30,
+		Burst:             1,
+		MinJitter:         0,
+		MaxJitter:         1 * time.Second,
+	}
+}
+
+func (l HostLimit) jitter() time.Duration {
+	if l.MaxJitter <= l.MinJitter {
+		return l.MinJitter
+	}
+	return l.MinJitter + time.Duration(rand.Int63n(int64(l.MaxJitter-l.MinJitter)))
+}
+
+// tokenBucket tracks the tokens of one host, tokens go negative for navigations waiting their turn
+type tokenBucket struct {
+	limit  HostLimit
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token and returns how long to wait until it is available
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.limit.RequestsPerMinute <= 0 {
+		return b.limit.jitter()
+	}
+	burst := float64(b.limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	perToken := time.Duration(float64(time.Minute) / b.limit.RequestsPerMinute)
+	b.tokens += float64(now.Sub(b.last)) / float64(perToken)
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return b.limit.jitter()
+	}
+	return time.Duration(-b.tokens*float64(perToken)) + b.limit.jitter()
+}
+
+// RateLimiter is a token bucket per host shared by every scraper of a run, hosts get the limit
+// set for them or for their closest parent domain, falling back to the default limit
+type RateLimiter struct {
+	defaults HostLimit
+	mu       sync.Mutex
+	limits   map[string]HostLimit
+	buckets  map[string]*tokenBucket
+}
+
+func NewRateLimiter(defaults HostLimit) *RateLimiter {
+	return &RateLimiter{
+		defaults: defaults,
+		limits:   make(map[string]HostLimit),
+		buckets:  make(map[string]*tokenBucket),
+	}
+}
+
+// SetHostLimit sets the limit of host and its subdomains, e.g. "mercadolibre.com.pe" also covers
+// articulo.mercadolibre.com.pe. buckets already in use keep their tokens
+func (l *RateLimiter) SetHostLimit(host string, limit HostLimit) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host = strings.ToLower(host)
+	l.limits[host] = limit
+	for bucketHost, bucket := range l.buckets {
+		bucket.limit = l.limitFor(bucketHost)
+	}
+}
+
+// SlowDown makes sure navigations to host are at least interval apart, e.g. for a robots.txt
+// Crawl-delay, limits already slower than that are kept
+func (l *RateLimiter) SlowDown(host string, interval time.Duration) {
+	if l == nil || interval <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	host = strings.ToLower(host)
+	limit := l.limitFor(host)
+	rpm := float64(time.Minute) / float64(interval)
+	if limit.RequestsPerMinute > 0 && limit.RequestsPerMinute <= rpm && limit.Burst <= 1 {
+		return
+	}
+	if limit.RequestsPerMinute <= 0 || rpm < limit.RequestsPerMinute {
+		limit.RequestsPerMinute = rpm
+	}
+	limit.Burst = 1
+	l.limits[host] = limit
+	if bucket, ok := l.buckets[host]; ok {
+		bucket.limit = limit
+	}
+}
+
+// limitFor returns the limit of the closest configured domain of host, callers must hold the lock
+func (l *RateLimiter) limitFor(host string) HostLimit {
+	for domain := host; domain != ""; {
+		if limit, ok := l.limits[domain]; ok {
+			return limit
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return l.defaults
+}
+
+// reserve takes the next slot of host and returns how long to wait for it
+func (l *RateLimiter) reserve(host string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[host]
+	if !ok {
+		limit := l.limitFor(host)
+		burst := float64(limit.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		bucket = &tokenBucket{limit: limit, tokens: burst, last: now}
+		l.buckets[host] = bucket
+	}
+	return bucket.reserve(now)
+}
+
+// Wait blocks until the host of rawUrl may be navigated to, it returns early with the context
+// error when ctx is done. a nil limiter never waits
+func (l *RateLimiter) Wait(ctx context.Context, rawUrl string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	host := rawUrl
+	if parsedUrl, err := url.Parse(rawUrl); err == nil && parsedUrl.Host != "" {
+		host = strings.ToLower(parsedUrl.Hostname())
+	}
+	return sleepContext(ctx, l.reserve(host, time.Now()))
+}
+
+// sleepContext sleeps for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,100 @@
+package gejie
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSpacesSameHost(t *testing.T) {
+	// 2000 requests per minute is one every 30ms
+	limiter := NewRateLimiter(HostLimit{RequestsPerMinute: 2000, Burst: 1})
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.Wait(context.Background(), "https://articulo.mercadolibre.com.pe/MPE-1")
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("3 visits to the same host took %v, expected at least 60ms", elapsed)
+	}
+
+	start = time.Now()
+	limiter.Wait(context.Background(), "https://listado.mercadolibre.com.pe/unit-test")
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("first visit to another host waited %v, expected no wait", elapsed)
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    HostLimit
+		offsets  []time.Duration
+		expected []time.Duration
+	}{
+		{
+			name:     "Burst is spent before waiting",
+			limit:    HostLimit{RequestsPerMinute: 60, Burst: 3},
+			offsets:  []time.Duration{0, 0, 0, 0, 0},
+			expected: []time.Duration{0, 0, 0, time.Second, 2 * time.Second},
+		},
+		{
+			name:     "Tokens refill over time",
+			limit:    HostLimit{RequestsPerMinute: 60, Burst: 1},
+			offsets:  []time.Duration{0, 0, 3 * time.Second, 3 * time.Second},
+			expected: []time.Duration{0, time.Second, 0, time.Second},
+		},
+		{
+			name:     "Refill is capped at the burst",
+			limit:    HostLimit{RequestsPerMinute: 60, Burst: 2},
+			offsets:  []time.Duration{0, time.Minute, time.Minute, time.Minute},
+			expected: []time.Duration{0, 0, 0, time.Second},
+		},
+		{
+			name:     "Fixed jitter is added to every wait",
+			limit:    HostLimit{RequestsPerMinute: 60, Burst: 1, MinJitter: 100 * time.Millisecond, MaxJitter: 100 * time.Millisecond},
+			offsets:  []time.Duration{0, 0},
+			expected: []time.Duration{100 * time.Millisecond, 1100 * time.Millisecond},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.limit)
+			start := time.Now()
+			for i, offset := range tt.offsets {
+				if wait := limiter.reserve("articulo.mercadolibre.com.pe", start.Add(offset)); wait != tt.expected[i] {
+					t.Errorf("reservation %d waits %v, expected %v", i, wait, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterHostLimits(t *testing.T) {
+	limiter := NewRateLimiter(HostLimit{RequestsPerMinute: 60, Burst: 1})
+	limiter.SetHostLimit("mercadolibre.com.pe", HostLimit{RequestsPerMinute: 6, Burst: 1})
+	limiter.SetHostLimit("listado.mercadolibre.com.pe", HostLimit{RequestsPerMinute: 0})
+
+	tests := []struct {
+		host     string
+		expected float64
+	}{
+		{"articulo.mercadolibre.com.pe", 6},
+		{"mercadolibre.com.pe", 6},
+		{"listado.mercadolibre.com.pe", 0},
+		{"www.zhipin.com", 60},
+	}
+	for _, tt := range tests {
+		if limit := limiter.limitFor(tt.host); limit.RequestsPerMinute != tt.expected {
+			t.Errorf("limitFor(%q) = %v requests per minute, expected %v", tt.host, limit.RequestsPerMinute, tt.expected)
+		}
+	}
+}
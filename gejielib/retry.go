@@ -0,0 +1,54 @@
+package gejie
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides whether and when a failed url is tried again
+type RetryPolicy struct {
+	// MaxAttempts counts the first try, 1 disables retries
+	MaxAttempts int
+	// BaseDelay is the backoff before the second attempt, doubling for every attempt after it up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   2 * time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// ShouldRetry tells whether a url that failed with err after attempts tries gets another one
+func (p *RetryPolicy) ShouldRetry(err error, attempts int) bool {
+	return p != nil && attempts < p.MaxAttempts && IsRetriable(err)
+}
+
+// Backoff returns how long to wait after the given number of failed attempts, the exponential
+// delay is jittered between half and all of it so workers that failed together spread out
+func (p *RetryPolicy) Backoff(attempts int) time.Duration {
+	if p == nil || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < attempts && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// gaveUp wraps the last error of a url that failed attempts times, keeping its kind for errors.Is
+func gaveUp(err error, attempts int) error {
+	if attempts <= 1 {
+		return err
+	}
+	return fmt.Errorf("gave up after %d attempts: %w", attempts, err)
+}
@@ -0,0 +1,52 @@
+package gejie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 500 * time.Millisecond}
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond},
+		{10, 500 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if delay := policy.Backoff(tt.attempts); delay < tt.max/2 || delay > tt.max {
+				t.Errorf("Backoff(%d) = %v, expected between %v and %v", tt.attempts, delay, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 3}
+	timeout := newScrapeError(ErrTimeout, "https://example.com", "goto", nil)
+	notFound := statusError("https://example.com", 404)
+
+	if !policy.ShouldRetry(timeout, 2) {
+		t.Errorf("timeout after 2 of 3 attempts was not retried")
+	}
+	if policy.ShouldRetry(timeout, 3) {
+		t.Errorf("timeout after 3 of 3 attempts was retried")
+	}
+	if policy.ShouldRetry(notFound, 1) {
+		t.Errorf("not found page was retried")
+	}
+	var noPolicy *RetryPolicy
+	if noPolicy.ShouldRetry(timeout, 1) {
+		t.Errorf("nil policy retried")
+	}
+	if err := gaveUp(timeout, 3); !errors.Is(err, ErrTimeout) {
+		t.Errorf("gaveUp(%v) lost the error kind", err)
+	}
+}
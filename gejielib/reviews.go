@@ -0,0 +1,237 @@
+package gejie
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MeliReview is one customer review of a product
+type MeliReview struct {
+	// Rating is the stars given, 1 to 5
+	Rating int
+	Text   string
+	// Date is the day the review was written, zero when DateText could not be read
+	Date     time.Time
+	DateText string
+	// Variant is the variant the reviewer bought, e.g. "Color: Negro"
+	Variant      string
+	HelpfulVotes int
+	ImageUrls    []string
+}
+
+// ReviewHistogram counts reviews by their stars
+type ReviewHistogram struct {
+	// Stars holds the number of 1 to 5 star reviews at 0 to 4
+	Stars   [5]int
+	Total   int
+	Average float64
+}
+
+// NewReviewHistogram counts the stars of reviews, reviews without a rating are left out
+func NewReviewHistogram(reviews []MeliReview) ReviewHistogram {
+	h := ReviewHistogram{}
+	sum := 0
+	for _, review := range reviews {
+		if review.Rating < 1 || review.Rating > 5 {
+			continue
+		}
+		h.Stars[review.Rating-1]++
+		h.Total++
+		sum += review.Rating
+	}
+	if h.Total > 0 {
+		h.Average = float64(sum) / float64(h.Total)
+	}
+	return h
+}
+
+// Count is the number of reviews giving stars
+func (h ReviewHistogram) Count(stars int) int {
+	if stars < 1 || stars > 5 {
+		return 0
+	}
+	return h.Stars[stars-1]
+}
+
+// Percent is the share of reviews giving stars, 0 to 100
+func (h ReviewHistogram) Percent(stars int) float64 {
+	if h.Total == 0 {
+		return 0
+	}
+	return float64(h.Count(stars)) * 100 / float64(h.Total)
+}
+
+// MeliReviews are the reviews of a product as the reviews modal lists them
+type MeliReviews struct {
+	ProductUrl string
+	// ReviewsUrl is the first page of the reviews modal
+	ReviewsUrl string
+	Reviews    []MeliReview
+	Histogram  ReviewHistogram
+}
+
+// ScrapeReviews collects up to max reviews of the product at productUrl, following the pages of
+// the reviews modal the product page embeds. max 0 collects every review. the reviews scraped
+// before a page failed are returned along with its error
+func ScrapeReviews(ctx context.Context, loader DocumentLoader, productUrl string, max int) (*MeliReviews, error) {
+	doc, finalUrl, err := loader.Document(ctx, productUrl)
+	if err != nil && !pausedDocument(doc, err) {
+		return nil, err
+	}
+	reviewsUrl := reviewsUrlFromHTML(doc, finalUrl)
+	if reviewsUrl == "" {
+		// the modal is only embedded once the reviews section scrolls into view on some pages
+		reviewsUrl = reviewsUrlOf(finalUrl)
+	}
+	if reviewsUrl == "" {
+		return nil, newScrapeError(ErrLayoutChanged, productUrl, "find reviews", nil)
+	}
+
+	result := &MeliReviews{ProductUrl: productUrl, ReviewsUrl: reviewsUrl, Reviews: []MeliReview{}}
+	visited := map[string]bool{}
+	pageUrl := reviewsUrl
+	for currentPage := 1; max <= 0 || len(result.Reviews) < max; currentPage++ {
+		visited[pageUrl] = true
+		doc, finalUrl, err := loader.Document(ctx, pageUrl)
+		if err != nil {
+			result.Histogram = NewReviewHistogram(result.Reviews)
+			return result, err
+		}
+		selectors := selectorsFor(SiteMeli, finalUrl)
+		reviews := reviewsFromHTML(doc, selectors)
+		fmt.Printf("found %d reviews on page %d\n", len(reviews), currentPage)
+		if len(reviews) == 0 {
+			break
+		}
+		if remaining := max - len(result.Reviews); max > 0 && len(reviews) > remaining {
+			reviews = reviews[:remaining]
+		}
+		result.Reviews = append(result.Reviews, reviews...)
+
+		next := selectors.Find(doc, SelectorReviewsNextPage).First().AttrOr("href", "")
+		if next == "" {
+			break
+		}
+		if pageUrl, err = ResolveURL(finalUrl, next); err != nil || visited[pageUrl] {
+			log.Printf("not following reviews page link %q: %v", next, err)
+			break
+		}
+	}
+	result.Histogram = NewReviewHistogram(result.Reviews)
+	return result, nil
+}
+
+// reviewsUrlFromHTML reads the url of the reviews modal, the src of its iframe or the href of
+// the link opening it
+func reviewsUrlFromHTML(doc *goquery.Document, pageUrl string) string {
+	frame := selectorsFor(SiteMeli, pageUrl).Find(doc, SelectorReviewsFrame).First()
+	src := frame.AttrOr("src", "")
+	if src == "" || src == "about:blank" {
+		src = frame.AttrOr("data-src", frame.AttrOr("href", ""))
+	}
+	if src == "" {
+		return ""
+	}
+	reviewsUrl, err := ResolveURL(pageUrl, src)
+	if err != nil {
+		return ""
+	}
+	return reviewsUrl
+}
+
+// reviewsUrlOf builds the url of the reviews modal of the item of productUrl, e.g.
+// https://www.mercadolibre.com.pe/noindex/catalog/reviews/MPE600000001?noIndex=true&access=view_all&modal=true
+func reviewsUrlOf(productUrl string) string {
+	parsed, err := url.Parse(productUrl)
+	if err != nil {
+		return ""
+	}
+	match := meliItemRegex.FindStringSubmatch(parsed.Path)
+	if match == nil {
+		return ""
+	}
+	host := strings.TrimPrefix(parsed.Host, "articulo.")
+	if !strings.HasPrefix(host, "www.") {
+		host = "www." + host
+	}
+	return fmt.Sprintf("https://%s/noindex/catalog/reviews/%s%s?noIndex=true&access=view_all&modal=true",
+		host, strings.ToUpper(match[1]), match[2])
+}
+
+// reviewsFromHTML reads the reviews of a page of the reviews modal
+func reviewsFromHTML(doc *goquery.Document, selectors Selectors) []MeliReview {
+	reviews := []MeliReview{}
+	selectors.Find(doc, SelectorReviewItems).Each(func(_ int, item *goquery.Selection) {
+		review := MeliReview{
+			Rating:   parseReviewRating(selectors.Find(item, SelectorReviewRating).First().Text()),
+			Text:     normalizeDescription(selectors.Find(item, SelectorReviewText).First().Text()),
+			DateText: strings.TrimSpace(selectors.Find(item, SelectorReviewDate).First().Text()),
+			Variant:  strings.Join(strings.Fields(selectors.Find(item, SelectorReviewVariant).First().Text()), " "),
+		}
+		review.Date, _ = parseReviewDate(review.DateText)
+		review.HelpfulVotes = parseHelpfulVotes(selectors.Find(item, SelectorReviewHelpful).First().Text())
+		selectors.Find(item, SelectorReviewImages).Each(func(_ int, img *goquery.Selection) {
+			if src := imageSrc(img); src != "" {
+				review.ImageUrls = append(review.ImageUrls, src)
+			}
+		})
+		if review.Rating > 0 || review.Text != "" {
+			reviews = append(reviews, review)
+		}
+	})
+	return reviews
+}
+
+var (
+	reviewRatingRegex  = regexp.MustCompile(`\b([1-5])\b`)
+	helpfulVotesRegex  = regexp.MustCompile(`\d+`)
+	reviewDateRegex    = regexp.MustCompile(`(\d{1,2})\s+(?:de\s+)?([a-z]+)\.?\s+(?:de\s+)?(\d{4})`)
+	reviewNumericRegex = regexp.MustCompile(`(\d{1,2})/(\d{1,2})/(\d{4})`)
+)
+
+// parseReviewRating reads the stars of texts like "Calificación 4 de 5", 0 when there are none
+func parseReviewRating(s string) int {
+	match := reviewRatingRegex.FindStringSubmatch(s)
+	if match == nil {
+		return 0
+	}
+	stars, _ := strconv.Atoi(match[1])
+	return stars
+}
+
+// parseHelpfulVotes reads the count of the "Es útil" button, 0 when nobody voted
+func parseHelpfulVotes(s string) int {
+	votes, _ := strconv.Atoi(helpfulVotesRegex.FindString(s))
+	return votes
+}
+
+// parseReviewDate reads review dates like "12 mar. 2025", "3 de marzo de 2025" or "12/03/2025"
+func parseReviewDate(s string) (time.Time, bool) {
+	lower := strings.ToLower(s)
+	if match := reviewDateRegex.FindStringSubmatch(lower); match != nil {
+		for name, month := range spanishMonths {
+			if len(match[2]) >= 3 && strings.HasPrefix(name, match[2]) {
+				day, _ := strconv.Atoi(match[1])
+				year, _ := strconv.Atoi(match[3])
+				return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), true
+			}
+		}
+	}
+	if match := reviewNumericRegex.FindStringSubmatch(lower); match != nil {
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year, _ := strconv.Atoi(match[3])
+		if month >= 1 && month <= 12 {
+			return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), true
+		}
+	}
+	return time.Time{}, false
+}
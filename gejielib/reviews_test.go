@@ -0,0 +1,170 @@
+package gejie
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseReviewRating(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{name: "Hidden label", input: "Calificación 4 de 5", expected: 4},
+		{name: "Stars", input: "5 estrellas", expected: 5},
+		{name: "One star", input: "Calificación 1 de 5", expected: 1},
+		{name: "No rating", input: "Calificación", expected: 0},
+		{name: "Empty", input: "", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseReviewRating(tt.input)
+			if result != tt.expected {
+				t.Errorf("parseReviewRating(%q) = %d, expected %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseReviewDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+		ok       bool
+	}{
+		{name: "Short month", input: "12 mar. 2025", expected: time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "Short month without dot", input: "5 sep 2024", expected: time.Date(2024, time.September, 5, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "Setiembre", input: "5 set. 2024", expected: time.Date(2024, time.September, 5, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "Long month", input: "3 de diciembre de 2024", expected: time.Date(2024, time.December, 3, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "Numeric", input: "20/11/2024", expected: time.Date(2024, time.November, 20, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "Relative", input: "Hace 2 días", ok: false},
+		{name: "Empty", input: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseReviewDate(tt.input)
+			if ok != tt.ok || !result.Equal(tt.expected) {
+				t.Errorf("parseReviewDate(%q) = %s %v, expected %s %v", tt.input, result, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseHelpfulVotes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int
+	}{
+		{name: "Count", input: "14", expected: 14},
+		{name: "Label", input: "Es útil 3", expected: 3},
+		{name: "No votes", input: "Es útil", expected: 0},
+		{name: "Empty", input: "", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseHelpfulVotes(tt.input)
+			if result != tt.expected {
+				t.Errorf("parseHelpfulVotes(%q) = %d, expected %d", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewReviewHistogram(t *testing.T) {
+	reviews := []MeliReview{{Rating: 5}, {Rating: 5}, {Rating: 4}, {Rating: 1}, {Rating: 0}}
+	histogram := NewReviewHistogram(reviews)
+	if histogram.Stars != [5]int{1, 0, 0, 1, 2} || histogram.Total != 4 || histogram.Average != 3.75 {
+		t.Errorf("NewReviewHistogram = %+v, expected 4 rated reviews averaging 3.75", histogram)
+	}
+	if histogram.Count(5) != 2 || histogram.Percent(5) != 50 || histogram.Count(6) != 0 {
+		t.Errorf("histogram counts = %d %v %d", histogram.Count(5), histogram.Percent(5), histogram.Count(6))
+	}
+	if empty := NewReviewHistogram(nil); empty.Percent(3) != 0 || empty.Average != 0 {
+		t.Errorf("empty histogram = %+v", empty)
+	}
+}
+
+func TestReviewsUrlOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Articulo", input: fixtureProductUrl, expected: "https://www.mercadolibre.com.pe/noindex/catalog/reviews/MPE600000001?noIndex=true&access=view_all&modal=true"},
+		{name: "Catalog", input: "https://www.mercadolibre.com.mx/teclado/p/MLM123456789", expected: "https://www.mercadolibre.com.mx/noindex/catalog/reviews/MLM123456789?noIndex=true&access=view_all&modal=true"},
+		{name: "No item", input: "https://www.mercadolibre.com.pe/ofertas", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := reviewsUrlOf(tt.input)
+			if result != tt.expected {
+				t.Errorf("reviewsUrlOf(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestScrapeReviewsOfFixture(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	result, err := ScrapeReviews(context.Background(), fetcher, fixtureProductUrl, 0)
+	if err != nil {
+		t.Fatalf("ScrapeReviews failed: %v", err)
+	}
+	if len(result.Reviews) != 5 {
+		t.Fatalf("ScrapeReviews found %d reviews, expected the 5 of both pages", len(result.Reviews))
+	}
+
+	first := result.Reviews[0]
+	expected := MeliReview{
+		Rating:       5,
+		Text:         "Excelente teclado, los switches azules suenan muy bien.",
+		Date:         time.Date(2025, time.March, 12, 0, 0, 0, 0, time.UTC),
+		DateText:     "12 mar. 2025",
+		Variant:      "Color: Negro",
+		HelpfulVotes: 14,
+		ImageUrls: []string{
+			"https://http2.mlstatic.com/D_NQ_NP_700001-MPE00000001_032025-O.webp",
+			"https://http2.mlstatic.com/D_NQ_NP_700002-MPE00000001_032025-O.webp",
+		},
+	}
+	if !reflect.DeepEqual(first, expected) {
+		t.Errorf("first review = %+v, expected %+v", first, expected)
+	}
+	last := result.Reviews[4]
+	if last.Rating != 1 || last.Variant != "" || len(last.ImageUrls) != 1 || last.Date.IsZero() {
+		t.Errorf("last review = %+v, expected the one star review of the second page", last)
+	}
+	if result.Histogram.Stars != [5]int{1, 0, 1, 1, 2} || result.Histogram.Average != 3.6 {
+		t.Errorf("histogram = %+v", result.Histogram)
+	}
+
+	result, err = ScrapeReviews(context.Background(), fetcher, fixtureProductUrl, 2)
+	if err != nil {
+		t.Fatalf("ScrapeReviews failed: %v", err)
+	}
+	if len(result.Reviews) != 2 || result.Histogram.Total != 2 {
+		t.Errorf("ScrapeReviews with max 2 found %d reviews", len(result.Reviews))
+	}
+}
+
+func TestScrapeReviewsWithoutReviewsPage(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	productUrl := "https://articulo.mercadolibre.com.pe/MPE-600000003-teclado-gamer-logitech-g413-se-_JM"
+	// the page embeds no modal, its url is built from the item id and has no fixture
+	result, err := ScrapeReviews(context.Background(), fetcher, productUrl, 10)
+	if err == nil {
+		t.Fatalf("ScrapeReviews = %+v, expected the missing fixture to fail", result)
+	}
+	if result == nil || result.ReviewsUrl != reviewsUrlOf(productUrl) || len(result.Reviews) != 0 {
+		t.Errorf("ScrapeReviews = %+v, expected no reviews from the built url", result)
+	}
+}
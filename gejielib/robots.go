@@ -0,0 +1,296 @@
+package gejie
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotsUserAgent is the product token matched against the user-agent lines of robots.txt
+const RobotsUserAgent = "gejie"
+
+// robotsCacheTTL is how long a fetched robots.txt is trusted, the limit RFC 9309 recommends
+const robotsCacheTTL = 24 * time.Hour
+
+// robotsErrorTTL is how long an origin whose robots.txt could not be fetched stays disallowed
+// before it is fetched again, a timeout or a 5xx is usually over soon
+const robotsErrorTTL = time.Minute
+
+// robotsRule is an allow or disallow line, pattern supports the * wildcard and a trailing $ anchor
+type robotsRule struct {
+	allow   bool
+	pattern string
+	regex   *regexp.Regexp
+}
+
+// robotsRules are the rules of robots.txt that apply to us
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	expiresAt  time.Time
+}
+
+var (
+	robotsAllowAll    = &robotsRules{}
+	robotsDisallowAll = &robotsRules{rules: []robotsRule{newRobotsRule(false, "/")}}
+)
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	anchored := strings.HasSuffix(pattern, "$")
+	parts := strings.Split(strings.TrimSuffix(pattern, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return robotsRule{allow: allow, pattern: pattern, regex: regexp.MustCompile(expr)}
+}
+
+// parseRobots keeps the groups of robots.txt naming agent, or the * groups when none do
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	type group struct {
+		agents     []string
+		rules      []robotsRule
+		crawlDelay time.Duration
+	}
+	groups := []*group{}
+	var current *group
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share the group that follows them
+			if !inAgents {
+				current = &group{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			// an empty disallow allows everything, the same as no rule
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, newRobotsRule(key == "allow", value))
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	agent = strings.ToLower(agent)
+	matches := func(want string) *robotsRules {
+		var rules *robotsRules
+		for _, g := range groups {
+			for _, a := range g.agents {
+				if a == want {
+					if rules == nil {
+						rules = &robotsRules{}
+					}
+					rules.rules = append(rules.rules, g.rules...)
+					if g.crawlDelay > rules.crawlDelay {
+						rules.crawlDelay = g.crawlDelay
+					}
+					break
+				}
+			}
+		}
+		return rules
+	}
+	if rules := matches(agent); rules != nil {
+		return rules
+	}
+	if rules := matches("*"); rules != nil {
+		return rules
+	}
+	return &robotsRules{}
+}
+
+// allowed applies the longest matching rule to path, an allow wins a tie with a disallow
+func (r *robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allowed := true
+	longest := -1
+	for _, rule := range r.rules {
+		if !rule.regex.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			longest = len(rule.pattern)
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
+
+// RobotsChecker fetches and caches robots.txt per origin and tells whether urls may be crawled,
+// a Crawl-delay slows the origin's host down in the rate limiter. a nil checker allows everything
+type RobotsChecker struct {
+	client  *http.Client
+	agent   string
+	limiter *RateLimiter
+	mu      sync.Mutex
+	cache   map[string]*robotsRules
+	// fetching holds the robots.txt fetch in flight of an origin, other urls of the origin wait for it
+	fetching map[string]chan struct{}
+}
+
+// NewRobotsChecker builds a checker fetching with client (http.DefaultClient with a timeout when nil),
+// feeding crawl delays to limiter when not nil
+func NewRobotsChecker(client *http.Client, limiter *RateLimiter) *RobotsChecker {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RobotsChecker{
+		client:   client,
+		agent:    RobotsUserAgent,
+		limiter:  limiter,
+		cache:    make(map[string]*robotsRules),
+		fetching: make(map[string]chan struct{}),
+	}
+}
+
+// Allowed tells whether rawUrl may be crawled, urls that are not absolute http urls are allowed
+func (c *RobotsChecker) Allowed(ctx context.Context, rawUrl string) bool {
+	if c == nil {
+		return true
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return true
+	}
+	rules := c.rules(ctx, parsed)
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if parsed.RawQuery != "" {
+		path += "?" + parsed.RawQuery
+	}
+	return rules.allowed(path)
+}
+
+// Filter returns the urls that may be crawled, logging the skipped ones
+func (c *RobotsChecker) Filter(ctx context.Context, urls []string) []string {
+	if c == nil {
+		return urls
+	}
+	allowed := make([]string, 0, len(urls))
+	for _, u := range urls {
+		if c.Allowed(ctx, u) {
+			allowed = append(allowed, u)
+		} else {
+			log.Printf("skipping %s: disallowed by robots.txt", u)
+		}
+	}
+	return allowed
+}
+
+// rules returns the cached rules of the origin of u, fetching them when missing or stale.
+// an origin is fetched once at a time without holding the lock, so a burst of urls of a new
+// origin fetches robots.txt once and a slow robots.txt does not hold up other origins
+func (c *RobotsChecker) rules(ctx context.Context, u *url.URL) *robotsRules {
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	c.mu.Lock()
+	for {
+		if rules, ok := c.cache[origin]; ok && time.Now().Before(rules.expiresAt) {
+			c.mu.Unlock()
+			return rules
+		}
+		done, ok := c.fetching[origin]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return robotsDisallowAll
+		}
+		// the fetch cached its rules, unless its caller was cancelled and this one fetches again
+		c.mu.Lock()
+	}
+	done := make(chan struct{})
+	c.fetching[origin] = done
+	c.mu.Unlock()
+
+	rules, err := c.fetch(ctx, origin)
+	stored := *rules
+	stored.expiresAt = time.Now().Add(robotsCacheTTL)
+	if err != nil {
+		stored.expiresAt = time.Now().Add(robotsErrorTTL)
+	}
+	c.mu.Lock()
+	delete(c.fetching, origin)
+	// a fetch cut short by cancellation says nothing about the site and is not cached
+	if ctx.Err() == nil {
+		if err != nil {
+			log.Printf("could not fetch %s/robots.txt, treating the site as disallowed for %s: %v", origin, robotsErrorTTL, err)
+		}
+		c.cache[origin] = &stored
+	}
+	c.mu.Unlock()
+	close(done)
+
+	if stored.crawlDelay > 0 {
+		c.limiter.SlowDown(strings.ToLower(u.Hostname()), stored.crawlDelay)
+	}
+	return &stored
+}
+
+// fetch downloads robots.txt of origin, following RFC 9309 a missing file (4xx) allows
+// everything while an unreachable one (5xx or network error) disallows everything
+func (c *RobotsChecker) fetch(ctx context.Context, origin string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return robotsDisallowAll, err
+	}
+	req.Header.Set("User-Agent", c.agent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return robotsDisallowAll, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		// 500 KiB is the least a crawler must parse per RFC 9309
+		return parseRobots(io.LimitReader(resp.Body, 500*1024), c.agent), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsAllowAll, nil
+	default:
+		return robotsDisallowAll, fmt.Errorf("http status %d", resp.StatusCode)
+	}
+}
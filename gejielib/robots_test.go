@@ -0,0 +1,236 @@
+package gejie
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobotsTxt = `# robots of a unit test site
+User-agent: googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /checkout
+Disallow: /*.pdf$
+Disallow: /search?*sort=
+Allow: /checkout/help
+Crawl-delay: 2
+
+User-agent: gejie
+User-agent: otherbot
+Disallow: /private
+Allow: /private/ok
+Disallow: /tmp/
+Allow: /tmp
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		agent    string
+		path     string
+		expected bool
+	}{
+		{"gejie", "/", true},
+		{"gejie", "/private", false},
+		{"gejie", "/private/page", false},
+		{"gejie", "/private/ok", true},
+		{"gejie", "/checkout", true},
+		{"gejie", "/tmp/a", false},
+		{"gejie", "/robots.txt", true},
+		{"GEJIE", "/private", false},
+		{"someone", "/checkout", false},
+		{"someone", "/checkout/help", true},
+		{"someone", "/catalog.pdf", false},
+		{"someone", "/catalog.pdf?page=2", true},
+		{"someone", "/search?q=teclado&sort=price", false},
+		{"someone", "/search?q=teclado", true},
+		{"googlebot", "/anything", false},
+	}
+
+	for _, tt := range tests {
+		rules := parseRobots(strings.NewReader(testRobotsTxt), tt.agent)
+		if allowed := rules.allowed(tt.path); allowed != tt.expected {
+			t.Errorf("%s allowed %s = %t, expected %t", tt.agent, tt.path, allowed, tt.expected)
+		}
+	}
+
+	if delay := parseRobots(strings.NewReader(testRobotsTxt), "someone").crawlDelay; delay != 2*time.Second {
+		t.Errorf("crawl delay of * group = %v, expected 2s", delay)
+	}
+	if delay := parseRobots(strings.NewReader(testRobotsTxt), "gejie").crawlDelay; delay != 0 {
+		t.Errorf("crawl delay of gejie group = %v, expected none", delay)
+	}
+}
+
+// newRobotsStub serves robots.txt with status and body, counting the fetches
+func newRobotsStub(t *testing.T, status int, body string) (*httptest.Server, *int32) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &fetches
+}
+
+func TestRobotsCheckerStub(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		path     string
+		expected bool
+	}{
+		{name: "Disallowed path", status: 200, body: "User-agent: *\nDisallow: /private\n", path: "/private/1", expected: false},
+		{name: "Allowed path", status: 200, body: "User-agent: *\nDisallow: /private\n", path: "/public/1", expected: true},
+		{name: "Missing robots allows everything", status: 404, path: "/private/1", expected: true},
+		{name: "Unreachable robots disallows everything", status: 503, path: "/public/1", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, fetches := newRobotsStub(t, tt.status, tt.body)
+			checker := NewRobotsChecker(server.Client(), nil)
+			for i := 0; i < 3; i++ {
+				if allowed := checker.Allowed(context.Background(), server.URL+tt.path); allowed != tt.expected {
+					t.Errorf("Allowed(%s) = %t, expected %t", tt.path, allowed, tt.expected)
+				}
+			}
+			if *fetches != 1 {
+				t.Errorf("robots.txt fetched %d times, expected it cached after the first", *fetches)
+			}
+		})
+	}
+}
+
+func TestRobotsCheckerCrawlDelay(t *testing.T) {
+	server, _ := newRobotsStub(t, 200, "User-agent: *\nCrawl-delay: 5\n")
+	limiter := NewRateLimiter(HostLimit{RequestsPerMinute: 60, Burst: 3})
+	checker := NewRobotsChecker(server.Client(), limiter)
+
+	checker.Allowed(context.Background(), server.URL+"/")
+	host := strings.TrimPrefix(server.URL, "http://")
+	host = host[:strings.LastIndex(host, ":")]
+	if limit := limiter.limitFor(host); limit.RequestsPerMinute != 12 || limit.Burst != 1 {
+		t.Errorf("limit after a 5s crawl delay = %+v, expected 12 requests per minute without burst", limit)
+	}
+}
+
+func TestFrontierSkipsDisallowedUrls(t *testing.T) {
+	server, _ := newRobotsStub(t, 200, "User-agent: *\nDisallow: /job_detail/private\n")
+	opts := DefaultFrontierOptions()
+	opts.Robots = NewRobotsChecker(server.Client(), nil)
+
+	for name, f := range frontierImplementations(t, opts) {
+		added := f.AddEntries(context.Background(), []FrontierEntry{
+			{Url: server.URL + "/job_detail/a.html"},
+			{Url: server.URL + "/job_detail/private-b.html"},
+		})
+		if added != 1 {
+			t.Errorf("%s: added %d urls, expected the disallowed one skipped", name, added)
+		}
+		if _, ok := f.Get(server.URL + "/job_detail/private-b.html"); ok {
+			t.Errorf("%s: disallowed url is in the frontier", name)
+		}
+	}
+}
+
+func TestRobotsCheckerSlowOriginBlocksOnlyItself(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(slow.Close)
+	var releaseOnce sync.Once
+	releaseSlow := func() { releaseOnce.Do(func() { close(release) }) }
+	t.Cleanup(releaseSlow)
+	fast, _ := newRobotsStub(t, 404, "")
+	checker := NewRobotsChecker(nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	slowDone := make(chan bool)
+	go func() { slowDone <- checker.Allowed(ctx, slow.URL+"/") }()
+	time.Sleep(50 * time.Millisecond)
+	// waits for the fetch of the first caller and fetches again once that caller is cancelled
+	waiterDone := make(chan bool)
+	go func() { waiterDone <- checker.Allowed(context.Background(), slow.URL+"/other") }()
+	time.Sleep(50 * time.Millisecond)
+
+	fastDone := make(chan bool)
+	go func() { fastDone <- checker.Allowed(context.Background(), fast.URL+"/") }()
+	select {
+	case allowed := <-fastDone:
+		if !allowed {
+			t.Errorf("Allowed on the fast origin = false, expected its missing robots.txt to allow it")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the fast origin waited for the slow robots.txt")
+	}
+
+	cancel()
+	select {
+	case allowed := <-slowDone:
+		if allowed {
+			t.Errorf("Allowed on a cancelled fetch = true, expected disallowed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancelling ctx did not stop the robots.txt fetch")
+	}
+	if _, cached := checker.cache[strings.ToLower(slow.URL)]; cached {
+		t.Errorf("the cancelled fetch was cached")
+	}
+
+	releaseSlow()
+	select {
+	case allowed := <-waiterDone:
+		if !allowed {
+			t.Errorf("Allowed of a caller waiting on the cancelled fetch = false, expected its own fetch to allow it")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the caller waiting on the cancelled fetch did not fetch again")
+	}
+}
+
+func TestRobotsCheckerRefetchesAfterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		ttl    time.Duration
+	}{
+		{name: "Unreachable robots is retried soon", status: 503, ttl: robotsErrorTTL},
+		{name: "Fetched robots is kept", status: 200, ttl: robotsCacheTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, fetches := newRobotsStub(t, tt.status, "User-agent: *\nDisallow: /private\n")
+			checker := NewRobotsChecker(server.Client(), nil)
+			checker.Allowed(context.Background(), server.URL+"/")
+			rules := checker.cache[strings.ToLower(server.URL)]
+			if ttl := time.Until(rules.expiresAt); ttl > tt.ttl || ttl < tt.ttl-time.Minute/2 {
+				t.Errorf("rules expire in %v, expected %v", ttl, tt.ttl)
+			}
+
+			rules.expiresAt = time.Now().Add(-time.Second)
+			checker.Allowed(context.Background(), server.URL+"/")
+			if *fetches != 2 {
+				t.Errorf("robots.txt fetched %d times, expected it fetched again once expired", *fetches)
+			}
+		})
+	}
+}
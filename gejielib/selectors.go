@@ -0,0 +1,374 @@
+package gejie
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/playwright-community/playwright-go"
+	"gopkg.in/yaml.v3"
+)
+
+// Site names the selector packs of a scraped site
+type Site string
+
+const (
+	SiteMeli   Site = "meli"
+	SiteZhipin Site = "zhipin"
+)
+
+// fields of the meli selector pack
+const (
+	SelectorProductLinks  = "product_links"
+	SelectorNextPage      = "next_page"
+	SelectorTitle         = "title"
+	SelectorPriceFraction = "price_fraction"
+	SelectorPriceCents    = "price_cents"
+	SelectorPriceCurrency = "price_currency"
+	SelectorReviews       = "reviews"
+	SelectorReviewsRating = "reviews_rating"
+	SelectorReviewsCount  = "reviews_count"
+	SelectorSold          = "sold"
+	SelectorImages        = "images"
+	SelectorStoreName     = "store_name"
+	SelectorStoreUrl      = "store_url"
+	SelectorStoreLogo     = "store_logo"
+	// the terms around the price, amounts are read with the money fields
+	SelectorPriceOriginal     = "price_original"
+	SelectorPriceDiscount     = "price_discount"
+	SelectorPriceInstallments = "price_installments"
+	SelectorPricePromotions   = "price_promotions"
+	SelectorMoneyFraction     = "money_fraction"
+	SelectorMoneyCents        = "money_cents"
+	// the stock next to the buy button, the paused or finished message and the warranty texts
+	SelectorAvailableQuantity = "available_quantity"
+	SelectorListingStatus     = "listing_status"
+	SelectorWarranty          = "warranty"
+	// the delivery promises, badges and pickup options of the shipping box
+	SelectorShipping              = "shipping"
+	SelectorShippingFull          = "shipping_full"
+	SelectorShippingInternational = "shipping_international"
+	SelectorShippingPickup        = "shipping_pickup"
+	// SelectorDescription is the element holding the description text
+	SelectorDescription       = "description"
+	SelectorDescriptionExpand = "description_expand"
+	// the spec tables, rows are looked up in their section and names and values in their row
+	SelectorSpecSections     = "spec_sections"
+	SelectorSpecSectionTitle = "spec_section_title"
+	SelectorSpecRows         = "spec_rows"
+	SelectorSpecName         = "spec_name"
+	SelectorSpecValue        = "spec_value"
+	// the variant pickers, labels, selected values and option links are looked up in their picker
+	SelectorVariationPickers  = "variation_pickers"
+	SelectorVariationLabel    = "variation_label"
+	SelectorVariationSelected = "variation_selected"
+	SelectorVariationOptions  = "variation_options"
+	SelectorBuyButton         = "buy_button"
+	// the cards of a search page, their link, price and price terms are looked up in the card
+	SelectorListingCards      = "listing_cards"
+	SelectorCardPrice         = "card_price"
+	SelectorCardPriceOriginal = "card_price_original"
+	SelectorCardPriceDiscount = "card_price_discount"
+	SelectorCardInstallments  = "card_installments"
+	SelectorCardPromotions    = "card_promotions"
+	// the shipping of a card, looked up in the card
+	SelectorCardShipping              = "card_shipping"
+	SelectorCardShippingFull          = "card_shipping_full"
+	SelectorCardShippingInternational = "card_shipping_international"
+	// the reviews modal a product page embeds, the review fields are looked up in their item
+	SelectorReviewsFrame    = "reviews_frame"
+	SelectorReviewItems     = "review_items"
+	SelectorReviewRating    = "review_rating"
+	SelectorReviewDate      = "review_date"
+	SelectorReviewText      = "review_text"
+	SelectorReviewVariant   = "review_variant"
+	SelectorReviewHelpful   = "review_helpful"
+	SelectorReviewImages    = "review_images"
+	SelectorReviewsNextPage = "reviews_next_page"
+)
+
+// fields of the zhipin selector pack, SelectorListingStatus holds its closed job notice
+const (
+	SelectorJobTitle      = "job_title"
+	SelectorSalary        = "salary"
+	SelectorJobContent    = "content"
+	SelectorJobTags       = "tags"
+	SelectorMoreJobs      = "more_jobs"
+	SelectorMoreJobsLinks = "more_jobs_links"
+)
+
+//go:embed selectors/*.json
+var embeddedSelectorPacks embed.FS
+
+// SelectorPack holds the selectors of a site, or of one country of it, e.g.
+//
+//	{"site": "meli", "country": "mx", "version": 2,
+//	 "fields": {"title": ["h1.ui-pdp-title", "h1.ui-pdp-header__title"]}}
+//
+// every field has a chain of selectors tried in order until one matches
+type SelectorPack struct {
+	Site Site `json:"site" yaml:"site"`
+	// Country is the last label of the site's host, e.g. "mx" or "pe", empty applies to all
+	Country string                   `json:"country,omitempty" yaml:"country,omitempty"`
+	Version int                      `json:"version" yaml:"version"`
+	Fields  map[string][]CssSelector `json:"fields" yaml:"fields"`
+}
+
+func (p SelectorPack) key() string {
+	if p.Country == "" {
+		return string(p.Site)
+	}
+	return string(p.Site) + "/" + p.Country
+}
+
+// validate checks the pack names its site and every selector of it parses
+func (p SelectorPack) validate() error {
+	if p.Site == "" {
+		return fmt.Errorf("selector pack has no site")
+	}
+	for field, chain := range p.Fields {
+		if len(chain) == 0 {
+			return fmt.Errorf("selector pack %s: field %s has no selectors", p.key(), field)
+		}
+		for _, selector := range chain {
+			if _, err := cascadia.ParseGroup(string(selector)); err != nil {
+				return fmt.Errorf("selector pack %s: field %s: invalid selector %q: %w", p.key(), field, selector, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SelectorPacks holds the packs of every site and country
+type SelectorPacks struct {
+	packs map[string]SelectorPack
+}
+
+// DefaultSelectorPacks returns the packs built into the binary
+func DefaultSelectorPacks() *SelectorPacks {
+	entries, err := embeddedSelectorPacks.ReadDir("selectors")
+	if err != nil {
+		panic(err)
+	}
+	packs := &SelectorPacks{packs: map[string]SelectorPack{}}
+	for _, entry := range entries {
+		data, err := embeddedSelectorPacks.ReadFile("selectors/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		pack, err := parseSelectorPack(entry.Name(), data)
+		if err != nil {
+			panic(err)
+		}
+		packs.packs[pack.key()] = pack
+	}
+	return packs
+}
+
+// LoadSelectorPacks reads the pack file at path, or every .json, .yaml and .yml pack of the
+// directory at path
+func LoadSelectorPacks(path string) ([]SelectorPack, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read selector packs: %w", err)
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read selector packs: %w", err)
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".json", ".yaml", ".yml":
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	packs := []SelectorPack{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read selector pack: %w", err)
+		}
+		pack, err := parseSelectorPack(file, data)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
+
+// parseSelectorPack reads a yaml pack when name ends in .yaml or .yml and a json one otherwise
+func parseSelectorPack(name string, data []byte) (SelectorPack, error) {
+	pack := SelectorPack{}
+	var err error
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &pack)
+	default:
+		err = json.Unmarshal(data, &pack)
+	}
+	if err != nil {
+		return pack, fmt.Errorf("could not parse selector pack %s: %w", name, err)
+	}
+	if err := pack.validate(); err != nil {
+		return pack, fmt.Errorf("%s: %w", name, err)
+	}
+	return pack, nil
+}
+
+// Override returns a copy of p where the fields of overrides replace the ones of the pack with
+// the same site and country, fields an override leaves out keep their selectors
+func (p *SelectorPacks) Override(overrides ...SelectorPack) *SelectorPacks {
+	merged := &SelectorPacks{packs: map[string]SelectorPack{}}
+	for key, pack := range p.packs {
+		merged.packs[key] = pack.copy()
+	}
+	for _, override := range overrides {
+		pack, ok := merged.packs[override.key()]
+		if !ok {
+			pack = SelectorPack{Site: override.Site, Country: override.Country, Fields: map[string][]CssSelector{}}
+		}
+		pack.Version = override.Version
+		for field, chain := range override.Fields {
+			pack.Fields[field] = chain
+		}
+		merged.packs[override.key()] = pack
+	}
+	return merged
+}
+
+func (p SelectorPack) copy() SelectorPack {
+	fields := make(map[string][]CssSelector, len(p.Fields))
+	for field, chain := range p.Fields {
+		fields[field] = chain
+	}
+	p.Fields = fields
+	return p
+}
+
+// For resolves the selectors of site in country, country fields take precedence over the site's
+func (p *SelectorPacks) For(site Site, country string) Selectors {
+	selectors := Selectors{Site: site, Fields: map[string][]CssSelector{}}
+	for _, key := range []string{string(site), string(site) + "/" + country} {
+		pack, ok := p.packs[key]
+		if !ok {
+			continue
+		}
+		selectors.Version = pack.Version
+		for field, chain := range pack.Fields {
+			selectors.Fields[field] = chain
+		}
+	}
+	return selectors
+}
+
+// Packs lists the packs sorted by site and country
+func (p *SelectorPacks) Packs() []SelectorPack {
+	packs := make([]SelectorPack, 0, len(p.packs))
+	for _, pack := range p.packs {
+		packs = append(packs, pack)
+	}
+	sort.Slice(packs, func(i, j int) bool { return packs[i].key() < packs[j].key() })
+	return packs
+}
+
+var (
+	selectorPacksMu     sync.RWMutex
+	activeSelectorPacks = DefaultSelectorPacks()
+)
+
+// UseSelectorPacks makes every scrape after it use packs, meant to be called once at startup
+func UseSelectorPacks(packs *SelectorPacks) {
+	selectorPacksMu.Lock()
+	defer selectorPacksMu.Unlock()
+	activeSelectorPacks = packs
+}
+
+// ActiveSelectorPacks returns the packs scrapes use, the built in ones unless UseSelectorPacks replaced them
+func ActiveSelectorPacks() *SelectorPacks {
+	selectorPacksMu.RLock()
+	defer selectorPacksMu.RUnlock()
+	return activeSelectorPacks
+}
+
+// selectorsFor resolves the active selectors of site for the country of pageUrl
+func selectorsFor(site Site, pageUrl string) Selectors {
+	return ActiveSelectorPacks().For(site, urlCountry(pageUrl))
+}
+
+// urlCountry returns the last label of the url's host, e.g. "mx" for mercadolibre.com.mx
+func urlCountry(pageUrl string) string {
+	parsed, err := url.Parse(pageUrl)
+	if err != nil {
+		return ""
+	}
+	host := parsed.Hostname()
+	return host[strings.LastIndex(host, ".")+1:]
+}
+
+// Selectors are the resolved selector chains of one site and country
+type Selectors struct {
+	Site    Site
+	Version int
+	Fields  map[string][]CssSelector
+}
+
+// Chain returns the selectors of field in the order they are tried
+func (s Selectors) Chain(field string) []CssSelector {
+	return s.Fields[field]
+}
+
+// Any joins the chain of field into one selector list, for waiting until any of them shows up
+func (s Selectors) Any(field string) string {
+	parts := make([]string, 0, len(s.Fields[field]))
+	for _, selector := range s.Fields[field] {
+		parts = append(parts, string(selector))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// finder is what goquery documents and selections share
+type finder interface {
+	Find(selector string) *goquery.Selection
+}
+
+// Find returns the matches of the first selector of field's chain that matches within root
+func (s Selectors) Find(root finder, field string) *goquery.Selection {
+	var found *goquery.Selection
+	for _, selector := range s.Fields[field] {
+		found = root.Find(string(selector))
+		if found.Length() > 0 {
+			return found
+		}
+	}
+	if found == nil {
+		// no selectors for the field, return an empty selection
+		return root.Find(":not(*)")
+	}
+	return found
+}
+
+// Locator returns a locator of the first selector of field's chain that matches on page,
+// or of the whole chain when none matches yet so waiting on it catches any of them
+func (s Selectors) Locator(page playwright.Page, field string) playwright.Locator {
+	for _, selector := range s.Fields[field] {
+		locator := page.Locator(string(selector))
+		if count, err := locator.Count(); err == nil && count > 0 {
+			return locator
+		}
+	}
+	return page.Locator(s.Any(field))
+}
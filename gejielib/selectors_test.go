@@ -0,0 +1,130 @@
+package gejie
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultSelectorPacksHaveEveryField(t *testing.T) {
+	packs := DefaultSelectorPacks()
+	expected := map[Site][]string{
+		SiteMeli: {
+			SelectorProductLinks, SelectorNextPage, SelectorTitle, SelectorPriceFraction, SelectorPriceCents,
+			SelectorPriceCurrency, SelectorReviews, SelectorReviewsRating, SelectorReviewsCount, SelectorSold,
+			SelectorImages, SelectorStoreName, SelectorStoreUrl, SelectorStoreLogo,
+		},
+		SiteZhipin: {
+			SelectorJobTitle, SelectorSalary, SelectorJobContent, SelectorJobTags, SelectorMoreJobs, SelectorMoreJobsLinks,
+		},
+	}
+	for site, fields := range expected {
+		selectors := packs.For(site, "")
+		if selectors.Version == 0 {
+			t.Errorf("%s pack has no version", site)
+		}
+		for _, field := range fields {
+			if len(selectors.Chain(field)) == 0 {
+				t.Errorf("%s pack has no selectors for %s", site, field)
+			}
+		}
+	}
+}
+
+func TestSelectorPacksOverride(t *testing.T) {
+	defaults := DefaultSelectorPacks()
+	packs := defaults.Override(
+		SelectorPack{Site: SiteMeli, Version: 7, Fields: map[string][]CssSelector{SelectorTitle: {"h1.new-title"}}},
+		SelectorPack{Site: SiteMeli, Country: "mx", Version: 8, Fields: map[string][]CssSelector{SelectorStoreName: {"h2.mx-store"}}},
+	)
+
+	pe := packs.For(SiteMeli, "pe")
+	if !reflect.DeepEqual(pe.Chain(SelectorTitle), []CssSelector{"h1.new-title"}) || pe.Version != 7 {
+		t.Errorf("pe title chain = %v version %d, expected the override", pe.Chain(SelectorTitle), pe.Version)
+	}
+	if !reflect.DeepEqual(pe.Chain(SelectorImages), defaults.For(SiteMeli, "pe").Chain(SelectorImages)) {
+		t.Errorf("pe images chain = %v, expected the built in one", pe.Chain(SelectorImages))
+	}
+	if pe.Chain(SelectorStoreName)[0] == "h2.mx-store" {
+		t.Errorf("the mx store name selector applies to pe")
+	}
+	mx := packs.For(SiteMeli, "mx")
+	if !reflect.DeepEqual(mx.Chain(SelectorStoreName), []CssSelector{"h2.mx-store"}) || mx.Version != 8 {
+		t.Errorf("mx store name chain = %v version %d, expected the country pack", mx.Chain(SelectorStoreName), mx.Version)
+	}
+	if defaults.For(SiteMeli, "").Chain(SelectorTitle)[0] == "h1.new-title" {
+		t.Errorf("Override changed the packs it was called on")
+	}
+}
+
+func TestLoadSelectorPacks(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"meli-pe.yaml": "site: meli\ncountry: pe\nversion: 2\nfields:\n  title:\n    - h1.pe-title\n    - h1.ui-pdp-title\n",
+		"zhipin.json":  `{"site": "zhipin", "version": 3, "fields": {"salary": ["span.salary"]}}`,
+		"notes.txt":    "not a pack",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packs, err := LoadSelectorPacks(dir)
+	if err != nil {
+		t.Fatalf("LoadSelectorPacks failed: %v", err)
+	}
+	if len(packs) != 2 {
+		t.Fatalf("LoadSelectorPacks read %d packs, expected 2", len(packs))
+	}
+	merged := DefaultSelectorPacks().Override(packs...)
+	if chain := merged.For(SiteMeli, "pe").Chain(SelectorTitle); !reflect.DeepEqual(chain, []CssSelector{"h1.pe-title", "h1.ui-pdp-title"}) {
+		t.Errorf("pe title chain = %v", chain)
+	}
+	if chain := merged.For(SiteZhipin, "com").Chain(SelectorSalary); !reflect.DeepEqual(chain, []CssSelector{"span.salary"}) {
+		t.Errorf("zhipin salary chain = %v", chain)
+	}
+
+	invalid := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(invalid, []byte(`{"site": "meli", "fields": {"title": ["h1[unclosed"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSelectorPacks(invalid); err == nil || !strings.Contains(err.Error(), "invalid selector") {
+		t.Errorf("LoadSelectorPacks of an invalid selector returned %v", err)
+	}
+}
+
+func TestSelectorsFindFallsBack(t *testing.T) {
+	doc := structuredDoc(t, `<html><body><h2 class="second">Tienda</h2><h2 class="third">Otra</h2></body></html>`)
+	selectors := Selectors{Fields: map[string][]CssSelector{SelectorStoreName: {"h2.first", "h2.second", "h2.third"}}}
+
+	if text := selectors.Find(doc, SelectorStoreName).Text(); text != "Tienda" {
+		t.Errorf("Find = %q, expected the first selector of the chain that matches", text)
+	}
+	if found := selectors.Find(doc, SelectorTitle); found.Length() != 0 {
+		t.Errorf("Find of a field without selectors matched %d elements", found.Length())
+	}
+}
+
+func TestProductFromHTMLUsesActiveSelectors(t *testing.T) {
+	UseSelectorPacks(DefaultSelectorPacks().Override(SelectorPack{
+		Site: SiteMeli, Country: "mx", Version: 2,
+		Fields: map[string][]CssSelector{SelectorTitle: {"h1.hotfix-title"}},
+	}))
+	t.Cleanup(func() { UseSelectorPacks(DefaultSelectorPacks()) })
+
+	html := `<html><body><h1 class="hotfix-title">Teclado</h1><span class="andes-money-amount__fraction">10</span>
+<div class="ui-pdp-price__second-line"><span class="andes-money-amount__fraction">25</span></div></body></html>`
+	product, _, err := productFromHTML(structuredDoc(t, html), structuredPageUrl, structuredPageUrl)
+	if err != nil {
+		t.Fatalf("productFromHTML failed: %v", err)
+	}
+	if product.Title != "Teclado" {
+		t.Errorf("Title = %q, expected it from the hotfix selector", product.Title)
+	}
+	if product.Price.AmountCents != 2500 {
+		t.Errorf("Price.AmountCents = %d, expected 2500 from the fallback price selector", product.Price.AmountCents)
+	}
+}
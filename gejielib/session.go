@@ -0,0 +1,232 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+var errBrowserClosed = errors.New("browser manager is closed")
+
+// browserSession is a product context of the manager with one open page, reused by one user at a
+// time across navigations. the page is replaced after PageRecycleAfter navigations to keep its
+// memory in check, and with a proxy pool the whole context moves to the next proxy when due
+type browserSession struct {
+	bm *BrowserManager
+	// mu guards the fields below, the manager closes sessions still in use when it shuts down
+	mu                 sync.Mutex
+	context            playwright.BrowserContext
+	page               playwright.Page
+	proxy              *Proxy
+	pageNavigations    int
+	contextNavigations int
+}
+
+func (s *browserSession) open() error {
+	context, proxy, err := newProductContext(s.bm)
+	if err != nil {
+		return err
+	}
+	s.context, s.proxy, s.contextNavigations = context, proxy, 0
+	return nil
+}
+
+func (s *browserSession) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeLocked()
+}
+
+func (s *browserSession) closeLocked() {
+	if s.page != nil {
+		s.page.Close()
+	}
+	if s.context != nil {
+		s.context.Close()
+	}
+	s.context, s.page, s.proxy = nil, nil, nil
+}
+
+// nextPage returns the page for the next navigation, opening a context or page when needed
+func (s *browserSession) nextPage() (playwright.Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bm.isClosed() {
+		return nil, errBrowserClosed
+	}
+	if s.context == nil {
+		// the last rotation failed
+		if err := s.open(); err != nil {
+			return nil, err
+		}
+	}
+	recycleAfter := s.bm.opts.PageRecycleAfter
+	if s.page != nil && recycleAfter > 0 && s.pageNavigations >= recycleAfter {
+		s.page.Close()
+		s.page = nil
+	}
+	if s.page == nil {
+		page, err := s.context.NewPage()
+		if err != nil {
+			return nil, fmt.Errorf("could not create page: %w", err)
+		}
+		s.page, s.pageNavigations = page, 0
+	}
+	s.pageNavigations++
+	s.contextNavigations++
+	return s.page, nil
+}
+
+// finish records the outcome of the last navigation against the proxy, moving the session to the
+// next proxy right away when it timed out or got blocked, or after RotateEvery navigations
+func (s *browserSession) finish(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proxy == nil {
+		return
+	}
+	pool := s.bm.Proxies()
+	pool.Report(*s.proxy, err)
+	rotateEvery := pool.RotateEvery()
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrBlocked) || (rotateEvery > 0 && s.contextNavigations >= rotateEvery) {
+		s.closeLocked()
+		if rotateErr := s.open(); rotateErr != nil {
+			log.Printf("could not rotate proxy: %v", rotateErr)
+		}
+	}
+}
+
+// acquireSession hands out an idle session, or a new one when all are in use
+func (bm *BrowserManager) acquireSession() (*browserSession, error) {
+	bm.mu.Lock()
+	if bm.closed {
+		bm.mu.Unlock()
+		return nil, errBrowserClosed
+	}
+	if n := len(bm.idle); n > 0 {
+		session := bm.idle[n-1]
+		bm.idle = bm.idle[:n-1]
+		bm.mu.Unlock()
+		return session, nil
+	}
+	bm.mu.Unlock()
+
+	// contexts are opened outside the lock so workers starting together do not wait on each other
+	session := &browserSession{bm: bm}
+	if err := session.open(); err != nil {
+		return nil, err
+	}
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.closed {
+		session.close()
+		return nil, errBrowserClosed
+	}
+	bm.sessions[session] = true
+	return session, nil
+}
+
+func (bm *BrowserManager) isClosed() bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.closed
+}
+
+// releaseSession gives a session back for reuse
+func (bm *BrowserManager) releaseSession(session *browserSession) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.closed || !bm.sessions[session] {
+		return
+	}
+	bm.idle = append(bm.idle, session)
+}
+
+// discardSession closes a session instead of reusing it, e.g. to abort a navigation in flight
+func (bm *BrowserManager) discardSession(session *browserSession) {
+	bm.mu.Lock()
+	delete(bm.sessions, session)
+	bm.mu.Unlock()
+	session.close()
+}
+
+// sessionLease lends a session of the manager to a single user. close is safe to call from
+// another goroutine: it returns an unused session for reuse, and discards one that is navigating
+// so the navigation is aborted
+type sessionLease struct {
+	bm *BrowserManager
+	// mu guards session and busy
+	mu      sync.Mutex
+	session *browserSession
+	busy    bool
+}
+
+func (bm *BrowserManager) leaseSession() (*sessionLease, error) {
+	session, err := bm.acquireSession()
+	if err != nil {
+		return nil, err
+	}
+	return &sessionLease{bm: bm, session: session}, nil
+}
+
+// run calls fn with the session page, the error fn returns is the outcome of its navigation
+func (l *sessionLease) run(fn func(page playwright.Page) error) error {
+	l.mu.Lock()
+	session := l.session
+	if session == nil {
+		l.mu.Unlock()
+		return errors.New("browser session was closed")
+	}
+	page, err := session.nextPage()
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	l.busy = true
+	l.mu.Unlock()
+
+	err = fn(page)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.busy = false
+	if l.session != nil {
+		l.session.finish(err)
+	}
+	return err
+}
+
+func (l *sessionLease) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.session == nil {
+		return
+	}
+	if l.busy {
+		l.bm.discardSession(l.session)
+	} else {
+		l.bm.releaseSession(l.session)
+	}
+	l.session = nil
+}
+
+// withSessionPage runs fn on the page of a leased session, cancelling ctx aborts fn's navigation
+func (bm *BrowserManager) withSessionPage(ctx context.Context, fn func(page playwright.Page) error) error {
+	lease, err := bm.leaseSession()
+	if err != nil {
+		return err
+	}
+	stopClosing := context.AfterFunc(ctx, lease.close)
+	defer stopClosing()
+	defer lease.close()
+
+	err = lease.run(fn)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package gejie
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/playwright-community/playwright-go"
+)
+
+const fixtureProductUrl = "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-mecanico-redragon-kumara-k552-rgb-_JM"
+
+func TestClosedBrowserManagerHandsOutNoSessions(t *testing.T) {
+	bm := &BrowserManager{opts: &BrowserOptions{}, sessions: map[*browserSession]bool{}}
+	bm.Close()
+	bm.Close()
+	if _, err := bm.acquireSession(); !errors.Is(err, errBrowserClosed) {
+		t.Errorf("acquireSession after Close returned %v, expected errBrowserClosed", err)
+	}
+	if _, err := ScrapeProduct(context.Background(), bm, fixtureProductUrl); !errors.Is(err, errBrowserClosed) {
+		t.Errorf("ScrapeProduct after Close returned %v, expected errBrowserClosed", err)
+	}
+}
+
+func TestBrowserManagerReusesSessions(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+
+	for i := 0; i < 3; i++ {
+		if _, err := ScrapeProduct(context.Background(), bm, fixtureProductUrl); err != nil {
+			t.Fatalf("ScrapeProduct #%d failed: %v", i, err)
+		}
+	}
+	// the manager context plus the one product session every scrape reused
+	if contexts := len(bm.GetBrowser().Contexts()); contexts != 2 {
+		t.Errorf("browser has %d contexts after three scrapes, expected 2", contexts)
+	}
+	if len(bm.sessions) != 1 || len(bm.idle) != 1 {
+		t.Errorf("manager has %d sessions, %d idle, expected the one session idle", len(bm.sessions), len(bm.idle))
+	}
+}
+
+func TestBrowserSessionRecyclesPages(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+	bm.opts.PageRecycleAfter = 2
+
+	lease, err := bm.leaseSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lease.close()
+	pages := []playwright.Page{}
+	for i := 0; i < 3; i++ {
+		err := lease.run(func(page playwright.Page) error {
+			pages = append(pages, page)
+			_, err := page.Goto(fixtureProductUrl)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("navigation #%d failed: %v", i, err)
+		}
+	}
+	if pages[0] != pages[1] || pages[1] == pages[2] {
+		t.Errorf("pages were not replaced after 2 navigations: %v", pages)
+	}
+	if !pages[0].IsClosed() {
+		t.Errorf("recycled page was left open")
+	}
+	if open := len(lease.session.context.Pages()); open != 1 {
+		t.Errorf("session context has %d pages open, expected 1", open)
+	}
+}
+
+func TestBrowserManagerShutdownIsLeakFree(t *testing.T) {
+	bm := newReplayBrowserManager(t)
+
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	opts.Concurrency = 3
+	if _, err := RunMeliSearchWithBrowser(context.Background(), bm, &searchUrl, opts); err != nil {
+		t.Fatalf("RunMeliSearchWithBrowser failed: %v", err)
+	}
+	if len(bm.idle) != len(bm.sessions) {
+		t.Errorf("%d of %d sessions were not given back after the search", len(bm.sessions)-len(bm.idle), len(bm.sessions))
+	}
+
+	// a scrape cancelled mid navigation discards its session instead of giving it back
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ScrapeProduct(ctx, bm, fixtureProductUrl); !errors.Is(err, context.Canceled) {
+		t.Errorf("ScrapeProduct with a cancelled context returned %v, expected context.Canceled", err)
+	}
+
+	browser := bm.GetBrowser()
+	contexts := browser.Contexts()
+	bm.Close()
+	if browser.IsConnected() {
+		t.Errorf("browser is still connected after Close")
+	}
+	for _, context := range contexts {
+		if pages := context.Pages(); len(pages) != 0 {
+			t.Errorf("context has %d pages open after Close", len(pages))
+		}
+	}
+	bm.Close()
+	if _, err := ScrapeProduct(context.Background(), bm, fixtureProductUrl); !errors.Is(err, errBrowserClosed) {
+		t.Errorf("ScrapeProduct after Close returned %v, expected errBrowserClosed", err)
+	}
+}
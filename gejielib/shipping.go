@@ -0,0 +1,223 @@
+package gejie
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Fulfillment tells who ships a product
+type Fulfillment string
+
+const (
+	// FulfillmentFull products ship from a meli warehouse, shown with the "Full" badge
+	FulfillmentFull   Fulfillment = "full"
+	FulfillmentSeller Fulfillment = "seller"
+)
+
+// ShippingOrigin tells whether a product ships from the country of the site or from abroad
+type ShippingOrigin string
+
+const (
+	OriginLocal ShippingOrigin = "local"
+	// OriginInternational products are cross border trade (CBT) listings shipped from abroad
+	OriginInternational ShippingOrigin = "international"
+)
+
+// Shipping is the delivery promise of a product page or listing card, zero when none is shown
+type Shipping struct {
+	Fulfillment  Fulfillment
+	FreeShipping bool
+	Origin       ShippingOrigin
+	// Promise is the delivery text as shown, e.g. "Llega gratis mañana"
+	Promise string
+	// Delivery is the window Promise names, nil when it names no day
+	Delivery *DeliveryWindow
+	// PickupOptions are the pickup texts shown, e.g. "Retira gratis en una agencia de Mercado Libre"
+	PickupOptions []string
+}
+
+// DeliveryWindow are the first and last days a delivery may arrive on, the same day for a single day
+type DeliveryWindow struct {
+	Earliest time.Time
+	Latest   time.Time
+}
+
+// Full tells whether meli fulfils the product
+func (s Shipping) Full() bool {
+	return s.Fulfillment == FulfillmentFull
+}
+
+// shippingFields names the selector fields of the shipping of a product page or a listing card
+type shippingFields struct {
+	shipping, full, international, pickup string
+}
+
+var (
+	productShippingFields = shippingFields{SelectorShipping, SelectorShippingFull, SelectorShippingInternational, SelectorShippingPickup}
+	cardShippingFields    = shippingFields{SelectorCardShipping, SelectorCardShippingFull, SelectorCardShippingInternational, ""}
+)
+
+// shippingFromHTML reads the shipping shown under root, delivery days are counted from now
+func shippingFromHTML(root finder, selectors Selectors, fields shippingFields, now time.Time) Shipping {
+	shipping := Shipping{}
+	texts := []string{}
+	selectors.Find(root, fields.shipping).Each(func(_ int, s *goquery.Selection) {
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			texts = append(texts, text)
+		}
+	})
+	if fields.pickup != "" {
+		selectors.Find(root, fields.pickup).Each(func(_ int, s *goquery.Selection) {
+			if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+				shipping.PickupOptions = append(shipping.PickupOptions, text)
+			}
+		})
+	}
+	full := selectors.Find(root, fields.full).Length() > 0
+	international := selectors.Find(root, fields.international)
+	if len(texts) == 0 && len(shipping.PickupOptions) == 0 && !full && international.Length() == 0 {
+		return shipping
+	}
+
+	shipping.Fulfillment = FulfillmentSeller
+	if full {
+		shipping.Fulfillment = FulfillmentFull
+	}
+	shipping.Origin = OriginLocal
+	if international.Length() > 0 || isInternationalShipping(strings.Join(texts, " ")) {
+		shipping.Origin = OriginInternational
+	}
+	// the promise naming a day wins over one like "Envío a todo el país"
+	for _, text := range texts {
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "gratis") {
+			shipping.FreeShipping = true
+		}
+		if shipping.Delivery != nil || !isDeliveryPromise(lower) {
+			continue
+		}
+		if delivery := parseDeliveryWindow(text, now); delivery != nil || shipping.Promise == "" {
+			shipping.Promise = text
+			shipping.Delivery = delivery
+		}
+	}
+	return shipping
+}
+
+// deliveryWords start the delivery promises of meli, "Llega mañana", "Recíbelo el lunes", ...
+var deliveryWords = []string{"llega", "recíbelo", "recibelo", "entrega", "envío"}
+
+func isDeliveryPromise(lower string) bool {
+	for _, word := range deliveryWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isInternationalShipping(text string) bool {
+	lower := strings.ToLower(text)
+	return strings.Contains(lower, "internacional") || strings.Contains(lower, "del exterior") || strings.Contains(lower, "desde el exterior")
+}
+
+var spanishMonths = map[string]time.Month{
+	"enero": time.January, "febrero": time.February, "marzo": time.March, "abril": time.April,
+	"mayo": time.May, "junio": time.June, "julio": time.July, "agosto": time.August,
+	"septiembre": time.September, "setiembre": time.September, "octubre": time.October,
+	"noviembre": time.November, "diciembre": time.December,
+}
+
+var spanishWeekdays = map[string]time.Weekday{
+	"domingo": time.Sunday, "lunes": time.Monday, "martes": time.Tuesday, "miércoles": time.Wednesday,
+	"miercoles": time.Wednesday, "jueves": time.Thursday, "viernes": time.Friday, "sábado": time.Saturday,
+	"sabado": time.Saturday,
+}
+
+var (
+	deliveryDaysRegex = regexp.MustCompile(`(\d+)\s*(?:a|y|-)\s*(\d+)\s*días|(\d+)\s*días`)
+	deliveryDateRegex = regexp.MustCompile(`\b(\d{1,2})\b(?:\s+de\s+([a-z]+))?`)
+	deliveryDayRegex  = regexp.MustCompile(`\b(domingo|lunes|martes|miércoles|miercoles|jueves|viernes|sábado|sabado)\b`)
+)
+
+// parseDeliveryWindow reads the days of a delivery promise counted from now, e.g. "Llega mañana",
+// "Llega el jueves 12 de marzo", "Llega entre el 12 y el 20 de febrero" or "Llega en 10 a 15 días".
+// nil when the promise names no day
+func parseDeliveryWindow(s string, now time.Time) *DeliveryWindow {
+	lower := strings.ToLower(s)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	window := func(from, to time.Time) *DeliveryWindow {
+		return &DeliveryWindow{Earliest: from, Latest: to}
+	}
+
+	if match := deliveryDaysRegex.FindStringSubmatch(lower); match != nil {
+		if match[3] != "" {
+			days, _ := strconv.Atoi(match[3])
+			return window(today.AddDate(0, 0, days), today.AddDate(0, 0, days))
+		}
+		from, _ := strconv.Atoi(match[1])
+		to, _ := strconv.Atoi(match[2])
+		return window(today.AddDate(0, 0, from), today.AddDate(0, 0, to))
+	}
+
+	// days without a month of their own are in the month named after them, "el 12 y el 20 de febrero"
+	days := []int{}
+	var month time.Month
+	for _, match := range deliveryDateRegex.FindAllStringSubmatch(lower, -1) {
+		if match[2] != "" {
+			named, ok := spanishMonths[match[2]]
+			if !ok {
+				continue
+			}
+			if month == 0 {
+				month = named
+			}
+		}
+		day, _ := strconv.Atoi(match[1])
+		days = append(days, day)
+	}
+	if month != 0 && len(days) > 0 {
+		return window(deliveryDate(today, month, days[0]), deliveryDate(today, month, days[len(days)-1]))
+	}
+
+	switch {
+	case strings.Contains(lower, "pasado mañana"):
+		return window(today.AddDate(0, 0, 2), today.AddDate(0, 0, 2))
+	case strings.Contains(lower, "mañana"):
+		return window(today.AddDate(0, 0, 1), today.AddDate(0, 0, 1))
+	case strings.Contains(lower, "hoy"):
+		return window(today, today)
+	}
+	if match := deliveryDayRegex.FindStringSubmatch(lower); match != nil {
+		ahead := (int(spanishWeekdays[match[1]]) - int(today.Weekday()) + 7) % 7
+		if ahead == 0 {
+			ahead = 7
+		}
+		return window(today.AddDate(0, 0, ahead), today.AddDate(0, 0, ahead))
+	}
+	return nil
+}
+
+// deliveryDate is the next day and month from today, promises made in december may arrive in january
+func deliveryDate(today time.Time, month time.Month, day int) time.Time {
+	date := time.Date(today.Year(), month, day, 0, 0, 0, 0, today.Location())
+	if date.Before(today.AddDate(0, -1, 0)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	return date
+}
+
+// fullProducts keeps the products meli fulfils, going by their search card when they have one
+func fullProducts(products []MeliProduct) []MeliProduct {
+	full := []MeliProduct{}
+	for _, product := range products {
+		if (product.Card != nil && fullCards(*product.Card)) || (product.Card == nil && product.Shipping.Full()) {
+			full = append(full, product)
+		}
+	}
+	return full
+}
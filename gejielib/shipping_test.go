@@ -0,0 +1,127 @@
+package gejie
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseDeliveryWindow(t *testing.T) {
+	// a thursday
+	now := time.Date(2026, time.February, 5, 15, 30, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		input    string
+		earliest time.Time
+		latest   time.Time
+		none     bool
+	}{
+		{name: "Today", input: "Llega gratis hoy", earliest: day(time.February, 5), latest: day(time.February, 5)},
+		{name: "Tomorrow", input: "Llega gratis mañana", earliest: day(time.February, 6), latest: day(time.February, 6)},
+		{name: "Day after tomorrow", input: "Llega pasado mañana", earliest: day(time.February, 7), latest: day(time.February, 7)},
+		{name: "Weekday", input: "Llega el lunes", earliest: day(time.February, 9), latest: day(time.February, 9)},
+		{name: "Same weekday next week", input: "Recíbelo el jueves", earliest: day(time.February, 12), latest: day(time.February, 12)},
+		{name: "Date", input: "Llega el jueves 12 de marzo", earliest: day(time.March, 12), latest: day(time.March, 12)},
+		{name: "Date range", input: "Llega gratis entre el 12 y el 20 de febrero", earliest: day(time.February, 12), latest: day(time.February, 20)},
+		{name: "Days range", input: "Llega en 10 a 15 días hábiles", earliest: day(time.February, 15), latest: day(time.February, 20)},
+		{name: "No day", input: "Envío a todo el país", none: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseDeliveryWindow(tt.input, now)
+			if tt.none {
+				if result != nil {
+					t.Errorf("parseDeliveryWindow(%q) = %+v, expected nil", tt.input, result)
+				}
+				return
+			}
+			if result == nil || !result.Earliest.Equal(tt.earliest) || !result.Latest.Equal(tt.latest) {
+				t.Errorf("parseDeliveryWindow(%q) = %+v, expected %s to %s", tt.input, result, tt.earliest, tt.latest)
+			}
+		})
+	}
+}
+
+func TestParseDeliveryWindowAcrossNewYear(t *testing.T) {
+	now := time.Date(2026, time.December, 28, 0, 0, 0, 0, time.UTC)
+	result := parseDeliveryWindow("Llega entre el 2 y el 6 de enero", now)
+	if result == nil || result.Earliest != time.Date(2027, time.January, 2, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("parseDeliveryWindow = %+v, expected january of next year", result)
+	}
+}
+
+func TestShippingOfProductsAndCards(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	result, err := RunMeliSearchWithFetcher(context.Background(), fetcher, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithFetcher failed: %v", err)
+	}
+	if len(result.Products) != 3 {
+		t.Fatalf("RunMeliSearchWithFetcher scraped %d products, expected 3", len(result.Products))
+	}
+
+	full := result.Products[0].Shipping
+	if !full.Full() || !full.FreeShipping || full.Origin != OriginLocal || full.Promise != "Llega gratis mañana" || full.Delivery == nil {
+		t.Errorf("full product shipping = %+v", full)
+	}
+	if len(full.PickupOptions) != 1 {
+		t.Errorf("full product pickup = %v, expected the agency pickup", full.PickupOptions)
+	}
+	seller := result.Products[1].Shipping
+	if seller.Fulfillment != FulfillmentSeller || seller.FreeShipping || seller.Promise != "Llega el lunes" || seller.Delivery == nil {
+		t.Errorf("seller product shipping = %+v, expected the promise naming a day", seller)
+	}
+	international := result.Products[2].Shipping
+	if international.Origin != OriginInternational || !international.FreeShipping || international.Delivery == nil {
+		t.Errorf("international product shipping = %+v", international)
+	}
+
+	cards, err := ScrapeListingCards(context.Background(), fetcher, fixtureSearchUrl, 3, nil)
+	if err != nil {
+		t.Fatalf("ScrapeListingCards failed: %v", err)
+	}
+	if !cards[0].Shipping.Full() || !cards[0].Shipping.FreeShipping {
+		t.Errorf("first card shipping = %+v, expected full and free", cards[0].Shipping)
+	}
+	if cards[1].Shipping.Fulfillment != "" {
+		t.Errorf("second card shipping = %+v, expected none shown", cards[1].Shipping)
+	}
+	if cards[2].Shipping.Origin != OriginInternational || cards[2].Shipping.Full() {
+		t.Errorf("third card shipping = %+v, expected international", cards[2].Shipping)
+	}
+
+	opts.OnlyFull = true
+	counting := &productCountingFetcher{HTTPFetcher: fetcher}
+	result, err = RunMeliSearchWithFetcher(context.Background(), counting, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithFetcher failed: %v", err)
+	}
+	if len(result.Products) != 1 || result.Products[0].Title != "Teclado Mecánico Redragon Kumara K552 Rgb" {
+		t.Errorf("OnlyFull kept %d products, expected the full one only", len(result.Products))
+	}
+	if len(counting.urls) != 1 || counting.urls[0] != cards[0].Url {
+		t.Errorf("OnlyFull fetched product pages %v, expected the full one only", counting.urls)
+	}
+}
+
+// productCountingFetcher records the product pages fetched
+type productCountingFetcher struct {
+	*HTTPFetcher
+	mu   sync.Mutex
+	urls []string
+}
+
+func (f *productCountingFetcher) Product(ctx context.Context, url string) (*MeliProduct, error) {
+	f.mu.Lock()
+	f.urls = append(f.urls, url)
+	f.mu.Unlock()
+	return f.HTTPFetcher.Product(ctx, url)
+}
@@ -0,0 +1,114 @@
+package gejie
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Condition is the item condition of the "Nuevo | +100 vendidos" subtitle
+type Condition string
+
+const (
+	ConditionNew         Condition = "new"
+	ConditionUsed        Condition = "used"
+	ConditionRefurbished Condition = "refurbished"
+)
+
+// ListingStatus tells whether a listing can still be bought
+type ListingStatus string
+
+const (
+	ListingActive ListingStatus = "active"
+	ListingPaused ListingStatus = "paused"
+	// ListingClosed listings are finished, sold out for good or taken down
+	ListingClosed ListingStatus = "closed"
+)
+
+// AvailableQuantity is the stock a product page shows, a lower bound for "+50 disponibles"
+type AvailableQuantity struct {
+	Min uint32
+	// Exact tells the page shows the count itself, "3 disponibles" or "Último disponible"
+	Exact bool
+}
+
+// String writes the quantity like the page does, e.g. "3" or "+50"
+func (q AvailableQuantity) String() string {
+	if q.Exact {
+		return strconv.FormatUint(uint64(q.Min), 10)
+	}
+	return "+" + strconv.FormatUint(uint64(q.Min), 10)
+}
+
+// conditionWords are the conditions of the subtitle on the meli sites, lowercase
+var conditionWords = map[string]Condition{
+	"nuevo":           ConditionNew,
+	"usado":           ConditionUsed,
+	"reacondicionado": ConditionRefurbished,
+}
+
+// parseCondition reads the condition half of a subtitle like "Nuevo  |  +100 vendidos"
+func parseCondition(s string) Condition {
+	condition, _, _ := strings.Cut(s, "|")
+	return conditionWords[strings.ToLower(strings.TrimSpace(condition))]
+}
+
+// schemaConditions are the schema.org itemCondition values of the json-ld offer
+var schemaConditions = map[string]Condition{
+	"NewCondition":         ConditionNew,
+	"UsedCondition":        ConditionUsed,
+	"RefurbishedCondition": ConditionRefurbished,
+}
+
+// schemaCondition reads an itemCondition like "https://schema.org/NewCondition"
+func schemaCondition(s string) Condition {
+	return schemaConditions[s[strings.LastIndex(s, "/")+1:]]
+}
+
+var availableRegex = regexp.MustCompile(`(\+)?\s*(\d[\d.,]*)\s*disponibles?`)
+
+// parseAvailableQuantity reads stock texts like "+50 disponibles", "(3 disponibles)", "Último
+// disponible" or "Stock disponible", nil when s shows no stock
+func parseAvailableQuantity(s string) *AvailableQuantity {
+	lower := strings.ToLower(s)
+	if match := availableRegex.FindStringSubmatch(lower); match != nil {
+		count, err := strconv.ParseUint(strings.NewReplacer(".", "", ",", "").Replace(match[2]), 10, 32)
+		if err == nil {
+			return &AvailableQuantity{Min: uint32(count), Exact: match[1] == ""}
+		}
+	}
+	switch {
+	case strings.Contains(lower, "último disponible"), strings.Contains(lower, "ultimo disponible"), strings.Contains(lower, "única disponible"):
+		return &AvailableQuantity{Min: 1, Exact: true}
+	case strings.Contains(lower, "stock disponible"):
+		return &AvailableQuantity{Min: 1}
+	}
+	return nil
+}
+
+// parseListingStatus reads the message a product page shows above its buy box, pages without
+// a paused or finished message are active
+func parseListingStatus(s string) ListingStatus {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.Contains(lower, "pausada"):
+		return ListingPaused
+	case strings.Contains(lower, "finaliz"), strings.Contains(lower, "ya no está disponible"), strings.Contains(lower, "inactiva"):
+		return ListingClosed
+	}
+	return ListingActive
+}
+
+// parseWarranty normalizes warranty texts like "Garantía del vendedor: 6 meses", "Sin garantía" is kept as is
+func parseWarranty(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// pausedDocument tells whether err only marks doc as a paused or finished listing, whose product
+// is still read along with its status
+func pausedDocument(doc *goquery.Document, err error) bool {
+	return doc != nil && errors.Is(err, ErrListingPaused)
+}
@@ -0,0 +1,161 @@
+package gejie
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Condition
+	}{
+		{name: "New with sold count", input: "Nuevo  |  +100 vendidos", expected: ConditionNew},
+		{name: "New only", input: "Nuevo", expected: ConditionNew},
+		{name: "Used", input: "Usado  |  5 vendidos", expected: ConditionUsed},
+		{name: "Refurbished", input: "Reacondicionado | +25 vendidos", expected: ConditionRefurbished},
+		{name: "Sold count only", input: "+100 vendidos", expected: ""},
+		{name: "Empty", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseCondition(tt.input)
+			if result != tt.expected {
+				t.Errorf("parseCondition(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSchemaCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Condition
+	}{
+		{name: "Url", input: "https://schema.org/NewCondition", expected: ConditionNew},
+		{name: "Short", input: "UsedCondition", expected: ConditionUsed},
+		{name: "Refurbished", input: "http://schema.org/RefurbishedCondition", expected: ConditionRefurbished},
+		{name: "Damaged", input: "https://schema.org/DamagedCondition", expected: ""},
+		{name: "Empty", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := schemaCondition(tt.input)
+			if result != tt.expected {
+				t.Errorf("schemaCondition(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseAvailableQuantity(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected *AvailableQuantity
+	}{
+		{name: "More than", input: "(+50 disponibles)", expected: &AvailableQuantity{Min: 50}},
+		{name: "Large bucket", input: "+1.000 disponibles", expected: &AvailableQuantity{Min: 1000}},
+		{name: "Exact count", input: "(3 disponibles)", expected: &AvailableQuantity{Min: 3, Exact: true}},
+		{name: "Last one", input: "¡Último disponible!", expected: &AvailableQuantity{Min: 1, Exact: true}},
+		{name: "Last one without accent", input: "Ultimo disponible", expected: &AvailableQuantity{Min: 1, Exact: true}},
+		{name: "In stock", input: "Stock disponible", expected: &AvailableQuantity{Min: 1}},
+		{name: "No stock shown", input: "Cantidad: 1", expected: nil},
+		{name: "Empty", input: "", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseAvailableQuantity(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseAvailableQuantity(%q) = %+v, expected %+v", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseListingStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ListingStatus
+	}{
+		{name: "Paused", input: "Publicación pausada", expected: ListingPaused},
+		{name: "Paused sentence", input: "Esta publicación está pausada. El vendedor no está recibiendo preguntas.", expected: ListingPaused},
+		{name: "Finished", input: "Publicación finalizada", expected: ListingClosed},
+		{name: "Finished sentence", input: "Esta publicación finalizó", expected: ListingClosed},
+		{name: "Unavailable", input: "Este producto ya no está disponible", expected: ListingClosed},
+		{name: "No message", input: "", expected: ListingActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseListingStatus(tt.input)
+			if result != tt.expected {
+				t.Errorf("parseListingStatus(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseWarranty(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "Factory", input: "Garantía de fábrica: 12 meses", expected: "Garantía de fábrica: 12 meses"},
+		{name: "Spaced", input: "\n  Garantía del vendedor:  6 meses \n", expected: "Garantía del vendedor: 6 meses"},
+		{name: "None", input: "Sin garantía", expected: "Sin garantía"},
+		{name: "Empty", input: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseWarranty(tt.input)
+			if result != tt.expected {
+				t.Errorf("parseWarranty(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStockConditionAndStatusOfFixtures(t *testing.T) {
+	fetcher := newReplayHTTPFetcher(t)
+	searchUrl := fixtureSearchUrl
+	opts := DefaultMeliSearchOptions()
+	opts.MaxItems = 3
+	result, err := RunMeliSearchWithFetcher(context.Background(), fetcher, &searchUrl, opts)
+	if err != nil {
+		t.Fatalf("RunMeliSearchWithFetcher failed: %v", err)
+	}
+	if len(result.Products) != 3 {
+		t.Fatalf("RunMeliSearchWithFetcher scraped %d products, expected 3", len(result.Products))
+	}
+
+	expected := []struct {
+		condition Condition
+		available string
+		status    ListingStatus
+		warranty  string
+	}{
+		{ConditionNew, "+50", ListingActive, "Garantía de fábrica: 12 meses"},
+		{ConditionNew, "", ListingPaused, ""},
+		{ConditionNew, "1", ListingActive, "Garantía del vendedor: 6 meses; Garantía de fábrica: 12 meses"},
+	}
+	for i, want := range expected {
+		product := result.Products[i]
+		available := ""
+		if product.AvailableQuantity != nil {
+			available = product.AvailableQuantity.String()
+		}
+		if product.Condition != want.condition || available != want.available || product.Status != want.status || product.Warranty != want.warranty {
+			t.Errorf("products[%d] = %q %q %q %q, expected %+v", i, product.Condition, available, product.Status, product.Warranty, want)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package gejie
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+// FieldSource tells where a product field was read from
+type FieldSource string
+
+const (
+	SourceJSONLD         FieldSource = "json-ld"
+	SourcePreloadedState FieldSource = "preloaded-state"
+	SourceCSS            FieldSource = "css"
+)
+
+// keys of MeliProduct.FieldSources
+const (
+	FieldTitle       = "title"
+	FieldPrice       = "price"
+	FieldRating      = "rating"
+	FieldReviewCount = "review_count"
+	FieldSold        = "sold"
+	FieldCondition   = "condition"
+	FieldImages      = "images"
+	FieldDescription = "description"
+	FieldAttributes  = "attributes"
+	FieldStoreName   = "store_name"
+	FieldStoreUrl    = "store_url"
+)
+
+// structuredProduct holds the product fields one embedded data source had, unset when it had not
+type structuredProduct struct {
+	Title      string
+	PriceCents *int
+	// ListPriceCents is the price before the discount, unset when not discounted
+	ListPriceCents *int
+	Currency       utils.CurrencyCode
+	Rating         *float32
+	ReviewCount    *uint32
+	Sold           *uint32
+	Condition      Condition
+	Images         []string
+	Description    string
+	Attributes     []MeliAttributeSection
+	StoreName      string
+	StoreUrl       string
+}
+
+// structuredSources are read in order, a field is taken from the first source that has it
+var structuredSources = []struct {
+	source FieldSource
+	read   func(doc *goquery.Document) (structuredProduct, bool)
+}{
+	{SourceJSONLD, jsonLDProduct},
+	{SourcePreloadedState, preloadedStateProduct},
+}
+
+// fillStructured sets the fields of product that are still unset from data, recording source
+func fillStructured(product *MeliProduct, data structuredProduct, source FieldSource) {
+	if product.Title == "" && data.Title != "" {
+		product.Title = data.Title
+		product.FieldSources[FieldTitle] = source
+	}
+	if product.FieldSources[FieldPrice] == "" && data.PriceCents != nil {
+		product.Price.AmountCents = *data.PriceCents
+		product.Price.CurrencyCode = data.Currency
+		if data.ListPriceCents != nil {
+			product.Price.ListAmountCents = *data.ListPriceCents
+		}
+		product.FieldSources[FieldPrice] = source
+	}
+	if product.Rating == nil && data.Rating != nil {
+		product.Rating = data.Rating
+		product.FieldSources[FieldRating] = source
+	}
+	if product.ReviewCount == nil && data.ReviewCount != nil {
+		product.ReviewCount = data.ReviewCount
+		product.FieldSources[FieldReviewCount] = source
+	}
+	if product.SoldMoreThan == nil && data.Sold != nil {
+		product.SoldMoreThan = data.Sold
+		product.FieldSources[FieldSold] = source
+	}
+	if product.Condition == "" && data.Condition != "" {
+		product.Condition = data.Condition
+		product.FieldSources[FieldCondition] = source
+	}
+	if len(product.ImageUrls) == 0 && len(data.Images) > 0 {
+		product.ImageUrls = data.Images
+		product.FieldSources[FieldImages] = source
+	}
+	if product.DescriptionContent == "" && data.Description != "" {
+		product.DescriptionContent = data.Description
+		product.FieldSources[FieldDescription] = source
+	}
+	addAttributes(product, data.Attributes, source)
+	if product.StoreInfo.Name == "" && data.StoreName != "" {
+		product.StoreInfo.Name = data.StoreName
+		product.FieldSources[FieldStoreName] = source
+	}
+	if product.StoreInfo.Url == "" && data.StoreUrl != "" {
+		product.StoreInfo.Url = parseUrlBase(data.StoreUrl)
+		product.FieldSources[FieldStoreUrl] = source
+	}
+}
+
+// jsonLDProduct reads the schema.org Product of the page's ld+json scripts
+func jsonLDProduct(doc *goquery.Document) (structuredProduct, bool) {
+	found := jsonLDProductNode(doc)
+	if found == nil {
+		return structuredProduct{}, false
+	}
+
+	data := structuredProduct{Title: strings.TrimSpace(jsonString(found["name"]))}
+	data.Images = jsonImageUrls(found["image"])
+	data.Description = normalizeDescription(jsonString(found["description"]))
+	data.Attributes = jsonLDAttributes(found)
+
+	offer := firstJSONObject(found["offers"])
+	if offer != nil {
+		price, ok := jsonNumber(offer["price"])
+		if !ok {
+			// AggregateOffer
+			price, ok = jsonNumber(offer["lowPrice"])
+		}
+		if ok {
+			cents := int(math.Round(price * 100))
+			data.PriceCents = &cents
+			data.Currency = currencyCode(jsonString(offer["priceCurrency"]))
+		}
+		data.Condition = schemaCondition(jsonString(offer["itemCondition"]))
+		if seller := firstJSONObject(offer["seller"]); seller != nil {
+			data.StoreName = strings.TrimSpace(jsonString(seller["name"]))
+			data.StoreUrl = jsonString(seller["url"])
+		}
+	}
+
+	if rating := firstJSONObject(found["aggregateRating"]); rating != nil {
+		if value, ok := jsonNumber(rating["ratingValue"]); ok {
+			score := float32(value)
+			data.Rating = &score
+		}
+		count, ok := jsonNumber(rating["reviewCount"])
+		if !ok {
+			count, ok = jsonNumber(rating["ratingCount"])
+		}
+		if ok {
+			reviews := uint32(count)
+			data.ReviewCount = &reviews
+		}
+	}
+	return data, true
+}
+
+// jsonLDProductNode returns the first schema.org Product of the page's ld+json scripts
+func jsonLDProductNode(doc *goquery.Document) map[string]any {
+	var found map[string]any
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		var data any
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			log.Printf("skipping unreadable ld+json: %v", err)
+			return true
+		}
+		found = findJSONLDProduct(data)
+		return found == nil
+	})
+	return found
+}
+
+// findJSONLDProduct returns the first Product node of an ld+json document, looking into arrays and @graph
+func findJSONLDProduct(data any) map[string]any {
+	switch node := data.(type) {
+	case []any:
+		for _, item := range node {
+			if product := findJSONLDProduct(item); product != nil {
+				return product
+			}
+		}
+	case map[string]any:
+		if graph, ok := node["@graph"]; ok {
+			return findJSONLDProduct(graph)
+		}
+		types := node["@type"]
+		if jsonString(types) == "Product" {
+			return node
+		}
+		if list, ok := types.([]any); ok {
+			for _, t := range list {
+				if jsonString(t) == "Product" {
+					return node
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// preloadedStateProduct reads the product of the __PRELOADED_STATE__ blob the page hydrates from,
+// using the paths of the pdp components seen on meli product pages
+func preloadedStateProduct(doc *goquery.Document) (structuredProduct, bool) {
+	state := preloadedState(doc)
+	if state == nil {
+		return structuredProduct{}, false
+	}
+	components, ok := findJSONKey(state, "components").(map[string]any)
+	if !ok {
+		return structuredProduct{}, false
+	}
+
+	data := structuredProduct{
+		Title:       strings.TrimSpace(jsonString(jsonPath(components, "header", "title"))),
+		StoreName:   strings.TrimSpace(firstJSONString(components, [][]string{{"seller_data", "header", "title", "text"}, {"seller_data", "header", "title"}, {"seller", "name"}})),
+		StoreUrl:    firstJSONString(components, [][]string{{"seller_data", "header", "link"}, {"seller_data", "footer", "action", "target"}, {"seller", "permalink"}}),
+		Description: normalizeDescription(firstJSONString(components, [][]string{{"description", "content"}, {"description", "text"}})),
+	}
+	data.Attributes = preloadedStateAttributes(components)
+	if price, ok := jsonNumber(jsonPath(components, "price", "price", "value")); ok {
+		cents := int(math.Round(price * 100))
+		data.PriceCents = &cents
+		data.Currency = currencyCode(jsonString(jsonPath(components, "price", "price", "currency_id")))
+		if original, ok := jsonNumber(jsonPath(components, "price", "price", "original_value")); ok {
+			listCents := int(math.Round(original * 100))
+			data.ListPriceCents = &listCents
+		}
+	}
+	if rating, ok := jsonNumber(jsonPath(components, "header", "reviews", "rating")); ok {
+		score := float32(rating)
+		data.Rating = &score
+	}
+	if amount, ok := jsonNumber(jsonPath(components, "header", "reviews", "amount")); ok {
+		reviews := uint32(amount)
+		data.ReviewCount = &reviews
+	}
+	if subtitle := jsonString(jsonPath(components, "header", "subtitle")); subtitle != "" {
+		sold := parseSoldCount(subtitle)
+		data.Sold = &sold
+		data.Condition = parseCondition(subtitle)
+	}
+
+	// pictures carry their url, or an id to put in the gallery's url template
+	template := jsonString(jsonPath(components, "gallery", "picture_config", "template"))
+	pictures, _ := jsonPath(components, "gallery", "pictures").([]any)
+	for _, picture := range pictures {
+		pictureMap, ok := picture.(map[string]any)
+		if !ok {
+			continue
+		}
+		if pictureUrl := jsonString(pictureMap["url"]); pictureUrl != "" {
+			data.Images = append(data.Images, pictureUrl)
+		} else if id := jsonString(pictureMap["id"]); id != "" && template != "" {
+			pictureUrl := strings.ReplaceAll(template, "{id}", id)
+			data.Images = append(data.Images, strings.ReplaceAll(pictureUrl, "{sanitizedTitle}", ""))
+		}
+	}
+	return data, true
+}
+
+// preloadedState decodes the json of the __PRELOADED_STATE__ script, either a json script with
+// that id or a script assigning window.__PRELOADED_STATE__
+func preloadedState(doc *goquery.Document) any {
+	var state any
+	doc.Find("script").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		text := s.Text()
+		start := 0
+		if id, _ := s.Attr("id"); id != "__PRELOADED_STATE__" {
+			marker := strings.Index(text, "__PRELOADED_STATE__")
+			if marker < 0 {
+				return true
+			}
+			start = strings.Index(text[marker:], "{")
+			if start < 0 {
+				return true
+			}
+			start += marker
+		}
+		// the decoder stops after the object, ignoring the statements that follow it
+		if err := json.NewDecoder(strings.NewReader(text[start:])).Decode(&state); err != nil {
+			log.Printf("skipping unreadable preloaded state: %v", err)
+			state = nil
+			return true
+		}
+		return false
+	})
+	return state
+}
+
+// findJSONKey returns the value of the first key named key, searching depth first
+func findJSONKey(data any, key string) any {
+	switch node := data.(type) {
+	case map[string]any:
+		if value, ok := node[key]; ok {
+			return value
+		}
+		for _, value := range node {
+			if found := findJSONKey(value, key); found != nil {
+				return found
+			}
+		}
+	case []any:
+		for _, value := range node {
+			if found := findJSONKey(value, key); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+func jsonPath(data any, path ...string) any {
+	for _, key := range path {
+		node, ok := data.(map[string]any)
+		if !ok {
+			return nil
+		}
+		data = node[key]
+	}
+	return data
+}
+
+func firstJSONString(data any, paths [][]string) string {
+	for _, path := range paths {
+		if s := jsonString(jsonPath(data, path...)); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func firstJSONObject(data any) map[string]any {
+	switch node := data.(type) {
+	case map[string]any:
+		return node
+	case []any:
+		for _, item := range node {
+			if object, ok := item.(map[string]any); ok {
+				return object
+			}
+		}
+	}
+	return nil
+}
+
+func jsonString(data any) string {
+	s, _ := data.(string)
+	return s
+}
+
+// jsonNumber reads numbers written as json numbers or strings, e.g. "159.90"
+func jsonNumber(data any) (float64, bool) {
+	switch value := data.(type) {
+	case float64:
+		return value, true
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// jsonImageUrls reads a schema.org image, a url, a list of urls or ImageObjects
+func jsonImageUrls(data any) []string {
+	urls := []string{}
+	switch value := data.(type) {
+	case string:
+		if value != "" {
+			urls = append(urls, value)
+		}
+	case map[string]any:
+		urls = append(urls, jsonImageUrls(value["url"])...)
+	case []any:
+		for _, item := range value {
+			urls = append(urls, jsonImageUrls(item)...)
+		}
+	}
+	return urls
+}
+
+// currencyCode maps an iso currency code to the one utils uses, e.g. "MXN"
+func currencyCode(code string) utils.CurrencyCode {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	for _, known := range []utils.CurrencyCode{
+		utils.CurrencyCodePeruvianSoles,
+		utils.CurrencyCodeMexicanPeso,
+		utils.CurrencyCodeColombianPeso,
+		utils.CurrencyCodeUnitedStatesDollar,
+		utils.CurrencyCodeChineseYuan,
+	} {
+		if strings.TrimSpace(string(known)) == code {
+			return known
+		}
+	}
+	return utils.CurrencyCode(code)
+}
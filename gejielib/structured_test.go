@@ -0,0 +1,132 @@
+package gejie
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zshanhui/gejiezhipin/utils"
+)
+
+const structuredPageUrl = "https://articulo.mercadolibre.com.mx/MLM-123-teclado-_JM"
+
+func structuredDoc(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestProductFromHTMLPrefersStructuredData(t *testing.T) {
+	html := `<html><head>
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+  {"@type":"BreadcrumbList"},
+  {"@type":"Product","name":"Teclado Aula F75",
+   "image":["https://http2.mlstatic.com/D_1.jpg",{"@type":"ImageObject","url":"https://http2.mlstatic.com/D_2.jpg"}],
+   "description":"Teclado 75%.\n\nConexión Bluetooth.",
+   "offers":{"@type":"Offer","price":1229.5,"priceCurrency":"MXN","seller":{"name":"Aula Store"}},
+   "aggregateRating":{"ratingValue":"4.8","reviewCount":37}}]}</script>
+</head><body>
+<h1 class="ui-pdp-title">Teclado del html</h1>
+<div class="ui-pdp-header__subtitle"><span class="ui-pdp-subtitle">Nuevo | +500 vendidos</span></div>
+<script>window.__PRELOADED_STATE__ = {"initialState":{"components":{
+  "header":{"title":"Teclado del estado","subtitle":"Nuevo  |  +1000 vendidos"},
+  "seller_data":{"header":{"title":{"text":"Otra tienda"},"link":"https://www.mercadolibre.com.mx/tienda/aula?x=1"}}}}};
+  window.other = 1;</script>
+</body></html>`
+
+	product, missing, err := productFromHTML(structuredDoc(t, html), structuredPageUrl, structuredPageUrl)
+	if err != nil {
+		t.Fatalf("productFromHTML failed: %v", err)
+	}
+	if len(missing) > 0 {
+		t.Errorf("productFromHTML missed %v", missing)
+	}
+	if product.Title != "Teclado Aula F75" {
+		t.Errorf("Title = %q, expected the json-ld name", product.Title)
+	}
+	if product.Price.AmountCents != 122950 || product.Price.CurrencyCode != utils.CurrencyCodeMexicanPeso {
+		t.Errorf("Price = %+v, expected 122950 %q", product.Price, utils.CurrencyCodeMexicanPeso)
+	}
+	if product.Rating == nil || *product.Rating != 4.8 {
+		t.Errorf("Rating = %v, expected 4.8", product.Rating)
+	}
+	if product.ReviewCount == nil || *product.ReviewCount != 37 {
+		t.Errorf("ReviewCount = %v, expected 37", product.ReviewCount)
+	}
+	if product.SoldMoreThan == nil || *product.SoldMoreThan != 1000 {
+		t.Errorf("SoldMoreThan = %v, expected 1000 from the preloaded state", product.SoldMoreThan)
+	}
+	if want := []string{"https://http2.mlstatic.com/D_1.jpg", "https://http2.mlstatic.com/D_2.jpg"}; !reflect.DeepEqual(product.ImageUrls, want) {
+		t.Errorf("ImageUrls = %v, expected %v", product.ImageUrls, want)
+	}
+	if product.StoreInfo.Name != "Aula Store" || product.StoreInfo.Url != "https://www.mercadolibre.com.mx/tienda/aula" {
+		t.Errorf("StoreInfo = %+v, expected the json-ld seller with the preloaded store url", product.StoreInfo)
+	}
+
+	if product.Condition != ConditionNew {
+		t.Errorf("Condition = %q, expected new from the preloaded subtitle", product.Condition)
+	}
+
+	if product.DescriptionContent != "Teclado 75%.\n\nConexión Bluetooth." {
+		t.Errorf("DescriptionContent = %q, expected the json-ld description", product.DescriptionContent)
+	}
+
+	expected := map[string]FieldSource{
+		FieldTitle:       SourceJSONLD,
+		FieldPrice:       SourceJSONLD,
+		FieldRating:      SourceJSONLD,
+		FieldReviewCount: SourceJSONLD,
+		FieldImages:      SourceJSONLD,
+		FieldStoreName:   SourceJSONLD,
+		FieldDescription: SourceJSONLD,
+		FieldSold:        SourcePreloadedState,
+		FieldCondition:   SourcePreloadedState,
+		FieldStoreUrl:    SourcePreloadedState,
+	}
+	if !reflect.DeepEqual(product.FieldSources, expected) {
+		t.Errorf("FieldSources = %v, expected %v", product.FieldSources, expected)
+	}
+}
+
+func TestProductFromHTMLFallsBackToSelectors(t *testing.T) {
+	html := `<html><body>
+<script id="__PRELOADED_STATE__" type="application/json">{"pageState":{"initialState":{"components":{
+  "price":{"price":{"value":159.9,"currency_id":"PEN"}},
+  "gallery":{"picture_config":{"template":"https://http2.mlstatic.com/D_NQ_NP_{id}-O.webp"},"pictures":[{"id":"900"},{"id":"901"}]}}}}}</script>
+<h1 class="ui-pdp-title"> Teclado Redragon </h1>
+<span class="andes-money-amount__fraction">999</span>
+</body></html>`
+
+	product, _, err := productFromHTML(structuredDoc(t, html), structuredPageUrl, structuredPageUrl)
+	if err != nil {
+		t.Fatalf("productFromHTML failed: %v", err)
+	}
+	if product.Title != "Teclado Redragon" || product.FieldSources[FieldTitle] != SourceCSS {
+		t.Errorf("Title = %q from %q, expected it from the selectors", product.Title, product.FieldSources[FieldTitle])
+	}
+	if product.Price.AmountCents != 15990 || product.Price.CurrencyCode != utils.CurrencyCodePeruvianSoles {
+		t.Errorf("Price = %+v, expected 15990 PEN from the preloaded state", product.Price)
+	}
+	if want := []string{"https://http2.mlstatic.com/D_NQ_NP_900-O.webp", "https://http2.mlstatic.com/D_NQ_NP_901-O.webp"}; !reflect.DeepEqual(product.ImageUrls, want) {
+		t.Errorf("ImageUrls = %v, expected %v", product.ImageUrls, want)
+	}
+	if _, ok := product.FieldSources[FieldRating]; ok {
+		t.Errorf("FieldSources has a rating source, the page has no rating")
+	}
+}
+
+func TestProductFromHTMLWithoutTitle(t *testing.T) {
+	html := `<html><body><script type="application/ld+json">{"@type":"Organization","name":"Mercado Libre"}</script></body></html>`
+
+	_, missing, err := productFromHTML(structuredDoc(t, html), structuredPageUrl, structuredPageUrl)
+	if FailureKind(err) != "layout-changed" {
+		t.Errorf("productFromHTML of a page without a product returned %v, expected a layout change", err)
+	}
+	if !reflect.DeepEqual(missing, []string{FieldTitle, FieldPrice, FieldImages}) {
+		t.Errorf("missing = %v", missing)
+	}
+}
@@ -1,7 +1,10 @@
 package gejie
 
 import (
+	"container/heap"
+	"context"
 	"fmt"
+	"log"
 	"sync"
 )
 
@@ -13,26 +16,168 @@ const (
 	StatusFailed
 )
 
+func (s URLStatus) String() string {
+	switch s {
+	case StatusPending:
+		return "pending"
+	case StatusVisited:
+		return "visited"
+	case StatusFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("URLStatus(%d)", int(s))
+	}
+}
+
+// URLRecord is what the frontier knows about a single url
+type URLRecord struct {
+	Url       string
+	Status    URLStatus
+	Attempts  int
+	LastError string
+	// FailureKind is the FailureKind of the last error, e.g. "timeout" or "not-found"
+	FailureKind string
+	// Depth is the number of links followed from the seed url, seeds have depth 0
+	Depth int
+	// Priority orders the queue, higher priorities are visited first
+	Priority int
+}
+
+// FrontierEntry is a url to enqueue along with where it sits in the crawl
+type FrontierEntry struct {
+	Url      string
+	Depth    int
+	Priority int
+}
+
+// FrontierOptions configures the order and reach of a crawl
+type FrontierOptions struct {
+	// MaxDepth drops urls more than MaxDepth links away from their seed, 0 means no limit
+	MaxDepth int
+	// Normalizer decides which urls are the same page, nil compares raw strings
+	Normalizer URLNormalizer
+	// Robots keeps urls disallowed by robots.txt out of the frontier, nil adds every url
+	Robots *RobotsChecker
+}
+
+func DefaultFrontierOptions() *FrontierOptions {
+	return &FrontierOptions{
+		MaxDepth:   0,
+		Normalizer: DefaultURLNormalizer(),
+	}
+}
+
+// allows tells whether entry is within the configured depth
+func (o *FrontierOptions) allows(entry FrontierEntry) bool {
+	return o.MaxDepth <= 0 || entry.Depth <= o.MaxDepth
+}
+
+// robotsAllowed drops the entries robots.txt disallows, done before taking any lock since
+// it may fetch robots.txt, ctx cancels the fetch
+func (o *FrontierOptions) robotsAllowed(ctx context.Context, entries []FrontierEntry) []FrontierEntry {
+	if o.Robots == nil {
+		return entries
+	}
+	allowed := make([]FrontierEntry, 0, len(entries))
+	for _, entry := range entries {
+		if o.Robots.Allowed(ctx, entry.Url) {
+			allowed = append(allowed, entry)
+		} else {
+			log.Printf("skipping %s: disallowed by robots.txt", entry.Url)
+		}
+	}
+	return allowed
+}
+
+// ChildEntries builds the entries of links found on parent, one level deeper than it,
+// relative links are resolved against parent
+func ChildEntries(f URLFrontierInterface, parent string, urls []string, priority int) []FrontierEntry {
+	depth := 0
+	if record, ok := f.Get(parent); ok {
+		depth = record.Depth + 1
+	}
+	entries := make([]FrontierEntry, 0, len(urls))
+	for _, url := range urls {
+		if resolved, err := ResolveURL(parent, url); err == nil {
+			url = resolved
+		}
+		entries = append(entries, FrontierEntry{Url: url, Depth: depth, Priority: priority})
+	}
+	return entries
+}
+
 // URLFrontierInterface defines the behavior of a URL frontier
 type URLFrontierInterface interface {
-	BulkAdd(urls []string)
-	Add(url string)
+	BulkAdd(ctx context.Context, urls []string)
+	Add(ctx context.Context, url string)
+	// AddEntries enqueues urls unknown to the frontier, returning how many were added.
+	// urls are stored in their canonical form and all methods accept any form of a known url
+	AddEntries(ctx context.Context, entries []FrontierEntry) int
 	MarkVisited(url string)
-	MarkFailed(url string)
+	MarkFailed(url string, err error)
+	// Requeue sets a failed url back to pending at the end of its priority, e.g. to retry it
+	Requeue(url string)
+	// GetNext returns the pending url with the highest priority, oldest first among equals
 	GetNext() (string, bool)
+	Get(url string) (URLRecord, bool)
 	Count() int
 	CountRemaining() int
 }
 
+// memoryRecord is a URLRecord with the seq of its live queue item, older items of a requeued
+// url are left in the heap and skipped
+type memoryRecord struct {
+	URLRecord
+	queueSeq uint64
+}
+
+// frontierItem is a queued url, seq breaks priority ties in the order urls were queued
+type frontierItem struct {
+	record *memoryRecord
+	seq    uint64
+}
+
+// frontierQueue is a heap of queued urls, highest priority then lowest seq on top
+type frontierQueue []frontierItem
+
+func (q frontierQueue) Len() int { return len(q) }
+func (q frontierQueue) Less(i, j int) bool {
+	if q[i].record.Priority != q[j].record.Priority {
+		return q[i].record.Priority > q[j].record.Priority
+	}
+	return q[i].seq < q[j].seq
+}
+func (q frontierQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *frontierQueue) Push(x any)   { *q = append(*q, x.(frontierItem)) }
+func (q *frontierQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
 // URLFrontier implements URLFrontierInterface
 type URLFrontier struct {
-	urls map[string]URLStatus
-	mu   sync.Mutex
+	// urls is keyed by the normalizer key
+	urls    map[string]*memoryRecord
+	queue   frontierQueue
+	seq     uint64
+	pending int
+	opts    *FrontierOptions
+	mu      sync.Mutex
 }
 
 func NewURLFrontier() URLFrontierInterface {
+	return NewURLFrontierWithOptions(DefaultFrontierOptions())
+}
+
+func NewURLFrontierWithOptions(opts *FrontierOptions) *URLFrontier {
+	if opts == nil {
+		opts = DefaultFrontierOptions()
+	}
 	return &URLFrontier{
-		urls: make(map[string]URLStatus),
+		urls: make(map[string]*memoryRecord),
+		opts: opts,
 	}
 }
 
@@ -42,56 +187,131 @@ func (f *URLFrontier) Count() int {
 	return len(f.urls)
 }
 
-// BulkAdd adds multiple URLs to the frontier
-func (f *URLFrontier) BulkAdd(urls []string) {
+// BulkAdd adds multiple URLs to the frontier as seeds
+func (f *URLFrontier) BulkAdd(ctx context.Context, urls []string) {
+	entries := make([]FrontierEntry, 0, len(urls))
 	for _, url := range urls {
-		f.Add(url)
+		entries = append(entries, FrontierEntry{Url: url})
 	}
+	f.AddEntries(ctx, entries)
+}
+
+func (f *URLFrontier) Add(ctx context.Context, url string) {
+	f.AddEntries(ctx, []FrontierEntry{{Url: url}})
 }
 
-// remaining methods stay the same, just ensure URLFrontier implements URLFrontierInterface
-func (f *URLFrontier) Add(url string) {
+func (f *URLFrontier) AddEntries(ctx context.Context, entries []FrontierEntry) int {
+	entries = f.opts.robotsAllowed(ctx, entries)
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	if _, exists := f.urls[url]; !exists {
-		f.urls[url] = StatusPending
-	} else {
-		fmt.Printf("url already exists (%s)", url)
+	added := 0
+	for _, entry := range entries {
+		canonical, key := normalizeURL(f.opts.Normalizer, entry.Url)
+		if _, exists := f.urls[key]; exists || !f.opts.allows(entry) {
+			continue
+		}
+		record := &memoryRecord{URLRecord: URLRecord{Url: canonical, Status: StatusPending, Depth: entry.Depth, Priority: entry.Priority}}
+		f.urls[key] = record
+		f.push(record)
+		f.pending++
+		added++
 	}
+	return added
 }
 
 func (f *URLFrontier) MarkVisited(url string) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	f.urls[url] = StatusVisited
+	record := f.record(url)
+	f.setStatus(record, StatusVisited)
+	record.Attempts++
 }
 
-func (f *URLFrontier) MarkFailed(url string) {
+func (f *URLFrontier) MarkFailed(url string, err error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	f.urls[url] = StatusFailed
+	record := f.record(url)
+	f.setStatus(record, StatusFailed)
+	record.Attempts++
+	if err != nil {
+		record.LastError = err.Error()
+		record.FailureKind = FailureKind(err)
+	}
+}
+
+func (f *URLFrontier) Requeue(url string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	record := f.record(url)
+	if record.Status != StatusPending {
+		f.enqueue(record)
+	}
+}
+
+// push queues record behind every queued url of its priority, callers must hold the lock
+func (f *URLFrontier) push(record *memoryRecord) {
+	f.seq++
+	record.queueSeq = f.seq
+	heap.Push(&f.queue, frontierItem{record: record, seq: f.seq})
+}
+
+// enqueue queues a record that is not pending and sets it pending, callers must hold the lock
+func (f *URLFrontier) enqueue(record *memoryRecord) {
+	f.push(record)
+	f.setStatus(record, StatusPending)
+}
+
+// setStatus keeps the pending count in step, callers must hold the lock
+func (f *URLFrontier) setStatus(record *memoryRecord, status URLStatus) {
+	if record.Status == StatusPending {
+		f.pending--
+	}
+	if status == StatusPending {
+		f.pending++
+	}
+	record.Status = status
+}
+
+// record returns the record of url, adding it when missing, callers must hold the lock
+func (f *URLFrontier) record(url string) *memoryRecord {
+	canonical, key := normalizeURL(f.opts.Normalizer, url)
+	record, exists := f.urls[key]
+	if !exists {
+		// not queued, the caller sets its status
+		record = &memoryRecord{URLRecord: URLRecord{Url: canonical, Status: StatusFailed}}
+		f.urls[key] = record
+	}
+	return record
 }
 
 func (f *URLFrontier) GetNext() (string, bool) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	for url, status := range f.urls {
-		if status == StatusPending {
-			return url, true
+	// urls stay queued until they are visited or failed, drop those and stale items of requeued urls from the top lazily
+	for f.queue.Len() > 0 {
+		top := f.queue[0]
+		if top.record.Status == StatusPending && top.seq == top.record.queueSeq {
+			return top.record.Url, true
 		}
+		heap.Pop(&f.queue)
 	}
 	return "", false
 }
 
+func (f *URLFrontier) Get(url string) (URLRecord, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, key := normalizeURL(f.opts.Normalizer, url)
+	record, exists := f.urls[key]
+	if !exists {
+		return URLRecord{}, false
+	}
+	return record.URLRecord, true
+}
+
 // CountRemaining returns the number of pending URLs that have not been visited
 func (f *URLFrontier) CountRemaining() int {
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	count := 0
-	for _, status := range f.urls {
-		if status == StatusPending {
-			count++
-		}
-	}
-	return count
+	return f.pending
 }
@@ -0,0 +1,321 @@
+package gejie
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"sort"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	frontierUrlsBucket  = []byte("urls")
+	frontierQueueBucket = []byte("queue")
+)
+
+// boltURLRecord is the stored form of a URLRecord, Seq keeps the order urls were added in,
+// QueueSeq the order they were last queued in and Key is the normalizer key the record is stored under
+type boltURLRecord struct {
+	URLRecord
+	Seq      uint64
+	QueueSeq uint64
+	Key      string
+}
+
+// BoltURLFrontier implements URLFrontierInterface on top of a bolt file so a crawl survives
+// crashes and restarts. the queue bucket only holds pending urls, keyed so that a cursor
+// walks them highest priority first and in the order they were added among equals
+type BoltURLFrontier struct {
+	db   *bolt.DB
+	opts *FrontierOptions
+}
+
+func NewBoltURLFrontier(db *bolt.DB, opts *FrontierOptions) (*BoltURLFrontier, error) {
+	if opts == nil {
+		opts = DefaultFrontierOptions()
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(frontierUrlsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(frontierQueueBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltURLFrontier{db: db, opts: opts}, nil
+}
+
+// SetOptions changes how urls added from now on are filtered and normalised, e.g. for a
+// job reopened by a run with other settings. the normalizer should stay the same for a job
+func (f *BoltURLFrontier) SetOptions(opts *FrontierOptions) {
+	if opts == nil {
+		opts = DefaultFrontierOptions()
+	}
+	f.opts = opts
+}
+
+// queueKey sorts by descending priority then ascending seq, the sign bit is flipped so
+// negative priorities sort below positive ones before the whole priority is inverted
+func queueKey(record *boltURLRecord) []byte {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint32(key, ^(uint32(int32(record.Priority)) ^ 1<<31))
+	binary.BigEndian.PutUint64(key[4:], record.QueueSeq)
+	return key
+}
+
+// getBoltRecord reads the record stored under the normalizer key of a url
+func getBoltRecord(tx *bolt.Tx, key string) (*boltURLRecord, error) {
+	data := tx.Bucket(frontierUrlsBucket).Get([]byte(key))
+	if data == nil {
+		return nil, nil
+	}
+	record := &boltURLRecord{}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func putBoltRecord(tx *bolt.Tx, record *boltURLRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(frontierUrlsBucket).Put([]byte(record.Key), data)
+}
+
+// setStatusInTx saves record with status, adding it to or removing it from the queue
+func setStatusInTx(tx *bolt.Tx, record *boltURLRecord, status URLStatus) error {
+	queue := tx.Bucket(frontierQueueBucket)
+	if status == StatusPending && record.Status != StatusPending {
+		if err := queue.Put(queueKey(record), []byte(record.Url)); err != nil {
+			return err
+		}
+	} else if status != StatusPending && record.Status == StatusPending {
+		if err := queue.Delete(queueKey(record)); err != nil {
+			return err
+		}
+	}
+	record.Status = status
+	return putBoltRecord(tx, record)
+}
+
+// addInTx enqueues entry unless the frontier already knows its url, returning the stored
+// record and whether it was added
+func (f *BoltURLFrontier) addInTx(tx *bolt.Tx, entry FrontierEntry) (*boltURLRecord, bool, error) {
+	canonical, key := normalizeURL(f.opts.Normalizer, entry.Url)
+	existing, err := getBoltRecord(tx, key)
+	if err != nil || existing != nil {
+		return existing, false, err
+	}
+	seq, err := tx.Bucket(frontierQueueBucket).NextSequence()
+	if err != nil {
+		return nil, false, err
+	}
+	record := &boltURLRecord{
+		URLRecord: URLRecord{Url: canonical, Status: StatusPending, Depth: entry.Depth, Priority: entry.Priority},
+		Seq:       seq,
+		QueueSeq:  seq,
+		Key:       key,
+	}
+	if err := tx.Bucket(frontierQueueBucket).Put(queueKey(record), []byte(record.Url)); err != nil {
+		return nil, false, err
+	}
+	return record, true, putBoltRecord(tx, record)
+}
+
+// BulkAdd adds multiple URLs to the frontier as seeds in a single transaction
+func (f *BoltURLFrontier) BulkAdd(ctx context.Context, urls []string) {
+	entries := make([]FrontierEntry, 0, len(urls))
+	for _, url := range urls {
+		entries = append(entries, FrontierEntry{Url: url})
+	}
+	f.AddEntries(ctx, entries)
+}
+
+func (f *BoltURLFrontier) Add(ctx context.Context, url string) {
+	f.BulkAdd(ctx, []string{url})
+}
+
+func (f *BoltURLFrontier) AddEntries(ctx context.Context, entries []FrontierEntry) int {
+	entries = f.opts.robotsAllowed(ctx, entries)
+	added := 0
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		added = 0
+		for _, entry := range entries {
+			if !f.opts.allows(entry) {
+				continue
+			}
+			_, isNew, err := f.addInTx(tx, entry)
+			if err != nil {
+				return err
+			}
+			if isNew {
+				added++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("could not add urls to frontier: %v", err)
+		return 0
+	}
+	return added
+}
+
+// update applies change to the record of url and saves it with status, adding the url first when it is unknown
+func (f *BoltURLFrontier) update(url string, status URLStatus, change func(record *boltURLRecord)) {
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		record, _, err := f.addInTx(tx, FrontierEntry{Url: url})
+		if err != nil {
+			return err
+		}
+		change(record)
+		return setStatusInTx(tx, record, status)
+	})
+	if err != nil {
+		log.Printf("could not update frontier record of %s: %v", url, err)
+	}
+}
+
+func (f *BoltURLFrontier) MarkVisited(url string) {
+	f.update(url, StatusVisited, func(record *boltURLRecord) {
+		record.Attempts++
+	})
+}
+
+func (f *BoltURLFrontier) MarkFailed(url string, err error) {
+	f.update(url, StatusFailed, func(record *boltURLRecord) {
+		record.Attempts++
+		if err != nil {
+			record.LastError = err.Error()
+			record.FailureKind = FailureKind(err)
+		}
+	})
+}
+
+func (f *BoltURLFrontier) Requeue(url string) {
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		record, _, err := f.addInTx(tx, FrontierEntry{Url: url})
+		if err != nil || record.Status == StatusPending {
+			return err
+		}
+		seq, err := tx.Bucket(frontierQueueBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		record.QueueSeq = seq
+		return setStatusInTx(tx, record, StatusPending)
+	})
+	if err != nil {
+		log.Printf("could not requeue %s: %v", url, err)
+	}
+}
+
+// RequeueFailed sets failed urls worth another try back to pending, returning how many were requeued
+func (f *BoltURLFrontier) RequeueFailed() int {
+	requeued := 0
+	err := f.db.Update(func(tx *bolt.Tx) error {
+		failed := []*boltURLRecord{}
+		err := tx.Bucket(frontierUrlsBucket).ForEach(func(_, data []byte) error {
+			record := &boltURLRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			if record.Status == StatusFailed && shouldResume(record.URLRecord) {
+				failed = append(failed, record)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// records are rewritten after the iteration since bolt forbids writes inside ForEach
+		for _, record := range failed {
+			if err := setStatusInTx(tx, record, StatusPending); err != nil {
+				return err
+			}
+		}
+		requeued = len(failed)
+		return nil
+	})
+	if err != nil {
+		log.Printf("could not requeue failed urls: %v", err)
+	}
+	return requeued
+}
+
+func (f *BoltURLFrontier) GetNext() (string, bool) {
+	next := ""
+	f.db.View(func(tx *bolt.Tx) error {
+		if _, url := tx.Bucket(frontierQueueBucket).Cursor().First(); url != nil {
+			next = string(url)
+		}
+		return nil
+	})
+	return next, next != ""
+}
+
+func (f *BoltURLFrontier) Get(url string) (URLRecord, bool) {
+	var record *boltURLRecord
+	err := f.db.View(func(tx *bolt.Tx) error {
+		var err error
+		_, key := normalizeURL(f.opts.Normalizer, url)
+		record, err = getBoltRecord(tx, key)
+		return err
+	})
+	if err != nil {
+		log.Printf("could not read frontier record of %s: %v", url, err)
+	}
+	if record == nil {
+		return URLRecord{}, false
+	}
+	return record.URLRecord, true
+}
+
+// URLs returns every url of the frontier in the order it was added
+func (f *BoltURLFrontier) URLs() []string {
+	records := []*boltURLRecord{}
+	err := f.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierUrlsBucket).ForEach(func(_, data []byte) error {
+			record := &boltURLRecord{}
+			if err := json.Unmarshal(data, record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	if err != nil {
+		log.Printf("could not list frontier urls: %v", err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	urls := make([]string, 0, len(records))
+	for _, record := range records {
+		urls = append(urls, record.Url)
+	}
+	return urls
+}
+
+func (f *BoltURLFrontier) Count() int {
+	count := 0
+	f.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(frontierUrlsBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
+
+// CountRemaining returns the number of pending URLs that have not been visited
+func (f *BoltURLFrontier) CountRemaining() int {
+	count := 0
+	f.db.View(func(tx *bolt.Tx) error {
+		count = tx.Bucket(frontierQueueBucket).Stats().KeyN
+		return nil
+	})
+	return count
+}
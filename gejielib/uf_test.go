@@ -0,0 +1,180 @@
+package gejie
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// frontierImplementations builds every frontier implementation with opts
+func frontierImplementations(t *testing.T, opts *FrontierOptions) map[string]URLFrontierInterface {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "frontier.db"), 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	boltFrontier, err := NewBoltURLFrontier(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]URLFrontierInterface{
+		"memory": NewURLFrontierWithOptions(opts),
+		"bolt":   boltFrontier,
+	}
+}
+
+// drain visits the frontier until it is empty, returning the urls in visiting order
+func drain(f URLFrontierInterface) []string {
+	visited := []string{}
+	for url, ok := f.GetNext(); ok; url, ok = f.GetNext() {
+		visited = append(visited, url)
+		f.MarkVisited(url)
+	}
+	return visited
+}
+
+func TestFrontierFIFO(t *testing.T) {
+	for name, f := range frontierImplementations(t, nil) {
+		urls := []string{}
+		for i := 0; i < 50; i++ {
+			urls = append(urls, fmt.Sprintf("/job_detail/%d.html", i))
+		}
+		f.BulkAdd(context.Background(), urls)
+		f.Add(context.Background(), urls[3])
+		if remaining := f.CountRemaining(); remaining != len(urls) {
+			t.Errorf("%s: CountRemaining() = %d, expected %d", name, remaining, len(urls))
+		}
+		if record, _ := f.Get(urls[0]); record.Status != StatusPending || record.Attempts != 0 {
+			t.Errorf("%s: record of a new url = %+v, expected pending", name, record)
+		}
+		visited := drain(f)
+		if fmt.Sprint(visited) != fmt.Sprint(urls) {
+			t.Errorf("%s: visited %v, expected insertion order %v", name, visited, urls)
+		}
+		if remaining := f.CountRemaining(); remaining != 0 {
+			t.Errorf("%s: CountRemaining() = %d after draining, expected 0", name, remaining)
+		}
+	}
+}
+
+func TestFrontierPriority(t *testing.T) {
+	for name, f := range frontierImplementations(t, nil) {
+		f.AddEntries(context.Background(), []FrontierEntry{
+			{Url: "detail-1", Priority: 0},
+			{Url: "listing-1", Priority: 10},
+			{Url: "low-1", Priority: -5},
+			{Url: "detail-2", Priority: 0},
+			{Url: "listing-2", Priority: 10},
+		})
+		expected := []string{"listing-1", "listing-2", "detail-1", "detail-2", "low-1"}
+		if visited := drain(f); fmt.Sprint(visited) != fmt.Sprint(expected) {
+			t.Errorf("%s: visited %v, expected %v", name, visited, expected)
+		}
+	}
+}
+
+func TestFrontierFailedUrlsLeaveQueue(t *testing.T) {
+	for name, f := range frontierImplementations(t, nil) {
+		f.BulkAdd(context.Background(), []string{"a", "b"})
+		f.MarkFailed("a", newScrapeError(ErrTimeout, "a", "goto", nil))
+		if next, _ := f.GetNext(); next != "b" {
+			t.Errorf("%s: GetNext() = %q after failing a, expected b", name, next)
+		}
+		record, _ := f.Get("a")
+		if record.Status != StatusFailed || record.Attempts != 1 || record.FailureKind != "timeout" {
+			t.Errorf("%s: record of a = %+v", name, record)
+		}
+		if remaining := f.CountRemaining(); remaining != 1 {
+			t.Errorf("%s: CountRemaining() = %d, expected 1", name, remaining)
+		}
+	}
+}
+
+func TestFrontierMaxDepth(t *testing.T) {
+	for name, f := range frontierImplementations(t, &FrontierOptions{MaxDepth: 1}) {
+		seed := "https://www.zhipin.com/job_detail/seed.html"
+		f.Add(context.Background(), seed)
+		links := []string{"/job_detail/child-1.html", "/job_detail/child-2.html", "/job_detail/seed.html"}
+		if added := f.AddEntries(context.Background(), ChildEntries(f, seed, links, 0)); added != 2 {
+			t.Errorf("%s: added %d children, expected 2", name, added)
+		}
+		child := "https://www.zhipin.com/job_detail/child-1.html"
+		if added := f.AddEntries(context.Background(), ChildEntries(f, child, []string{"/job_detail/grandchild.html"}, 0)); added != 0 {
+			t.Errorf("%s: added %d urls beyond the max depth, expected 0", name, added)
+		}
+		record, _ := f.Get("https://www.zhipin.com/job_detail/child-2.html")
+		if record.Depth != 1 {
+			t.Errorf("%s: depth of child-2 = %d, expected 1", name, record.Depth)
+		}
+		expected := []string{seed, child, "https://www.zhipin.com/job_detail/child-2.html"}
+		if visited := drain(f); fmt.Sprint(visited) != fmt.Sprint(expected) {
+			t.Errorf("%s: visited %v, expected %v", name, visited, expected)
+		}
+	}
+}
+
+func TestFrontierDeduplicatesUrlShapes(t *testing.T) {
+	for name, f := range frontierImplementations(t, nil) {
+		added := f.AddEntries(context.Background(), []FrontierEntry{
+			{Url: "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM#position=1&search_layout=stack"},
+			{Url: "https://www.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM?tracking_id=abc"},
+			{Url: "https://www.mercadolibre.com.pe/teclado-redragon/p/MPE19045732?pdp_filters=item_id:MPE600000001"},
+			{Url: "https://articulo.mercadolibre.com.pe/MPE-600000002-teclado-aula-_JM"},
+		})
+		if added != 2 {
+			t.Errorf("%s: added %d urls, expected 2", name, added)
+		}
+		f.MarkVisited("HTTPS://ARTICULO.mercadolibre.com.pe/MPE-600000001-otro-titulo-_JM")
+		next, _ := f.GetNext()
+		if next != "https://articulo.mercadolibre.com.pe/MPE-600000002-teclado-aula-_JM" {
+			t.Errorf("%s: GetNext() = %q, expected the second item", name, next)
+		}
+		record, ok := f.Get("https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM")
+		if !ok || record.Url != "https://articulo.mercadolibre.com.pe/MPE-600000001-teclado-redragon-_JM" || record.Status != StatusVisited {
+			t.Errorf("%s: record of the first item = %+v", name, record)
+		}
+		if count := f.Count(); count != 2 {
+			t.Errorf("%s: Count() = %d, expected 2", name, count)
+		}
+	}
+}
+
+func BenchmarkFrontierGetNext(b *testing.B) {
+	f := NewURLFrontier()
+	for i := 0; i < 10000; i++ {
+		f.Add(context.Background(), fmt.Sprintf("/job_detail/%d.html", i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		url, ok := f.GetNext()
+		if !ok {
+			b.StopTimer()
+			for j := 0; j < 10000; j++ {
+				f.Add(context.Background(), fmt.Sprintf("/job_detail/%d-%d.html", i, j))
+			}
+			b.StartTimer()
+			continue
+		}
+		f.MarkVisited(url)
+	}
+}
+
+func TestFrontierRequeue(t *testing.T) {
+	for name, f := range frontierImplementations(t, nil) {
+		f.BulkAdd(context.Background(), []string{"a", "b", "c"})
+		f.MarkFailed("a", newScrapeError(ErrTimeout, "a", "goto", nil))
+		f.Requeue("a")
+		f.Requeue("b")
+		expected := []string{"b", "c", "a"}
+		if visited := drain(f); fmt.Sprint(visited) != fmt.Sprint(expected) {
+			t.Errorf("%s: visited %v, expected the requeued url last %v", name, visited, expected)
+		}
+		if record, _ := f.Get("a"); record.Attempts != 2 {
+			t.Errorf("%s: a has %d attempts, expected 2", name, record.Attempts)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package gejie
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MeliVariant is one combination of a product's variant pickers, e.g. {"Color": "Negro", "Switch": "Red"}
+type MeliVariant struct {
+	Attributes map[string]string
+	Price      Price
+	Available  bool
+	ImageUrls  []string
+	Url        string
+}
+
+// Name lists the attribute values of the variant sorted by attribute, e.g. "Color: Negro, Switch: Red"
+func (v MeliVariant) Name() string {
+	names := make([]string, 0, len(v.Attributes))
+	for name := range v.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+": "+v.Attributes[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// variantFromHTML reads the picker selections of the page as the variant the page shows, along
+// with the links of the picker options leading to the other variants. pages without pickers have
+// no variant
+func variantFromHTML(doc *goquery.Document, pageUrl string, selectors Selectors) (*MeliVariant, []string) {
+	attributes := map[string]string{}
+	links := []string{}
+	selectors.Find(doc, SelectorVariationPickers).Each(func(_ int, picker *goquery.Selection) {
+		// the label reads like "Color: Negro", the selected value may also have an element of its own
+		label := strings.TrimSpace(selectors.Find(picker, SelectorVariationLabel).First().Text())
+		name, value, _ := strings.Cut(label, ":")
+		if selected := strings.TrimSpace(selectors.Find(picker, SelectorVariationSelected).First().Text()); selected != "" {
+			value = selected
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if name != "" && value != "" {
+			attributes[name] = value
+		}
+		selectors.Find(picker, SelectorVariationOptions).Each(func(_ int, option *goquery.Selection) {
+			href := option.AttrOr("href", "")
+			if href == "" {
+				return
+			}
+			if link, err := ResolveURL(pageUrl, href); err == nil {
+				links = append(links, link)
+			}
+		})
+	})
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	return &MeliVariant{Attributes: attributes, Available: availableFromHTML(doc, selectors)}, links
+}
+
+// availableFromHTML tells whether the page's variant can be bought, from the json-ld availability
+// when the page has one and from an enabled buy button otherwise
+func availableFromHTML(doc *goquery.Document, selectors Selectors) bool {
+	if product := jsonLDProductNode(doc); product != nil {
+		if availability := jsonString(jsonPath(firstJSONObject(product["offers"]), "availability")); availability != "" {
+			return strings.HasSuffix(availability, "InStock") || strings.HasSuffix(availability, "LimitedAvailability")
+		}
+	}
+	button := selectors.Find(doc, SelectorBuyButton).First()
+	_, disabled := button.Attr("disabled")
+	return button.Length() > 0 && !disabled
+}
+
+// variantKey identifies a variant by its attribute combination
+func variantKey(v MeliVariant) string {
+	return v.Name()
+}
+
+// enumerateVariants loads the picker options of product breadth first until it knows max variants,
+// every loaded page adds the variant it shows and the options leading on from it. max below 2
+// keeps the variant the product page opened with only
+func enumerateVariants(ctx context.Context, loader DocumentLoader, product *MeliProduct, max int) {
+	if len(product.Variants) == 0 || max < 2 {
+		return
+	}
+	known := map[string]bool{variantKey(product.Variants[0]): true}
+	visited := map[string]bool{}
+	markVisited := func(link string) bool {
+		// the item normalizer would drop the query, variants of an item differ in theirs only
+		if canonical, err := canonicalURL(link, isMeliTrackingParam); err == nil {
+			link = canonical.String()
+		}
+		if visited[link] {
+			return false
+		}
+		visited[link] = true
+		return true
+	}
+	markVisited(product.Url)
+
+	queue := product.variantLinks
+	// option links may differ in tracking params only, loads are bounded so they cannot run away
+	for loads := 0; len(queue) > 0 && len(product.Variants) < max && loads < 4*max; {
+		link := queue[0]
+		queue = queue[1:]
+		if !markVisited(link) {
+			continue
+		}
+		loads++
+		doc, finalUrl, err := loader.Document(ctx, link)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("could not load variant %s of %s: %v", link, product.Url, err)
+			continue
+		}
+		variantProduct, _, err := productFromHTML(doc, finalUrl, link)
+		if err != nil || len(variantProduct.Variants) == 0 {
+			log.Printf("variant page %s has no variant to read: %v", link, err)
+			continue
+		}
+		variant := variantProduct.Variants[0]
+		if !known[variantKey(variant)] {
+			known[variantKey(variant)] = true
+			product.Variants = append(product.Variants, variant)
+		}
+		queue = append(queue, variantProduct.variantLinks...)
+	}
+}
+
+// FlattenVariants returns a product per variant, each with the title, price, images and url of
+// its variant, the variant attributes added to its attributes and Variants holding that variant
+// only. products without variants are kept as they are
+func FlattenVariants(products []MeliProduct) []MeliProduct {
+	flat := []MeliProduct{}
+	for _, product := range products {
+		if len(product.Variants) < 2 {
+			flat = append(flat, product)
+			continue
+		}
+		for _, variant := range product.Variants {
+			row := product
+			row.Variants = []MeliVariant{variant}
+			row.Title = product.Title + " (" + variant.Name() + ")"
+			row.Price = variant.Price
+			row.Url = variant.Url
+			if len(variant.ImageUrls) > 0 {
+				row.ImageUrls = variant.ImageUrls
+			}
+			row.Attributes = make(map[string]string, len(product.Attributes)+len(variant.Attributes))
+			for name, value := range product.Attributes {
+				row.Attributes[name] = value
+			}
+			for name, value := range variant.Attributes {
+				row.Attributes[name] = value
+			}
+			flat = append(flat, row)
+		}
+	}
+	return flat
+}
@@ -0,0 +1,196 @@
+package gejie
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var (
+	variantColors   = []string{"negro", "blanco"}
+	variantSwitches = []string{"red", "blue"}
+	variantLabels   = map[string]string{"negro": "Negro", "blanco": "Blanco", "red": "Red", "blue": "Blue"}
+)
+
+// variantPage renders the product page of a color and switch, whose pickers link to the pages
+// changing one of the two. white blue switches are sold out
+func variantPage(color string, switchType string) string {
+	var b strings.Builder
+	price := 1000
+	if color == "blanco" {
+		price += 100
+	}
+	if switchType == "blue" {
+		price += 20
+	}
+	fmt.Fprintf(&b, `<html><body><h1 class="ui-pdp-title">Teclado Kumara</h1>
+<div class="ui-pdp-price__second-line"><span class="andes-money-amount__fraction">%d</span></div>
+<img class="ui-pdp-gallery__figure__image" src="https://http2.mlstatic.com/%s.jpg">`, price, color)
+	fmt.Fprintf(&b, `<div class="ui-pdp-variations__picker"><p class="ui-pdp-variations__label">Color: <span class="ui-pdp-variations__selected-label">%s</span></p>`, variantLabels[color])
+	for _, other := range variantColors {
+		fmt.Fprintf(&b, `<a class="ui-pdp-thumbnail" href="/p/%s-%s?tracking_id=abc">%s</a>`, other, switchType, other)
+	}
+	fmt.Fprintf(&b, `</div><div class="ui-pdp-variations__picker"><p class="ui-pdp-variations__label">Switch: %s</p>`, variantLabels[switchType])
+	for _, other := range variantSwitches {
+		fmt.Fprintf(&b, `<a class="ui-pdp-thumbnail" href="/p/%s-%s">%s</a>`, color, other, other)
+	}
+	disabled := ""
+	if color == "blanco" && switchType == "blue" {
+		disabled = " disabled"
+	}
+	fmt.Fprintf(&b, `</div><form class="ui-pdp-buybox__form"><button type="submit"%s>Comprar ahora</button></form></body></html>`, disabled)
+	return b.String()
+}
+
+func newVariantSite(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	loads := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		color, switchType, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/p/"), "-")
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		loads.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(variantPage(color, switchType)))
+	}))
+	t.Cleanup(server.Close)
+	return server, loads
+}
+
+func TestHTTPFetcherEnumeratesVariants(t *testing.T) {
+	server, loads := newVariantSite(t)
+	tests := []struct {
+		name        string
+		maxVariants int
+		expected    []string
+	}{
+		{"page variant only", 1, []string{"Color: Negro, Switch: Red"}},
+		{"capped", 3, []string{"Color: Blanco, Switch: Red", "Color: Negro, Switch: Blue", "Color: Negro, Switch: Red"}},
+		{"all", 10, []string{"Color: Blanco, Switch: Blue", "Color: Blanco, Switch: Red", "Color: Negro, Switch: Blue", "Color: Negro, Switch: Red"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loads.Store(0)
+			opts := DefaultBrowserOptions()
+			opts.RateLimiter = nil
+			opts.MaxVariants = tt.maxVariants
+			fetcher := NewHTTPFetcher(opts)
+			defer fetcher.Close()
+
+			product, err := fetcher.Product(context.Background(), server.URL+"/p/negro-red")
+			if err != nil {
+				t.Fatalf("Product failed: %v", err)
+			}
+			names := []string{}
+			for _, variant := range product.Variants {
+				names = append(names, variant.Name())
+			}
+			if product.Variants[0].Name() != "Color: Negro, Switch: Red" {
+				t.Errorf("first variant = %q, expected the one the page opened with", product.Variants[0].Name())
+			}
+			sort.Strings(names)
+			if strings.Join(names, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("variants = %v, expected %v", names, tt.expected)
+			}
+			if loads.Load() > 4 {
+				t.Errorf("loaded %d pages, expected every variant page once at most", loads.Load())
+			}
+		})
+	}
+}
+
+func TestVariantFields(t *testing.T) {
+	server, _ := newVariantSite(t)
+	opts := DefaultBrowserOptions()
+	opts.RateLimiter = nil
+	opts.MaxVariants = 4
+	fetcher := NewHTTPFetcher(opts)
+	defer fetcher.Close()
+
+	product, err := fetcher.Product(context.Background(), server.URL+"/p/negro-red")
+	if err != nil {
+		t.Fatalf("Product failed: %v", err)
+	}
+	byName := map[string]MeliVariant{}
+	for _, variant := range product.Variants {
+		byName[variant.Name()] = variant
+	}
+	soldOut := byName["Color: Blanco, Switch: Blue"]
+	if soldOut.Available || soldOut.Price.AmountCents != 112000 || soldOut.ImageUrls[0] != "https://http2.mlstatic.com/blanco.jpg" {
+		t.Errorf("white blue variant = %+v, expected unavailable at 1120 with the white image", soldOut)
+	}
+	if !strings.HasSuffix(soldOut.Url, "/p/blanco-blue") {
+		t.Errorf("white blue variant url = %q", soldOut.Url)
+	}
+	if first := byName["Color: Negro, Switch: Red"]; !first.Available || first.Price.AmountCents != 100000 {
+		t.Errorf("black red variant = %+v, expected available at 1000", first)
+	}
+}
+
+func TestAvailableFromJSONLD(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected bool
+	}{
+		{"in stock", `<script type="application/ld+json">{"@type":"Product","offers":{"availability":"https://schema.org/InStock"}}</script><button type="submit" disabled></button>`, true},
+		{"out of stock", `<script type="application/ld+json">{"@type":"Product","offers":{"availability":"https://schema.org/OutOfStock"}}</script><form class="ui-pdp-buybox__form"><button type="submit"></button></form>`, false},
+		{"buy button", `<form class="ui-pdp-buybox__form"><button type="submit"></button></form>`, true},
+		{"no buy button", `<p>Publicación pausada</p>`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := structuredDoc(t, "<html><body>"+tt.html+"</body></html>")
+			if got := availableFromHTML(doc, selectorsFor(SiteMeli, structuredPageUrl)); got != tt.expected {
+				t.Errorf("availableFromHTML = %t, expected %t", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFlattenVariants(t *testing.T) {
+	products := []MeliProduct{
+		{
+			Title:      "Teclado",
+			Url:        "https://articulo.mercadolibre.com.pe/MPE-1",
+			ImageUrls:  []string{"a.jpg"},
+			Attributes: map[string]string{"Marca": "Redragon"},
+			Variants: []MeliVariant{
+				{Attributes: map[string]string{"Color": "Negro"}, Price: Price{AmountCents: 100}, Available: true, ImageUrls: []string{"negro.jpg"}, Url: "https://articulo.mercadolibre.com.pe/MPE-1"},
+				{Attributes: map[string]string{"Color": "Blanco"}, Price: Price{AmountCents: 200}, Url: "https://articulo.mercadolibre.com.pe/MPE-1?attributes=COLOR:Blanco"},
+			},
+		},
+		{Title: "Silla", Url: "https://articulo.mercadolibre.com.pe/MPE-2"},
+	}
+
+	flat := FlattenVariants(products)
+	if len(flat) != 3 {
+		t.Fatalf("FlattenVariants returned %d products, expected a row per variant and the product without variants", len(flat))
+	}
+	white := flat[1]
+	if white.Title != "Teclado (Color: Blanco)" || white.Price.AmountCents != 200 || white.ImageUrls[0] != "a.jpg" || white.Attributes["Color"] != "Blanco" || white.Attributes["Marca"] != "Redragon" {
+		t.Errorf("white row = %+v", white)
+	}
+	if _, ok := products[0].Attributes["Color"]; ok {
+		t.Errorf("FlattenVariants changed the attributes of the product it flattened")
+	}
+	if flat[2].Title != "Silla" {
+		t.Errorf("product without variants = %+v, expected it unchanged", flat[2])
+	}
+
+	records := meliProductCsvRecords(flat)
+	variantColumn := len(meliProductCsvHeader) - 1
+	if records[0][variantColumn] != "Variant" || records[1][variantColumn] != "Color: Negro" || records[3][variantColumn] != "" {
+		t.Errorf("variant column = %q, %q, %q", records[0][variantColumn], records[1][variantColumn], records[3][variantColumn])
+	}
+	if records := meliProductCsvRecords(products); records[1][variantColumn] != "2 variants" {
+		t.Errorf("variant column of an unflattened product = %q", records[1][variantColumn])
+	}
+}
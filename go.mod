@@ -3,8 +3,13 @@ module github.com/zshanhui/gejiezhipin
 go 1.24
 
 require (
+	github.com/PuerkitoBio/goquery v1.10.0
+	github.com/andybalholm/cascadia v1.3.2
 	github.com/playwright-community/playwright-go v0.5200.0
 	github.com/spf13/cobra v1.9.1
+	go.etcd.io/bbolt v1.4.0
+	golang.org/x/net v0.29.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -12,6 +17,7 @@ require (
 	github.com/go-jose/go-jose/v3 v3.0.4 // indirect
 	github.com/go-stack/stack v1.8.1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
+	github.com/kr/text v0.2.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
 )
@@ -17,13 +17,45 @@ const devMode int8 = 1
 func main() {
 	MaxItems := 10
 	if devMode == 1 {
-		// used to test during development
-		if len(os.Args) > 1 && os.Args[1] == "--zhipin" {
-			gejie.RunZhipin("https://www.zhipin.com/job_detail/b6840d4438ff55c41n1609S-FFVT.html", true)
-			return
+		ctx, stop := cli.SignalContext()
+		defer stop()
+
+		mode := ""
+		if len(os.Args) > 1 {
+			mode = os.Args[1]
+		}
+		// one browser is shared by everything the run scrapes, started once the mode is known
+		var bm *gejie.BrowserManager
+		startBrowser := func(opts *gejie.BrowserOptions) *gejie.BrowserManager {
+			var err error
+			if bm, err = gejie.NewBrowserManager(opts); err != nil {
+				fmt.Printf("could not start browser: %v\n", err)
+				os.Exit(1)
+			}
+			return bm
+		}
+		closeBrowser := func() {
+			if bm != nil {
+				bm.Close()
+			}
+		}
+		defer closeBrowser()
+		// os.Exit skips the deferred close
+		exit := func(code int) {
+			closeBrowser()
+			os.Exit(code)
 		}
 
-		if len(os.Args) > 1 && os.Args[1] == "--meli-search" {
+		// used to test during development
+		switch mode {
+		case "--zhipin":
+			bm := startBrowser(gejie.DefaultZhipinBrowserOptions())
+			if err := gejie.RunZhipin(ctx, bm, "https://www.zhipin.com/job_detail/b6840d4438ff55c41n1609S-FFVT.html", nil); err != nil {
+				fmt.Printf("zhipin run failed: %v\n", err)
+				exit(1)
+			}
+
+		case "--meli-search":
 			searchUrlPe := "https://listado.mercadolibre.com.pe/teclado-mecanico"
 			// searchURlMx := "https://listado.mercadolibre.com.mx/teclado-inalambrico"
 			maxItems := 2 // default value
@@ -39,39 +71,62 @@ func main() {
 					break
 				}
 			}
-			products := gejie.RunMeliSearch(&searchUrlPe, int8(maxItems), false)
-			for _, product := range products {
+			bm := startBrowser(gejie.DefaultBrowserOptions())
+			searchOpts := gejie.DefaultMeliSearchOptions()
+			searchOpts.MaxItems = maxItems
+			result, err := gejie.RunMeliSearchWithBrowser(ctx, bm, &searchUrlPe, searchOpts)
+			if err != nil && result == nil {
+				fmt.Printf("meli search failed: %v\n", err)
+				exit(1)
+			}
+			for _, product := range result.Products {
 				utils.PrintProduct(&product)
 			}
+			if err != nil {
+				fmt.Printf("meli search stopped early: %v\n", err)
+			}
 
-		} else if len(os.Args) > 1 && os.Args[1] == "--meli-product-links" {
+		case "--meli-product-links":
 			searchUrlPe := "https://listado.mercadolibre.com.pe/teclado-mecanico"
-			bm, _ := gejie.NewBrowserManager(gejie.DefaultBrowserOptions())
+			bm := startBrowser(gejie.DefaultBrowserOptions())
 			page, _ := bm.NewPage()
 			page.Goto(searchUrlPe, playwright.PageGotoOptions{
 				Timeout: playwright.Float(8000),
 			})
 
 			fmt.Print("test scraping product links...\n")
-			productLinks := gejie.ScrapeProductLinksWithPagination(page, MaxItems)
+			productLinks, err := gejie.ScrapeProductLinksWithPagination(ctx, page, MaxItems, bm.RateLimiter())
+			if err != nil {
+				fmt.Printf("error scraping product links: %v\n", err)
+			}
 			for _, productLink := range productLinks {
 				fmt.Println(productLink)
 			}
 
-		} else if len(os.Args) > 1 && os.Args[1] == "--meli-product" {
-			product := gejie.ScrapeProductPageDirect(gejie.ProductUrlExample)
+		case "--meli-product":
+			bm := startBrowser(gejie.DefaultBrowserOptions())
+			product, err := gejie.ScrapeProduct(ctx, bm, gejie.ProductUrlExample)
+			if err != nil {
+				fmt.Printf("could not scrape product: %v\n", err)
+				exit(1)
+			}
 			utils.PrintProduct(product)
 
-		} else if len(os.Args) > 1 && os.Args[1] == "--meli-product-images" {
-			images := gejie.ScrapeProductImages(nil, gejie.ProductUrlExample)
+		case "--meli-product-images":
+			bm := startBrowser(gejie.DefaultBrowserOptions())
+			images, err := gejie.ScrapeProductImages(ctx, bm, gejie.ProductUrlExample)
+			if err != nil {
+				fmt.Printf("could not scrape product images: %v\n", err)
+			}
 			fmt.Print("extracted images: ", images)
 
-		} else if len(os.Args) > 1 && os.Args[1] == "--meli-store" {
-			panic("not implemented")
+		case "--meli-store":
+			fmt.Printf("--meli-store is not implemented yet\n")
+			exit(1)
 
-		} else {
-			fmt.Printf("command not recognized: %s", os.Args[1])
-			os.Exit(1)
+		default:
+			fmt.Printf("command not recognized: %s\n", mode)
+			exit(1)
 		}
 		return
 	}
@@ -182,16 +182,24 @@ func CurrencyCodeToAbbrev(code CurrencyCode) CurrencyAbbrev {
 
 // StandardizeAmount handles string representation differences in currencies and converts the whole to cents int
 func StandardizeAmountCents(amountWhole string, curCode CurrencyCode) int {
-	var amountInt int
-	var err error
+	amountInt, err := ParseAmountCents(amountWhole)
+	if err != nil {
+		log.Printf("failed to parse amount to int: %v", err)
+		return 0
+	}
+
+	return amountInt
+}
+
+// ParseAmountCents converts the whole part of a price such as "4,333" to cents, returning an error for unparseable text
+func ParseAmountCents(amountWhole string) (int, error) {
 	// separators from left side of decimal should be removed, e.g. "4,333", "4.333" -> "4333"
 	amountWhole = strings.ReplaceAll(amountWhole, ".", "")
 	amountWhole = strings.ReplaceAll(amountWhole, ",", "")
-	amountInt, err = strconv.Atoi(amountWhole)
+	amountInt, err := strconv.Atoi(strings.TrimSpace(amountWhole))
 	if err != nil {
-		log.Fatalf("failed to parse amount to int: %v", err)
-		return 0
+		return 0, err
 	}
 
-	return amountInt * 100
+	return amountInt * 100, nil
 }
@@ -340,3 +340,27 @@ func TestStandardizeAmount(t *testing.T) {
 		})
 	}
 }
+
+func TestParseAmountCents(t *testing.T) {
+	tests := []struct {
+		amount    string
+		expected  int
+		expectErr bool
+	}{
+		{"1,234", 123400, false},
+		{"1.234", 123400, false},
+		{" 159 ", 15900, false},
+		{"", 0, true},
+		{"S/ 159", 0, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseAmountCents(tt.amount)
+		if (err != nil) != tt.expectErr {
+			t.Errorf("ParseAmountCents(%q) error = %v, expectErr %t", tt.amount, err, tt.expectErr)
+		}
+		if result != tt.expected {
+			t.Errorf("ParseAmountCents(%q) = %d, want %d", tt.amount, result, tt.expected)
+		}
+	}
+}